@@ -26,6 +26,13 @@ func main() {
     port := flag.String("port", ":8080", "The server port")
     flag.Parse()
 
+    if flag.Arg(0) == "migrate" {
+        if err := runMigrate(*dsn, flag.Args()[1:]); err != nil {
+            logger.Fatal("migration command failed", zap.Error(err))
+        }
+        return
+    }
+
     db, err := database.NewDB(*dsn)
     if err != nil {
         logger.Fatal("failed to initialize database", zap.Error(err))
@@ -35,13 +42,21 @@ func main() {
     // Inicializar servicios
     tradeService := services.NewTradeService(db)
 
+    // Cierra o marca para revisión los trades vencidos al arrancar y cada día
+    jobCtx, stopJobs := context.WithCancel(context.Background())
+    defer stopJobs()
+    go tradeService.RunExpirationScheduler(jobCtx)
+
     // Inicializar handlers
-    tradeHandler := handlers.NewTradeHandler(db.DB)
+    tradeHandler := handlers.NewTradeHandler(db.DB, tradeService)
     tradeImportHandler := handlers.NewTradeImportHandler(tradeService)
-    accountHandler := handlers.NewAccountHandler(db.DB)
-    positionHandler := handlers.NewPositionHandler(db.DB)
-    incomeHandler := handlers.NewIncomeHandler(db.DB)
-    wheelHandler := handlers.NewWheelHandler(db.DB)
+    accountHandler := handlers.NewAccountHandler(db.DB, tradeService)
+    positionHandler := handlers.NewPositionHandler(db.DB, tradeService)
+    incomeHandler := handlers.NewIncomeHandler(db.DB, tradeService)
+    wheelHandler := handlers.NewWheelHandler(db.DB, tradeService)
+    strategyHandler := handlers.NewStrategyHandler(tradeService)
+    reportHandler := handlers.NewReportHandler(tradeService)
+    corporateActionHandler := handlers.NewCorporateActionHandler(tradeService)
     apiHandler := handlers.NewAPIHandler(db.DB)
     apiConfigHandler := handlers.NewAPIConfigHandler(db.DB)
 
@@ -75,11 +90,18 @@ func main() {
         v1.PUT("/trades/:id", tradeHandler.UpdateTrade)
         v1.DELETE("/trades/:id", tradeHandler.DeleteTrade)
         v1.POST("/trades/:id/close", tradeHandler.CloseTrade)
+        v1.POST("/trades/:id/roll", tradeHandler.RollTrade)
+        v1.GET("/trades/:id/executions", tradeHandler.ListExecutions)
+        v1.POST("/trades/:id/executions", tradeHandler.AddExecution)
+        v1.DELETE("/trades/:id/executions/:execution_id", tradeHandler.DeleteExecution)
         v1.POST("/trades/import", tradeImportHandler.Import)
         v1.POST("/trades/validate", tradeImportHandler.ValidateCSV)
         v1.POST("/trades/confirm", tradeImportHandler.ConfirmImport)
+        v1.POST("/trades/executions/import", tradeImportHandler.ImportExecutions)
         v1.POST("/trades/buy", tradeHandler.BuyStocks)
         v1.POST("/trades/sell", tradeHandler.SellStocks)
+        v1.GET("/trades/expirations", tradeHandler.ListExpirationLog)
+        v1.POST("/trades/expirations/run", tradeHandler.RunExpirations)
 
         // ==================== ACCOUNTS ====================
         v1.GET("/accounts", accountHandler.ListAccounts)
@@ -91,6 +113,8 @@ func main() {
         v1.POST("/accounts/:id/deposit", accountHandler.Deposit)
         v1.POST("/accounts/:id/withdrawal", accountHandler.Withdrawal)
         v1.GET("/accounts/:id/transactions", accountHandler.GetTransactionHistory)
+        v1.GET("/accounts/:id/reconcile", accountHandler.Reconcile)
+        v1.GET("/accounts/:id/buying-power", accountHandler.GetBuyingPower)
         v1.POST("/accounts/activate", accountHandler.ActivateAccount)
 
         // ==================== POSITIONS ====================
@@ -100,6 +124,7 @@ func main() {
         v1.PUT("/positions/:id", positionHandler.UpdatePosition)
         v1.DELETE("/positions/:id", positionHandler.DeletePosition)
         v1.POST("/positions/:id/close", positionHandler.ClosePosition)
+        v1.GET("/lots/sales", positionHandler.ListLotSales)
 
         // ==================== INCOME ====================
         v1.GET("/income", incomeHandler.ListIncome)
@@ -108,9 +133,31 @@ func main() {
 
         // ==================== WHEELS ====================
         v1.GET("/wheels", wheelHandler.ListWheels)
+        v1.GET("/wheels/summaries", wheelHandler.ListSummaries)
         v1.GET("/wheels/:id", wheelHandler.GetWheel)
+        v1.GET("/wheels/:id/breakeven", wheelHandler.GetBreakevenHistory)
+        v1.GET("/wheels/:id/timeline", wheelHandler.GetTimeline)
         v1.POST("/wheels", wheelHandler.CreateWheel)
+        v1.POST("/wheels/backfill", wheelHandler.BackfillWheels)
         v1.PUT("/wheels/:id", wheelHandler.UpdateWheel)
+        v1.POST("/wheels/:id/recompute", wheelHandler.RecomputeWheel)
+        v1.POST("/wheels/:id/close", wheelHandler.CloseWheel)
+        v1.GET("/wheels/:id/summary", wheelHandler.GetSummary)
+
+        // ==================== STRATEGIES ====================
+        v1.GET("/strategies", strategyHandler.ListStrategies)
+        v1.GET("/strategies/:id", strategyHandler.GetStrategy)
+        v1.POST("/strategies", strategyHandler.OpenStrategy)
+        v1.POST("/strategies/:id/close", strategyHandler.CloseStrategy)
+
+        // ==================== REPORTS ====================
+        v1.GET("/reports/tax", reportHandler.TaxReport)
+        v1.GET("/reports/wash-sales", reportHandler.WashSales)
+
+        // ==================== CORPORATE ACTIONS ====================
+        v1.GET("/corporate-actions", corporateActionHandler.ListCorporateActions)
+        v1.GET("/corporate-actions/:id", corporateActionHandler.GetCorporateAction)
+        v1.POST("/corporate-actions", corporateActionHandler.ApplyCorporateAction)
 
         // ==================== ANALYTICS ====================
         v1.GET("/trades/dashboard", tradeHandler.GetDashboard)
@@ -139,6 +186,7 @@ func main() {
         sigint := make(chan os.Signal, 1)
         signal.Notify(sigint, syscall.SIGINT, syscall.SIGTERM)
         <-sigint
+        stopJobs()
 
         ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
         defer cancel()
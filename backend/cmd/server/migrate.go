@@ -0,0 +1,70 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "text/tabwriter"
+
+    "github.com/wheel-tracker/backend/internal/database"
+)
+
+const migrateUsage = "usage: server [-dsn DSN] migrate status|up|down [steps]|verify"
+
+// runMigrate handles `server migrate status|up|down [steps]|verify` without starting the API
+func runMigrate(dsn string, args []string) error {
+    if len(args) == 0 {
+        return errors.New(migrateUsage)
+    }
+
+    db, err := database.Open(dsn)
+    if err != nil {
+        return err
+    }
+    defer db.Close()
+
+    switch args[0] {
+    case "status":
+        statuses, err := db.MigrationStatus()
+        if err != nil {
+            return err
+        }
+        w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+        fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+        for _, s := range statuses {
+            appliedAt := "pending"
+            if s.AppliedAt != nil {
+                appliedAt = *s.AppliedAt
+            }
+            fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+        }
+        return w.Flush()
+
+    case "up":
+        count, err := db.MigrateUp()
+        fmt.Printf("%d migration(s) applied\n", count)
+        return err
+
+    case "verify":
+        if err := db.VerifySchema(); err != nil {
+            return err
+        }
+        fmt.Println("database schema matches the embedded schema")
+        return nil
+
+    case "down":
+        steps := 1
+        if len(args) > 1 {
+            steps, err = strconv.Atoi(args[1])
+            if err != nil || steps <= 0 {
+                return fmt.Errorf("steps must be a positive integer")
+            }
+        }
+        count, err := db.MigrateDown(steps)
+        fmt.Printf("%d migration(s) reverted\n", count)
+        return err
+    }
+
+    return errors.New(migrateUsage)
+}
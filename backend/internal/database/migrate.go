@@ -0,0 +1,293 @@
+package database
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "sort"
+    "strings"
+)
+
+// Migration is a numbered schema change. Down is nil when the change loses
+// data and cannot be reverted; MigrateDown stops there with an error.
+type Migration struct {
+    Version int
+    Name    string
+    Up      func(tx *sql.Tx) error
+    Down    func(tx *sql.Tx) error
+}
+
+// MigrationStatus reports whether a known migration has been applied
+type MigrationStatus struct {
+    Version   int     `json:"version"`
+    Name      string  `json:"name"`
+    Applied   bool    `json:"applied"`
+    AppliedAt *string `json:"applied_at,omitempty"`
+}
+
+// sortedMigrations returns the registered migrations ordered by version
+func sortedMigrations() []Migration {
+    list := make([]Migration, len(migrations))
+    copy(list, migrations)
+    sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+    return list
+}
+
+func (d *DB) ensureMigrationsTable() error {
+    _, err := d.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            name TEXT NOT NULL,
+            applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+        )
+    `)
+    return err
+}
+
+// appliedMigrations returns applied_at keyed by version
+func (d *DB) appliedMigrations() (map[int]string, error) {
+    if err := d.ensureMigrationsTable(); err != nil {
+        return nil, err
+    }
+
+    rows, err := d.Query("SELECT version, applied_at FROM schema_migrations")
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    applied := make(map[int]string)
+    for rows.Next() {
+        var version int
+        var appliedAt string
+        if err := rows.Scan(&version, &appliedAt); err != nil {
+            return nil, err
+        }
+        applied[version] = appliedAt
+    }
+    return applied, rows.Err()
+}
+
+// MigrationStatus lists every known migration and whether it is applied
+func (d *DB) MigrationStatus() ([]MigrationStatus, error) {
+    applied, err := d.appliedMigrations()
+    if err != nil {
+        return nil, err
+    }
+
+    var statuses []MigrationStatus
+    for _, m := range sortedMigrations() {
+        s := MigrationStatus{Version: m.Version, Name: m.Name}
+        if at, ok := applied[m.Version]; ok {
+            s.Applied = true
+            s.AppliedAt = &at
+        }
+        statuses = append(statuses, s)
+    }
+    return statuses, nil
+}
+
+// MigrateUp applies all pending migrations in version order and returns how many ran.
+// An empty database is created directly from schema.sql instead.
+func (d *DB) MigrateUp() (int, error) {
+    applied, err := d.appliedMigrations()
+    if err != nil {
+        return 0, err
+    }
+
+    known := make(map[int]bool)
+    for _, m := range migrations {
+        known[m.Version] = true
+    }
+    for version := range applied {
+        if !known[version] {
+            return 0, fmt.Errorf("database has migration %d applied which this build does not know about; refusing to continue", version)
+        }
+    }
+
+    if len(applied) == 0 {
+        empty, err := d.isEmpty()
+        if err != nil {
+            return 0, err
+        }
+        if empty {
+            if err := d.bootstrap(); err != nil {
+                return 0, err
+            }
+            return len(migrations), nil
+        }
+    }
+
+    count := 0
+    for _, m := range sortedMigrations() {
+        if _, ok := applied[m.Version]; ok {
+            continue
+        }
+        if err := d.runMigration(m, true); err != nil {
+            return count, err
+        }
+        log.Printf("Applied migration %04d_%s\n", m.Version, m.Name)
+        count++
+    }
+    return count, nil
+}
+
+// MigrateDown reverts the most recent applied migrations, up to steps of them
+func (d *DB) MigrateDown(steps int) (int, error) {
+    applied, err := d.appliedMigrations()
+    if err != nil {
+        return 0, err
+    }
+
+    list := sortedMigrations()
+    count := 0
+    for i := len(list) - 1; i >= 0 && count < steps; i-- {
+        m := list[i]
+        if _, ok := applied[m.Version]; !ok {
+            continue
+        }
+        if m.Down == nil {
+            return count, fmt.Errorf("migration %04d_%s cannot be reverted", m.Version, m.Name)
+        }
+        if err := d.runMigration(m, false); err != nil {
+            return count, err
+        }
+        log.Printf("Reverted migration %04d_%s\n", m.Version, m.Name)
+        count++
+    }
+    return count, nil
+}
+
+// runMigration executes one migration step and records it in a single transaction
+func (d *DB) runMigration(m Migration, up bool) error {
+    tx, err := d.Begin()
+    if err != nil {
+        return err
+    }
+
+    step := m.Up
+    if !up {
+        step = m.Down
+    }
+    if err := step(tx); err != nil {
+        tx.Rollback()
+        return fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+    }
+
+    if up {
+        _, err = tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name)
+    } else {
+        _, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version)
+    }
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// execStatements returns a migration step that runs the given SQL as-is
+func execStatements(statements string) func(tx *sql.Tx) error {
+    return func(tx *sql.Tx) error {
+        _, err := tx.Exec(statements)
+        return err
+    }
+}
+
+// columnNames returns the columns of table in definition order
+func columnNames(tx *sql.Tx, table string) ([]string, error) {
+    rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var names []string
+    for rows.Next() {
+        var cid, notNull, pk int
+        var name, colType string
+        var dflt sql.NullString
+        if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+            return nil, err
+        }
+        names = append(names, name)
+    }
+    return names, rows.Err()
+}
+
+// hasColumn reports whether table already has the named column
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+    names, err := columnNames(tx, table)
+    if err != nil {
+        return false, err
+    }
+    for _, name := range names {
+        if strings.EqualFold(name, column) {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
+// addColumn adds a column unless an earlier manual migration already did.
+// definition is everything after the column name, e.g. "REAL DEFAULT 1.0".
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+    exists, err := hasColumn(tx, table, column)
+    if err != nil || exists {
+        return err
+    }
+    _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+    return err
+}
+
+// dropColumn removes a column if it is present
+func dropColumn(tx *sql.Tx, table, column string) error {
+    exists, err := hasColumn(tx, table, column)
+    if err != nil || !exists {
+        return err
+    }
+    _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+    return err
+}
+
+// rebuildTable swaps table for a new definition, which is the only way SQLite
+// can change a CHECK constraint. createSQL must create "<table>_new"; columns
+// present in both versions are copied and the caller recreates the indexes.
+func rebuildTable(tx *sql.Tx, table, createSQL string) error {
+    if _, err := tx.Exec(createSQL); err != nil {
+        return err
+    }
+
+    oldCols, err := columnNames(tx, table)
+    if err != nil {
+        return err
+    }
+    newCols, err := columnNames(tx, table+"_new")
+    if err != nil {
+        return err
+    }
+    existing := make(map[string]bool)
+    for _, name := range oldCols {
+        existing[strings.ToLower(name)] = true
+    }
+    var shared []string
+    for _, name := range newCols {
+        if existing[strings.ToLower(name)] {
+            shared = append(shared, name)
+        }
+    }
+
+    cols := strings.Join(shared, ", ")
+    statements := []string{
+        fmt.Sprintf("INSERT INTO %s_new (%s) SELECT %s FROM %s", table, cols, cols, table),
+        fmt.Sprintf("DROP TABLE %s", table),
+        fmt.Sprintf("ALTER TABLE %s_new RENAME TO %s", table, table),
+    }
+    for _, stmt := range statements {
+        if _, err := tx.Exec(stmt); err != nil {
+            return err
+        }
+    }
+    return nil
+}
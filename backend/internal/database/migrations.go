@@ -0,0 +1,633 @@
+package database
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+)
+
+// migrations is the ordered history of schema changes. Never edit or renumber
+// an entry once it has shipped; add a new one instead.
+var migrations = []Migration{
+    {
+        Version: 1,
+        Name:    "baseline",
+        Up:      initSchema,
+    },
+    {
+        Version: 2,
+        Name:    "accounts_type_and_margin",
+        Up: func(tx *sql.Tx) error {
+            if err := addColumn(tx, "accounts", "account_type", "TEXT DEFAULT 'cash'"); err != nil {
+                return err
+            }
+            return addColumn(tx, "accounts", "margin_multiplier", "REAL DEFAULT 1.0")
+        },
+        Down: func(tx *sql.Tx) error {
+            if err := dropColumn(tx, "accounts", "margin_multiplier"); err != nil {
+                return err
+            }
+            return dropColumn(tx, "accounts", "account_type")
+        },
+    },
+    {
+        Version: 3,
+        Name:    "trades_delta",
+        Up: func(tx *sql.Tx) error {
+            return addColumn(tx, "trades", "delta", "REAL")
+        },
+        Down: func(tx *sql.Tx) error {
+            return dropColumn(tx, "trades", "delta")
+        },
+    },
+    {
+        Version: 4,
+        Name:    "account_transactions",
+        Up: execStatements(`
+            CREATE TABLE IF NOT EXISTS account_transactions (
+                transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                account_id INTEGER NOT NULL,
+                transaction_type TEXT NOT NULL CHECK(transaction_type IN ('DEPOSIT', 'WITHDRAWAL')),
+                amount REAL NOT NULL,
+                transaction_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+                notes TEXT,
+                created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE
+            );
+            CREATE INDEX IF NOT EXISTS idx_account_transactions_account ON account_transactions(account_id);
+            CREATE INDEX IF NOT EXISTS idx_account_transactions_date ON account_transactions(transaction_date);
+        `),
+        Down: execStatements(`
+            DROP INDEX IF EXISTS idx_account_transactions_date;
+            DROP INDEX IF EXISTS idx_account_transactions_account;
+            DROP TABLE IF EXISTS account_transactions;
+        `),
+    },
+    {
+        Version: 5,
+        Name:    "assignment_positions",
+        Up: func(tx *sql.Tx) error {
+            if err := addColumn(tx, "positions", "source_trade_id", "INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL"); err != nil {
+                return err
+            }
+            return rebuildAccountTransactions(tx, "'DEPOSIT', 'WITHDRAWAL', 'ASSIGNMENT'")
+        },
+        Down: func(tx *sql.Tx) error {
+            if err := rebuildAccountTransactions(tx, "'DEPOSIT', 'WITHDRAWAL'"); err != nil {
+                return err
+            }
+            return dropColumn(tx, "positions", "source_trade_id")
+        },
+    },
+    {
+        Version: 6,
+        Name:    "call_away_transactions",
+        Up: func(tx *sql.Tx) error {
+            return rebuildAccountTransactions(tx, "'DEPOSIT', 'WITHDRAWAL', 'ASSIGNMENT', 'CALL_AWAY'")
+        },
+        Down: func(tx *sql.Tx) error {
+            return rebuildAccountTransactions(tx, "'DEPOSIT', 'WITHDRAWAL', 'ASSIGNMENT'")
+        },
+    },
+    {
+        Version: 7,
+        Name:    "trade_roll_chain",
+        Up: func(tx *sql.Tx) error {
+            if err := addColumn(tx, "trades", "parent_trade_id", "INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL"); err != nil {
+                return err
+            }
+            if err := addColumn(tx, "trades", "roll_group", "INTEGER"); err != nil {
+                return err
+            }
+            return execStatements("CREATE INDEX IF NOT EXISTS idx_trades_roll_group ON trades(roll_group)")(tx)
+        },
+        Down: func(tx *sql.Tx) error {
+            if err := execStatements("DROP INDEX IF EXISTS idx_trades_roll_group")(tx); err != nil {
+                return err
+            }
+            if err := dropColumn(tx, "trades", "roll_group"); err != nil {
+                return err
+            }
+            return dropColumn(tx, "trades", "parent_trade_id")
+        },
+    },
+    {
+        Version: 8,
+        Name:    "trade_expiration_log",
+        Up: func(tx *sql.Tx) error {
+            if err := addColumn(tx, "trades", "underlying_price", "REAL"); err != nil {
+                return err
+            }
+            return execStatements(`
+                CREATE TABLE IF NOT EXISTS trade_expiration_log (
+                    log_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    trade_id INTEGER NOT NULL,
+                    action TEXT NOT NULL CHECK(action IN ('EXPIRED', 'ASSIGNMENT_REVIEW')),
+                    underlying_price REAL,
+                    message TEXT,
+                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (trade_id) REFERENCES trades(trade_id) ON DELETE CASCADE
+                );
+                CREATE INDEX IF NOT EXISTS idx_trade_expiration_log_trade ON trade_expiration_log(trade_id);
+            `)(tx)
+        },
+        Down: func(tx *sql.Tx) error {
+            err := execStatements(`
+                DROP INDEX IF EXISTS idx_trade_expiration_log_trade;
+                DROP TABLE IF EXISTS trade_expiration_log;
+            `)(tx)
+            if err != nil {
+                return err
+            }
+            return dropColumn(tx, "trades", "underlying_price")
+        },
+    },
+    {
+        Version: 9,
+        Name:    "stock_order_transactions",
+        Up: func(tx *sql.Tx) error {
+            return rebuildAccountTransactions(tx, "'DEPOSIT', 'WITHDRAWAL', 'ASSIGNMENT', 'CALL_AWAY', 'STOCK_BUY', 'STOCK_SELL'")
+        },
+        Down: func(tx *sql.Tx) error {
+            return rebuildAccountTransactions(tx, "'DEPOSIT', 'WITHDRAWAL', 'ASSIGNMENT', 'CALL_AWAY'")
+        },
+    },
+    {
+        Version: 10,
+        Name:    "wheel_summaries",
+        Up: execStatements(`
+            CREATE TABLE IF NOT EXISTS wheel_summaries (
+                wheel_id INTEGER PRIMARY KEY,
+                account_id INTEGER NOT NULL,
+                symbol TEXT NOT NULL,
+                start_date DATE NOT NULL,
+                end_date DATE NOT NULL,
+                days_held INTEGER NOT NULL,
+                trade_count INTEGER NOT NULL,
+                total_premium REAL NOT NULL,
+                realized_pnl REAL NOT NULL,
+                capital_at_risk REAL NOT NULL,
+                return_on_capital REAL NOT NULL,
+                annualized_return REAL NOT NULL,
+                created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (wheel_id) REFERENCES wheels(wheel_id) ON DELETE CASCADE
+            );
+        `),
+        Down: execStatements("DROP TABLE IF EXISTS wheel_summaries"),
+    },
+    {
+        Version: 11,
+        Name:    "strategy_groups",
+        Up: func(tx *sql.Tx) error {
+            err := execStatements(`
+                CREATE TABLE IF NOT EXISTS strategy_groups (
+                    group_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    account_id INTEGER NOT NULL,
+                    symbol TEXT NOT NULL,
+                    strategy TEXT NOT NULL CHECK(strategy IN ('VERTICAL', 'STRANGLE', 'IRON_CONDOR', 'COLLAR')),
+                    position_id INTEGER REFERENCES positions(position_id) ON DELETE SET NULL,
+                    open_date DATE NOT NULL,
+                    close_date DATE,
+                    status TEXT NOT NULL DEFAULT 'OPEN' CHECK(status IN ('OPEN', 'CLOSED')),
+                    notes TEXT,
+                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE
+                );
+            `)(tx)
+            if err != nil {
+                return err
+            }
+            if err := addColumn(tx, "trades", "side", "TEXT NOT NULL DEFAULT 'STO' CHECK(side IN ('BTO', 'STO'))"); err != nil {
+                return err
+            }
+            if err := addColumn(tx, "trades", "strategy_group_id", "INTEGER REFERENCES strategy_groups(group_id) ON DELETE SET NULL"); err != nil {
+                return err
+            }
+            return execStatements("CREATE INDEX IF NOT EXISTS idx_trades_strategy_group ON trades(strategy_group_id)")(tx)
+        },
+        Down: func(tx *sql.Tx) error {
+            if err := execStatements("DROP INDEX IF EXISTS idx_trades_strategy_group")(tx); err != nil {
+                return err
+            }
+            if err := dropColumn(tx, "trades", "strategy_group_id"); err != nil {
+                return err
+            }
+            if err := dropColumn(tx, "trades", "side"); err != nil {
+                return err
+            }
+            return execStatements("DROP TABLE IF EXISTS strategy_groups")(tx)
+        },
+    },
+    {
+        Version: 12,
+        Name:    "trade_splits",
+        Up: func(tx *sql.Tx) error {
+            return addColumn(tx, "trades", "split_from_trade_id", "INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL")
+        },
+        Down: func(tx *sql.Tx) error {
+            return dropColumn(tx, "trades", "split_from_trade_id")
+        },
+    },
+    {
+        Version: 13,
+        Name:    "trade_executions",
+        Up: execStatements(`
+            CREATE TABLE IF NOT EXISTS trade_executions (
+                execution_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                trade_id INTEGER NOT NULL,
+                action TEXT NOT NULL CHECK(action IN ('OPEN', 'CLOSE')),
+                contracts INTEGER NOT NULL,
+                price REAL NOT NULL,
+                fees REAL NOT NULL DEFAULT 0.0,
+                executed_at DATETIME NOT NULL,
+                created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                FOREIGN KEY (trade_id) REFERENCES trades(trade_id) ON DELETE CASCADE
+            );
+            CREATE INDEX IF NOT EXISTS idx_trade_executions_trade ON trade_executions(trade_id);
+        `),
+        Down: execStatements(`
+            DROP INDEX IF EXISTS idx_trade_executions_trade;
+            DROP TABLE IF EXISTS trade_executions;
+        `),
+    },
+    {
+        Version: 14,
+        Name:    "cash_ledger",
+        Up: func(tx *sql.Tx) error {
+            err := rebuildTable(tx, "account_transactions", `
+                CREATE TABLE account_transactions_new (
+                    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    account_id INTEGER NOT NULL,
+                    transaction_type TEXT NOT NULL CHECK(transaction_type IN (`+ledgerTypes+`)),
+                    amount REAL NOT NULL,
+                    transaction_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    notes TEXT,
+                    trade_id INTEGER,
+                    income_id INTEGER,
+                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE,
+                    FOREIGN KEY (trade_id) REFERENCES trades(trade_id) ON DELETE CASCADE,
+                    FOREIGN KEY (income_id) REFERENCES dividends_income(income_id) ON DELETE CASCADE
+                )
+            `)
+            if err != nil {
+                return err
+            }
+            // Rows left behind by an account deleted without foreign keys
+            // enforced cannot be posted anywhere
+            if err := logOrphans(tx, "trades", "dividends_income"); err != nil {
+                return err
+            }
+            // Amounts become signed cash movements, then every trade and
+            // income row gets the postings it should always have had
+            err = execStatements(`
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_account ON account_transactions(account_id);
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_date ON account_transactions(transaction_date);
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_trade ON account_transactions(trade_id);
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_income ON account_transactions(income_id);
+
+                UPDATE account_transactions SET amount = -ABS(amount)
+                WHERE transaction_type IN ('WITHDRAWAL', 'ASSIGNMENT', 'STOCK_BUY');
+
+                INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes, trade_id)
+                SELECT t.account_id, 'PREMIUM',
+                    (CASE t.side WHEN 'BTO' THEN -1 ELSE 1 END) * t.premium_per_share * t.contracts * 100,
+                    t.open_date, 'Premium of trade #' || t.trade_id, t.trade_id
+                FROM trades t
+                JOIN accounts a ON a.account_id = t.account_id;
+
+                INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes, trade_id)
+                SELECT t.account_id, 'OPTION_CLOSE',
+                    (CASE t.side WHEN 'BTO' THEN 1 ELSE -1 END) * t.close_price * t.contracts * 100,
+                    t.close_date, 'Close of trade #' || t.trade_id, t.trade_id
+                FROM trades t
+                JOIN accounts a ON a.account_id = t.account_id
+                WHERE t.status = 'CLOSED' AND t.close_price IS NOT NULL AND t.close_price <> 0
+                    AND COALESCE(t.close_method, '') NOT IN ('EXPIRATION', 'ASSIGNMENT');
+
+                INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes, trade_id)
+                SELECT t.account_id, 'FEE', -t.fees, COALESCE(t.close_date, t.open_date), 'Fees of trade #' || t.trade_id, t.trade_id
+                FROM trades t
+                JOIN accounts a ON a.account_id = t.account_id
+                WHERE t.fees <> 0;
+
+                INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes, income_id)
+                SELECT i.account_id, 'INCOME', i.amount, i.payment_date, i.income_type || ' #' || i.income_id, i.income_id
+                FROM dividends_income i
+                JOIN accounts a ON a.account_id = i.account_id
+                WHERE i.income_type <> 'SPLIT';
+            `)(tx)
+            if err != nil {
+                return err
+            }
+            return carryBalances(tx, "Balance held before the cash ledger")
+        },
+        // No Down: the postings and adjustments it derives cannot be told
+        // apart from what was recorded by hand, so the ledger it replaced
+        // cannot be restored
+    },
+    {
+        Version: 15,
+        Name:    "tax_lots",
+        Up: func(tx *sql.Tx) error {
+            if err := addColumn(tx, "accounts", "lot_method", "TEXT NOT NULL DEFAULT 'FIFO' CHECK(lot_method IN ('FIFO', 'LIFO', 'HIFO', 'SPECIFIC'))"); err != nil {
+                return err
+            }
+            if err := addColumn(tx, "positions", "split_from_position_id", "INTEGER REFERENCES positions(position_id) ON DELETE SET NULL"); err != nil {
+                return err
+            }
+            // Positions sold before lots were tracked become one sale each
+            return execStatements(`
+                CREATE TABLE IF NOT EXISTS lot_sales (
+                    sale_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    account_id INTEGER NOT NULL,
+                    symbol TEXT NOT NULL,
+                    lot_id INTEGER NOT NULL,
+                    position_id INTEGER NOT NULL,
+                    shares INTEGER NOT NULL,
+                    acquired_date DATE NOT NULL,
+                    sold_date DATE NOT NULL,
+                    cost_basis_per_share REAL NOT NULL,
+                    sale_price_per_share REAL NOT NULL,
+                    fees REAL NOT NULL DEFAULT 0.0,
+                    realized_pnl REAL NOT NULL,
+                    term TEXT NOT NULL CHECK(term IN ('SHORT', 'LONG')),
+                    lot_method TEXT NOT NULL CHECK(lot_method IN ('FIFO', 'LIFO', 'HIFO', 'SPECIFIC')),
+                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE,
+                    FOREIGN KEY (position_id) REFERENCES positions(position_id) ON DELETE CASCADE
+                );
+                CREATE INDEX IF NOT EXISTS idx_lot_sales_account ON lot_sales(account_id);
+                CREATE INDEX IF NOT EXISTS idx_lot_sales_position ON lot_sales(position_id);
+
+                INSERT INTO lot_sales (
+                    account_id, symbol, lot_id, position_id, shares, acquired_date, sold_date,
+                    cost_basis_per_share, sale_price_per_share, realized_pnl, term, lot_method
+                )
+                SELECT account_id, symbol, position_id, position_id, shares, acquired_date, sold_date,
+                    cost_basis_per_share, sold_price_per_share,
+                    (sold_price_per_share - cost_basis_per_share) * shares,
+                    CASE WHEN date(sold_date) > date(acquired_date, '+1 year') THEN 'LONG' ELSE 'SHORT' END,
+                    'FIFO'
+                FROM positions
+                WHERE status = 'CLOSED' AND sold_date IS NOT NULL AND sold_price_per_share IS NOT NULL;
+            `)(tx)
+        },
+        Down: func(tx *sql.Tx) error {
+            err := execStatements(`
+                DROP INDEX IF EXISTS idx_lot_sales_position;
+                DROP INDEX IF EXISTS idx_lot_sales_account;
+                DROP TABLE IF EXISTS lot_sales;
+            `)(tx)
+            if err != nil {
+                return err
+            }
+            if err := dropColumn(tx, "positions", "split_from_position_id"); err != nil {
+                return err
+            }
+            return dropColumn(tx, "accounts", "lot_method")
+        },
+    },
+    {
+        Version: 16,
+        Name:    "lot_sale_trades",
+        Up: func(tx *sql.Tx) error {
+            if err := addColumn(tx, "lot_sales", "trade_id", "INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL"); err != nil {
+                return err
+            }
+            // Shares called away were sold at the strike on the day the call was assigned
+            return execStatements(`
+                UPDATE lot_sales SET trade_id = (
+                    SELECT t.trade_id FROM trades t
+                    WHERE t.account_id = lot_sales.account_id
+                        AND t.symbol = lot_sales.symbol
+                        AND t.status = 'CLOSED'
+                        AND t.close_method = 'ASSIGNMENT'
+                        AND t.trade_type IN ('CC', 'CALL')
+                        AND date(t.close_date) = date(lot_sales.sold_date)
+                        AND ABS(t.strike_price - lot_sales.sale_price_per_share) < 0.005
+                    ORDER BY t.trade_id
+                    LIMIT 1
+                )
+                WHERE trade_id IS NULL;
+            `)(tx)
+        },
+        Down: func(tx *sql.Tx) error {
+            return dropColumn(tx, "lot_sales", "trade_id")
+        },
+    },
+    {
+        Version: 17,
+        Name:    "corporate_actions",
+        Up: func(tx *sql.Tx) error {
+            if err := addColumn(tx, "trades", "deliverable", "INTEGER NOT NULL DEFAULT 100"); err != nil {
+                return err
+            }
+            if err := addColumn(tx, "positions", "split_ratio", "REAL NOT NULL DEFAULT 1.0"); err != nil {
+                return err
+            }
+            return execStatements(`
+                CREATE TABLE IF NOT EXISTS corporate_actions (
+                    action_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    symbol TEXT NOT NULL,
+                    action_type TEXT NOT NULL CHECK(action_type IN ('SPLIT', 'REVERSE_SPLIT', 'SYMBOL_CHANGE')),
+                    split_from INTEGER,
+                    split_to INTEGER,
+                    new_symbol TEXT,
+                    effective_date DATE NOT NULL,
+                    notes TEXT,
+                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+                );
+                CREATE TABLE IF NOT EXISTS corporate_action_adjustments (
+                    adjustment_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    action_id INTEGER NOT NULL,
+                    position_id INTEGER REFERENCES positions(position_id) ON DELETE CASCADE,
+                    trade_id INTEGER REFERENCES trades(trade_id) ON DELETE CASCADE,
+                    old_symbol TEXT NOT NULL,
+                    new_symbol TEXT NOT NULL,
+                    old_quantity INTEGER NOT NULL,
+                    new_quantity INTEGER NOT NULL,
+                    old_price REAL NOT NULL,
+                    new_price REAL NOT NULL,
+                    old_deliverable INTEGER,
+                    new_deliverable INTEGER,
+                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    FOREIGN KEY (action_id) REFERENCES corporate_actions(action_id) ON DELETE CASCADE
+                );
+                CREATE INDEX IF NOT EXISTS idx_corporate_actions_symbol ON corporate_actions(symbol);
+                CREATE INDEX IF NOT EXISTS idx_corporate_action_adjustments_action ON corporate_action_adjustments(action_id);
+            `)(tx)
+        },
+        Down: func(tx *sql.Tx) error {
+            err := execStatements(`
+                DROP INDEX IF EXISTS idx_corporate_action_adjustments_action;
+                DROP INDEX IF EXISTS idx_corporate_actions_symbol;
+                DROP TABLE IF EXISTS corporate_action_adjustments;
+                DROP TABLE IF EXISTS corporate_actions;
+            `)(tx)
+            if err != nil {
+                return err
+            }
+            if err := dropColumn(tx, "positions", "split_ratio"); err != nil {
+                return err
+            }
+            return dropColumn(tx, "trades", "deliverable")
+        },
+    },
+    {
+        Version: 18,
+        Name:    "stock_postings",
+        Up: func(tx *sql.Tx) error {
+            // Rebuilt rather than altered so databases whose ledger predates
+            // ADJUSTMENT accept it too
+            err := rebuildTable(tx, "account_transactions", `
+                CREATE TABLE account_transactions_new (
+                    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+                    account_id INTEGER NOT NULL,
+                    transaction_type TEXT NOT NULL CHECK(transaction_type IN (`+ledgerTypes+`)),
+                    amount REAL NOT NULL,
+                    transaction_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    notes TEXT,
+                    trade_id INTEGER,
+                    income_id INTEGER,
+                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                    position_id INTEGER REFERENCES positions(position_id) ON DELETE CASCADE,
+                    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE,
+                    FOREIGN KEY (trade_id) REFERENCES trades(trade_id) ON DELETE CASCADE,
+                    FOREIGN KEY (income_id) REFERENCES dividends_income(income_id) ON DELETE CASCADE
+                )
+            `)
+            if err != nil {
+                return err
+            }
+            if err := logOrphans(tx, "positions", "lot_sales"); err != nil {
+                return err
+            }
+            // Stock movements were posted only by some endpoints and without
+            // a source. Every lot gets the postings it should always have
+            // had: its purchase, or the strike paid when a put assigned it,
+            // and each sale, or the strike received when a call took it.
+            err = execStatements(`
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_account ON account_transactions(account_id);
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_date ON account_transactions(transaction_date);
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_trade ON account_transactions(trade_id);
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_income ON account_transactions(income_id);
+                CREATE INDEX IF NOT EXISTS idx_account_transactions_position ON account_transactions(position_id);
+
+                DELETE FROM account_transactions
+                WHERE transaction_type IN ('STOCK_BUY', 'STOCK_SELL', 'ASSIGNMENT', 'CALL_AWAY')
+                    AND trade_id IS NULL AND income_id IS NULL;
+
+                INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes, position_id)
+                SELECT p.account_id, 'STOCK_BUY',
+                    -(SELECT SUM(r.cost_basis_per_share * r.shares) FROM positions r
+                      WHERE r.position_id = p.position_id OR r.split_from_position_id = p.position_id),
+                    p.acquired_date, 'Purchase of position #' || p.position_id, p.position_id
+                FROM positions p
+                JOIN accounts a ON a.account_id = p.account_id
+                WHERE p.split_from_position_id IS NULL AND p.source_trade_id IS NULL;
+
+                INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes, position_id)
+                SELECT p.account_id, 'ASSIGNMENT', -t.strike_price * t.contracts * 100, p.acquired_date,
+                    'Assignment of trade #' || t.trade_id || ' into position #' || p.position_id, p.position_id
+                FROM positions p
+                JOIN accounts a ON a.account_id = p.account_id
+                JOIN trades t ON t.trade_id = p.source_trade_id
+                WHERE p.split_from_position_id IS NULL;
+
+                INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes, position_id)
+                SELECT l.account_id,
+                    CASE WHEN l.trade_id IS NULL THEN 'STOCK_SELL' ELSE 'CALL_AWAY' END,
+                    l.sale_price_per_share * l.shares - l.fees, l.sold_date,
+                    CASE WHEN l.trade_id IS NULL THEN 'Sale of position #' || l.position_id
+                        ELSE 'Call away of position #' || l.position_id || ' by trade #' || l.trade_id END,
+                    l.position_id
+                FROM lot_sales l
+                JOIN accounts a ON a.account_id = l.account_id;
+            `)(tx)
+            if err != nil {
+                return err
+            }
+            return carryBalances(tx, "Balance held before stock postings")
+        },
+        // No Down: the unsourced stock postings it replaced are gone
+    },
+    {
+        Version: 19,
+        Name:    "drop_positions_is_covered",
+        // Coverage is worked out from the open calls each time a position is
+        // read; the stored flag was never kept up to date
+        Up: func(tx *sql.Tx) error {
+            return dropColumn(tx, "positions", "is_covered")
+        },
+        Down: func(tx *sql.Tx) error {
+            return addColumn(tx, "positions", "is_covered", "INTEGER DEFAULT 0")
+        },
+    },
+}
+
+// ledgerTypes are the cash movements account_transactions accepts since the
+// cash ledger became the source of account balances
+const ledgerTypes = "'DEPOSIT', 'WITHDRAWAL', 'ASSIGNMENT', 'CALL_AWAY', 'STOCK_BUY', 'STOCK_SELL', 'PREMIUM', 'OPTION_CLOSE', 'FEE', 'INCOME', 'ADJUSTMENT'"
+
+// carryBalances posts an ADJUSTMENT for whatever the stored balance of each
+// account holds beyond its initial balance and ledger, so a migration that
+// derives postings keeps the cash the user recorded. Reconcile reports any
+// drift of the derived postings themselves.
+func carryBalances(tx *sql.Tx, note string) error {
+    _, err := tx.Exec(`
+        INSERT INTO account_transactions (account_id, transaction_type, amount, transaction_date, notes)
+        SELECT account_id, 'ADJUSTMENT', carried, DATE('now'), ?
+        FROM (
+            SELECT a.account_id, COALESCE(a.current_balance, 0) - COALESCE(a.initial_balance, 0) - COALESCE((
+                SELECT SUM(amount) FROM account_transactions x WHERE x.account_id = a.account_id
+            ), 0) AS carried
+            FROM accounts a
+        )
+        WHERE ABS(carried) >= 0.005
+    `, note)
+    return err
+}
+
+// logOrphans reports the rows of tables whose account no longer exists; the
+// ledger migrations post nothing for them
+func logOrphans(tx *sql.Tx, tables ...string) error {
+    for _, table := range tables {
+        var count int
+        err := tx.QueryRow(fmt.Sprintf(`
+            SELECT COUNT(*) FROM %s WHERE account_id NOT IN (SELECT account_id FROM accounts)
+        `, table)).Scan(&count)
+        if err != nil {
+            return err
+        }
+        if count > 0 {
+            log.Printf("Skipping %d %s row(s) whose account does not exist\n", count, table)
+        }
+    }
+    return nil
+}
+
+// rebuildAccountTransactions recreates account_transactions allowing the given
+// quoted, comma separated transaction types
+func rebuildAccountTransactions(tx *sql.Tx, types string) error {
+    err := rebuildTable(tx, "account_transactions", `
+        CREATE TABLE account_transactions_new (
+            transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+            account_id INTEGER NOT NULL,
+            transaction_type TEXT NOT NULL CHECK(transaction_type IN (`+types+`)),
+            amount REAL NOT NULL,
+            transaction_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+            notes TEXT,
+            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+            FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE
+        )
+    `)
+    if err != nil {
+        return err
+    }
+    return execStatements(`
+        CREATE INDEX IF NOT EXISTS idx_account_transactions_account ON account_transactions(account_id);
+        CREATE INDEX IF NOT EXISTS idx_account_transactions_date ON account_transactions(transaction_date);
+    `)(tx)
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+    "context"
+    "math"
+    "path/filepath"
+    "testing"
+)
+
+// openBaseline opens a database holding only the schema of migration 1, as
+// databases that predate the runner do
+func openBaseline(t *testing.T) *DB {
+    t.Helper()
+    db, err := Open(filepath.Join(t.TempDir(), "trades.db"))
+    if err != nil {
+        t.Fatalf("open database: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+
+    if err := db.ensureMigrationsTable(); err != nil {
+        t.Fatalf("create schema_migrations: %v", err)
+    }
+    migrateTo(t, db, 1)
+    return db
+}
+
+// migrateTo applies the pending migrations up to version one at a time
+func migrateTo(t *testing.T, db *DB, version int) {
+    t.Helper()
+    applied, err := db.appliedMigrations()
+    if err != nil {
+        t.Fatalf("list migrations: %v", err)
+    }
+    for _, m := range sortedMigrations() {
+        if _, ok := applied[m.Version]; ok || m.Version > version {
+            continue
+        }
+        if err := db.runMigration(m, true); err != nil {
+            t.Fatalf("apply migration %d: %v", m.Version, err)
+        }
+    }
+}
+
+// A database created by migration 1 before bootstrapping existed has to
+// reach the same schema through the later migrations
+func TestMigrationsReachSchema(t *testing.T) {
+    db := openBaseline(t)
+    if _, err := db.MigrateUp(); err != nil {
+        t.Fatalf("migrate up: %v", err)
+    }
+    if err := db.VerifySchema(); err != nil {
+        t.Error(err)
+    }
+}
+
+// A database in use carries balances kept without a ledger, rows of deleted
+// accounts and assigned shares; migrating it must keep every balance
+func TestMigrationsKeepPopulatedData(t *testing.T) {
+    db := openBaseline(t)
+    ctx := context.Background()
+
+    // Foreign keys were not always enforced, so an account could go and
+    // leave its trades behind
+    conn, err := db.Conn(ctx)
+    if err != nil {
+        t.Fatalf("get connection: %v", err)
+    }
+    fixture := []string{
+        "PRAGMA foreign_keys = OFF",
+        `INSERT INTO accounts (account_id, name, broker, initial_balance, current_balance)
+            VALUES (2, 'Broker', 'IBKR', 0, 20000)`,
+        `INSERT INTO trades (trade_id, account_id, symbol, trade_type, contracts, strike_price, premium_per_share,
+            open_date, expiration_date, close_date, close_method, close_price, fees, status)
+            VALUES (1, 2, 'AAPL', 'CSP', 1, 50, 1, '2026-01-05', '2026-01-16', '2026-01-16', 'ASSIGNMENT', 0, 1, 'CLOSED')`,
+        `INSERT INTO trades (trade_id, account_id, symbol, trade_type, contracts, strike_price, premium_per_share,
+            open_date, expiration_date, status)
+            VALUES (2, 2, 'AAPL', 'CC', 1, 55, 0.5, '2026-01-20', '2026-02-20', 'OPEN')`,
+        `INSERT INTO trades (trade_id, account_id, symbol, trade_type, contracts, strike_price, premium_per_share,
+            open_date, expiration_date, status)
+            VALUES (3, 99, 'MSFT', 'CSP', 1, 300, 3, '2026-01-05', '2026-02-20', 'OPEN')`,
+        `INSERT INTO positions (position_id, account_id, symbol, shares, cost_basis_per_share, acquired_date, status)
+            VALUES (1, 2, 'AAPL', 100, 50, '2026-01-16', 'OPEN')`,
+        `INSERT INTO positions (position_id, account_id, symbol, shares, cost_basis_per_share, acquired_date,
+            sold_date, sold_price_per_share, status)
+            VALUES (2, 1, 'MSFT', 10, 300, '2025-06-02', '2026-02-02', 310, 'CLOSED')`,
+        "PRAGMA foreign_keys = ON",
+    }
+    for _, stmt := range fixture {
+        if _, err := conn.ExecContext(ctx, stmt); err != nil {
+            t.Fatalf("load fixture: %v\n%s", err, stmt)
+        }
+    }
+    conn.Close()
+
+    // The put's shares are linked to it once positions know their source
+    migrateTo(t, db, 5)
+    if _, err := db.Exec("UPDATE positions SET source_trade_id = 1 WHERE position_id = 1"); err != nil {
+        t.Fatalf("link assigned position: %v", err)
+    }
+
+    if _, err := db.MigrateUp(); err != nil {
+        t.Fatalf("migrate up: %v", err)
+    }
+    if err := db.VerifySchema(); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        accountID int
+        balance   float64
+    }{
+        {1, 10000},
+        {2, 20000},
+    }
+    for _, tt := range tests {
+        var stored, ledger float64
+        err := db.QueryRow(`
+            SELECT current_balance, initial_balance + (
+                SELECT COALESCE(SUM(amount), 0) FROM account_transactions WHERE account_id = ?
+            ) FROM accounts WHERE account_id = ?
+        `, tt.accountID, tt.accountID).Scan(&stored, &ledger)
+        if err != nil {
+            t.Fatalf("account %d: %v", tt.accountID, err)
+        }
+        if math.Abs(stored-tt.balance) > 0.005 {
+            t.Errorf("account %d: stored balance = %.2f, want %.2f", tt.accountID, stored, tt.balance)
+        }
+        if math.Abs(ledger-stored) > 0.005 {
+            t.Errorf("account %d: ledger adds up to %.2f, stored balance is %.2f", tt.accountID, ledger, stored)
+        }
+    }
+
+    var assignment float64
+    err = db.QueryRow(`
+        SELECT COALESCE(SUM(amount), 0) FROM account_transactions
+        WHERE position_id = 1 AND transaction_type = 'ASSIGNMENT'
+    `).Scan(&assignment)
+    if err != nil {
+        t.Fatalf("assignment posting: %v", err)
+    }
+    if assignment != -5000 {
+        t.Errorf("assignment of position 1 = %.2f, want -5000", assignment)
+    }
+
+    var orphans int
+    if err := db.QueryRow("SELECT COUNT(*) FROM account_transactions WHERE trade_id = 3").Scan(&orphans); err != nil {
+        t.Fatalf("orphan postings: %v", err)
+    }
+    if orphans != 0 {
+        t.Errorf("trade 3 of a deleted account got %d postings", orphans)
+    }
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+    "database/sql"
+    _ "embed"
+    "fmt"
+    "log"
+    "sort"
+    "strings"
+)
+
+// schemaSQL is the canonical schema; see the header of schema.sql
+//
+//go:embed schema.sql
+var schemaSQL string
+
+//go:embed seed.sql
+var seedSQL string
+
+// baselineSQL is the schema databases had before the migration runner, which
+// migration 1 creates; it must never change
+//
+//go:embed baseline.sql
+var baselineSQL string
+
+// columnInfo is the part of PRAGMA table_info that handlers depend on
+type columnInfo struct {
+    Type    string
+    NotNull bool
+    PK      bool
+}
+
+func (c columnInfo) String() string {
+    s := c.Type
+    if c.NotNull {
+        s += " NOT NULL"
+    }
+    if c.PK {
+        s += " PRIMARY KEY"
+    }
+    return s
+}
+
+// SchemaMismatchError lists every difference between the live database and schema.sql
+type SchemaMismatchError struct {
+    Problems []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+    return "database schema does not match the embedded schema:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// isEmpty reports whether the database has no application tables yet
+func (d *DB) isEmpty() (bool, error) {
+    var tableCount int
+    err := d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'").Scan(&tableCount)
+    return tableCount == 0, err
+}
+
+// initSchema creates the baseline schema for brand new databases. Existing
+// databases are left alone; later migrations bring them up to date. MigrateUp
+// bootstraps empty databases from schema.sql, so in practice this only
+// records that a database predating the runner already has its tables.
+func initSchema(tx *sql.Tx) error {
+    var tableCount int
+    err := tx.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'").Scan(&tableCount)
+    if err != nil {
+        return err
+    }
+    if tableCount > 0 {
+        log.Println("Database schema already exists, skipping initialization")
+        return nil
+    }
+    if _, err := tx.Exec(baselineSQL); err != nil {
+        return err
+    }
+    log.Println("Database schema initialized")
+    return nil
+}
+
+// bootstrap creates a brand new database straight from schema.sql and marks
+// every known migration as applied, since the schema already includes them
+func (d *DB) bootstrap() error {
+    tx, err := d.Begin()
+    if err != nil {
+        return err
+    }
+
+    if _, err := tx.Exec(schemaSQL); err != nil {
+        tx.Rollback()
+        return fmt.Errorf("failed to create schema: %v", err)
+    }
+    if _, err := tx.Exec(seedSQL); err != nil {
+        tx.Rollback()
+        return fmt.Errorf("failed to insert default data: %v", err)
+    }
+    for _, m := range migrations {
+        if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+            tx.Rollback()
+            return err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+    log.Println("Database schema initialized")
+    return nil
+}
+
+// VerifySchema compares every table and column of the live database against
+// the embedded schema and returns a *SchemaMismatchError describing the drift
+func (d *DB) VerifySchema() error {
+    expected, err := expectedColumns()
+    if err != nil {
+        return err
+    }
+    live, err := tableColumns(d.DB)
+    if err != nil {
+        return err
+    }
+
+    var problems []string
+    for _, table := range sortedKeys(expected) {
+        liveCols, ok := live[table]
+        if !ok {
+            problems = append(problems, fmt.Sprintf("- table %s is missing", table))
+            continue
+        }
+        for _, col := range sortedKeys(expected[table]) {
+            want := expected[table][col]
+            got, ok := liveCols[col]
+            switch {
+            case !ok:
+                problems = append(problems, fmt.Sprintf("- %s.%s is missing (expected %s)", table, col, want))
+            case got != want:
+                problems = append(problems, fmt.Sprintf("~ %s.%s is %s, expected %s", table, col, got, want))
+            }
+        }
+        for _, col := range sortedKeys(liveCols) {
+            if _, ok := expected[table][col]; !ok {
+                problems = append(problems, fmt.Sprintf("+ %s.%s is not in the schema", table, col))
+            }
+        }
+    }
+    for _, table := range sortedKeys(live) {
+        if _, ok := expected[table]; !ok {
+            problems = append(problems, fmt.Sprintf("+ table %s is not in the schema", table))
+        }
+    }
+
+    if len(problems) > 0 {
+        return &SchemaMismatchError{Problems: problems}
+    }
+    return nil
+}
+
+// expectedColumns builds schema.sql in a scratch in-memory database and reads it back
+func expectedColumns() (map[string]map[string]columnInfo, error) {
+    mem, err := sql.Open("sqlite3", ":memory:")
+    if err != nil {
+        return nil, err
+    }
+    defer mem.Close()
+    // Every pooled connection to :memory: is a separate database
+    mem.SetMaxOpenConns(1)
+
+    if _, err := mem.Exec(schemaSQL); err != nil {
+        return nil, fmt.Errorf("embedded schema is invalid: %v", err)
+    }
+    return tableColumns(mem)
+}
+
+// tableColumns returns the columns of every application table keyed by table name
+func tableColumns(db *sql.DB) (map[string]map[string]columnInfo, error) {
+    rows, err := db.Query("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'")
+    if err != nil {
+        return nil, err
+    }
+    var tables []string
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            rows.Close()
+            return nil, err
+        }
+        tables = append(tables, name)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    result := make(map[string]map[string]columnInfo)
+    for _, table := range tables {
+        cols, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+        if err != nil {
+            return nil, err
+        }
+        result[table] = make(map[string]columnInfo)
+        for cols.Next() {
+            var cid, notNull, pk int
+            var name, colType string
+            var dflt sql.NullString
+            if err := cols.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+                cols.Close()
+                return nil, err
+            }
+            result[table][name] = columnInfo{
+                Type:    strings.ToUpper(colType),
+                NotNull: notNull == 1,
+                PK:      pk > 0,
+            }
+        }
+        cols.Close()
+        if err := cols.Err(); err != nil {
+            return nil, err
+        }
+    }
+    return result, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
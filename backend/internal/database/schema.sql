@@ -0,0 +1,289 @@
+-- Options Wheel Tracker Database Schema
+--
+-- Canonical schema embedded in the binary. New databases are created from this
+-- file and existing ones are checked against it at startup, so every migration
+-- in migrations.go must leave the database looking exactly like this.
+
+-- Table: accounts
+CREATE TABLE IF NOT EXISTS accounts (
+    account_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    broker TEXT NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'USD',
+    initial_balance REAL DEFAULT 0.0,
+    current_balance REAL DEFAULT 0.0,
+    is_active INTEGER DEFAULT 1,
+    account_type TEXT DEFAULT 'cash',
+    margin_multiplier REAL DEFAULT 1.0,
+    lot_method TEXT NOT NULL DEFAULT 'FIFO' CHECK(lot_method IN ('FIFO', 'LIFO', 'HIFO', 'SPECIFIC')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: account_transactions
+CREATE TABLE IF NOT EXISTS account_transactions (
+    transaction_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    transaction_type TEXT NOT NULL CHECK(transaction_type IN ('DEPOSIT', 'WITHDRAWAL', 'ASSIGNMENT', 'CALL_AWAY', 'STOCK_BUY', 'STOCK_SELL', 'PREMIUM', 'OPTION_CLOSE', 'FEE', 'INCOME', 'ADJUSTMENT')),
+    amount REAL NOT NULL,
+    transaction_date DATETIME DEFAULT CURRENT_TIMESTAMP,
+    notes TEXT,
+    trade_id INTEGER,
+    income_id INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    position_id INTEGER REFERENCES positions(position_id) ON DELETE CASCADE,
+    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE,
+    FOREIGN KEY (trade_id) REFERENCES trades(trade_id) ON DELETE CASCADE,
+    FOREIGN KEY (income_id) REFERENCES dividends_income(income_id) ON DELETE CASCADE
+);
+
+-- Table: trades
+CREATE TABLE IF NOT EXISTS trades (
+    trade_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    symbol TEXT NOT NULL,
+    trade_type TEXT NOT NULL CHECK(trade_type IN ('CSP', 'CC', 'PUT', 'CALL')),
+    contracts INTEGER NOT NULL,
+    strike_price REAL NOT NULL,
+    premium_per_share REAL NOT NULL,
+    delta REAL,
+    open_date DATE NOT NULL,
+    expiration_date DATE NOT NULL,
+    close_date DATE,
+    close_method TEXT CHECK(close_method IN ('BTC', 'EXPIRATION', 'ASSIGNMENT', NULL)),
+    close_price REAL,
+    fees REAL DEFAULT 0.0,
+    status TEXT NOT NULL DEFAULT 'OPEN' CHECK(status IN ('OPEN', 'CLOSED')),
+    tags TEXT,
+    notes TEXT,
+    wheel_id INTEGER,
+    parent_trade_id INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL,
+    roll_group INTEGER,
+    underlying_price REAL,
+    side TEXT NOT NULL DEFAULT 'STO' CHECK(side IN ('BTO', 'STO')),
+    strategy_group_id INTEGER REFERENCES strategy_groups(group_id) ON DELETE SET NULL,
+    split_from_trade_id INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL,
+    deliverable INTEGER NOT NULL DEFAULT 100,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE,
+    FOREIGN KEY (wheel_id) REFERENCES wheels(wheel_id) ON DELETE SET NULL
+);
+
+-- Table: strategy_groups
+CREATE TABLE IF NOT EXISTS strategy_groups (
+    group_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    symbol TEXT NOT NULL,
+    strategy TEXT NOT NULL CHECK(strategy IN ('VERTICAL', 'STRANGLE', 'IRON_CONDOR', 'COLLAR')),
+    position_id INTEGER REFERENCES positions(position_id) ON DELETE SET NULL,
+    open_date DATE NOT NULL,
+    close_date DATE,
+    status TEXT NOT NULL DEFAULT 'OPEN' CHECK(status IN ('OPEN', 'CLOSED')),
+    notes TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE
+);
+
+-- Table: trade_executions
+CREATE TABLE IF NOT EXISTS trade_executions (
+    execution_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    trade_id INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK(action IN ('OPEN', 'CLOSE')),
+    contracts INTEGER NOT NULL,
+    price REAL NOT NULL,
+    fees REAL NOT NULL DEFAULT 0.0,
+    executed_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (trade_id) REFERENCES trades(trade_id) ON DELETE CASCADE
+);
+
+-- Table: trade_expiration_log
+CREATE TABLE IF NOT EXISTS trade_expiration_log (
+    log_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    trade_id INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK(action IN ('EXPIRED', 'ASSIGNMENT_REVIEW')),
+    underlying_price REAL,
+    message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (trade_id) REFERENCES trades(trade_id) ON DELETE CASCADE
+);
+
+-- Table: positions
+CREATE TABLE IF NOT EXISTS positions (
+    position_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    symbol TEXT NOT NULL,
+    shares INTEGER NOT NULL,
+    cost_basis_per_share REAL NOT NULL,
+    acquired_date DATE NOT NULL,
+    sold_date DATE,
+    sold_price_per_share REAL,
+    status TEXT NOT NULL DEFAULT 'OPEN' CHECK(status IN ('OPEN', 'CLOSED')),
+    wheel_id INTEGER,
+    source_trade_id INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL,
+    notes TEXT,
+    split_from_position_id INTEGER REFERENCES positions(position_id) ON DELETE SET NULL,
+    split_ratio REAL NOT NULL DEFAULT 1.0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE,
+    FOREIGN KEY (wheel_id) REFERENCES wheels(wheel_id) ON DELETE SET NULL
+);
+
+-- Table: lot_sales
+CREATE TABLE IF NOT EXISTS lot_sales (
+    sale_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    symbol TEXT NOT NULL,
+    lot_id INTEGER NOT NULL,
+    position_id INTEGER NOT NULL,
+    shares INTEGER NOT NULL,
+    acquired_date DATE NOT NULL,
+    sold_date DATE NOT NULL,
+    cost_basis_per_share REAL NOT NULL,
+    sale_price_per_share REAL NOT NULL,
+    fees REAL NOT NULL DEFAULT 0.0,
+    realized_pnl REAL NOT NULL,
+    term TEXT NOT NULL CHECK(term IN ('SHORT', 'LONG')),
+    lot_method TEXT NOT NULL CHECK(lot_method IN ('FIFO', 'LIFO', 'HIFO', 'SPECIFIC')),
+    trade_id INTEGER REFERENCES trades(trade_id) ON DELETE SET NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE,
+    FOREIGN KEY (position_id) REFERENCES positions(position_id) ON DELETE CASCADE
+);
+
+-- Table: wheels
+CREATE TABLE IF NOT EXISTS wheels (
+    wheel_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    symbol TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE,
+    status TEXT NOT NULL DEFAULT 'ACTIVE' CHECK(status IN ('ACTIVE', 'CLOSED')),
+    current_phase TEXT CHECK(current_phase IN ('CSP', 'HOLDING', 'CC', 'COMPLETED')),
+    total_premium REAL DEFAULT 0.0,
+    total_pnl REAL DEFAULT 0.0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE
+);
+
+-- Table: wheel_summaries
+CREATE TABLE IF NOT EXISTS wheel_summaries (
+    wheel_id INTEGER PRIMARY KEY,
+    account_id INTEGER NOT NULL,
+    symbol TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    days_held INTEGER NOT NULL,
+    trade_count INTEGER NOT NULL,
+    total_premium REAL NOT NULL,
+    realized_pnl REAL NOT NULL,
+    capital_at_risk REAL NOT NULL,
+    return_on_capital REAL NOT NULL,
+    annualized_return REAL NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (wheel_id) REFERENCES wheels(wheel_id) ON DELETE CASCADE
+);
+
+-- Table: dividends_income
+CREATE TABLE IF NOT EXISTS dividends_income (
+    income_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL,
+    symbol TEXT,
+    income_type TEXT NOT NULL CHECK(income_type IN ('DIVIDEND', 'INTEREST', 'SPLIT', 'OTHER')),
+    amount REAL NOT NULL,
+    payment_date DATE NOT NULL,
+    currency TEXT DEFAULT 'USD',
+    notes TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (account_id) REFERENCES accounts(account_id) ON DELETE CASCADE
+);
+
+-- Table: corporate_actions
+CREATE TABLE IF NOT EXISTS corporate_actions (
+    action_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    symbol TEXT NOT NULL,
+    action_type TEXT NOT NULL CHECK(action_type IN ('SPLIT', 'REVERSE_SPLIT', 'SYMBOL_CHANGE')),
+    split_from INTEGER,
+    split_to INTEGER,
+    new_symbol TEXT,
+    effective_date DATE NOT NULL,
+    notes TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: corporate_action_adjustments
+CREATE TABLE IF NOT EXISTS corporate_action_adjustments (
+    adjustment_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action_id INTEGER NOT NULL,
+    position_id INTEGER REFERENCES positions(position_id) ON DELETE CASCADE,
+    trade_id INTEGER REFERENCES trades(trade_id) ON DELETE CASCADE,
+    old_symbol TEXT NOT NULL,
+    new_symbol TEXT NOT NULL,
+    old_quantity INTEGER NOT NULL,
+    new_quantity INTEGER NOT NULL,
+    old_price REAL NOT NULL,
+    new_price REAL NOT NULL,
+    old_deliverable INTEGER,
+    new_deliverable INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (action_id) REFERENCES corporate_actions(action_id) ON DELETE CASCADE
+);
+
+-- Table: exchange_rates
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    rate_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_currency TEXT NOT NULL,
+    to_currency TEXT NOT NULL,
+    rate REAL NOT NULL,
+    timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+    source TEXT DEFAULT 'CURRENCYFREAKS'
+);
+
+-- Table: api_configs
+CREATE TABLE IF NOT EXISTS api_configs (
+    config_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    provider TEXT NOT NULL UNIQUE,
+    api_key TEXT NOT NULL,
+    api_secret TEXT,
+    additional_config TEXT,
+    is_active INTEGER DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Table: portfolios
+CREATE TABLE IF NOT EXISTS portfolios (
+    portfolio_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    description TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX IF NOT EXISTS idx_trades_symbol ON trades(symbol);
+CREATE INDEX IF NOT EXISTS idx_trades_status ON trades(status);
+CREATE INDEX IF NOT EXISTS idx_trades_account ON trades(account_id);
+CREATE INDEX IF NOT EXISTS idx_trades_expiration ON trades(expiration_date);
+CREATE INDEX IF NOT EXISTS idx_trades_roll_group ON trades(roll_group);
+CREATE INDEX IF NOT EXISTS idx_trades_strategy_group ON trades(strategy_group_id);
+CREATE INDEX IF NOT EXISTS idx_trade_executions_trade ON trade_executions(trade_id);
+CREATE INDEX IF NOT EXISTS idx_trade_expiration_log_trade ON trade_expiration_log(trade_id);
+CREATE INDEX IF NOT EXISTS idx_positions_symbol ON positions(symbol);
+CREATE INDEX IF NOT EXISTS idx_positions_status ON positions(status);
+CREATE INDEX IF NOT EXISTS idx_positions_account ON positions(account_id);
+CREATE INDEX IF NOT EXISTS idx_lot_sales_account ON lot_sales(account_id);
+CREATE INDEX IF NOT EXISTS idx_lot_sales_position ON lot_sales(position_id);
+CREATE INDEX IF NOT EXISTS idx_wheels_symbol ON wheels(symbol);
+CREATE INDEX IF NOT EXISTS idx_wheels_status ON wheels(status);
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_pair ON exchange_rates(from_currency, to_currency);
+CREATE INDEX IF NOT EXISTS idx_account_transactions_account ON account_transactions(account_id);
+CREATE INDEX IF NOT EXISTS idx_account_transactions_date ON account_transactions(transaction_date);
+CREATE INDEX IF NOT EXISTS idx_account_transactions_trade ON account_transactions(trade_id);
+CREATE INDEX IF NOT EXISTS idx_account_transactions_income ON account_transactions(income_id);
+CREATE INDEX IF NOT EXISTS idx_account_transactions_position ON account_transactions(position_id);
+CREATE INDEX IF NOT EXISTS idx_corporate_actions_symbol ON corporate_actions(symbol);
+CREATE INDEX IF NOT EXISTS idx_corporate_action_adjustments_action ON corporate_action_adjustments(action_id);
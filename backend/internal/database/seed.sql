@@ -0,0 +1,10 @@
+-- Default data inserted when a brand new database is created
+
+INSERT INTO accounts (name, broker, currency, initial_balance, current_balance)
+VALUES ('Default Portfolio', 'Interactive Brokers', 'USD', 10000.00, 10000.00);
+
+INSERT INTO api_configs (provider, api_key, is_active)
+VALUES
+    ('FINNHUB', 'your_finnhub_api_key_here', 1),
+    ('CURRENCYFREAKS', 'your_currencyfreaks_api_key_here', 1),
+    ('INTERACTIVE_BROKERS', 'localhost:4002', 1);
@@ -2,96 +2,63 @@ package handlers
 
 import (
     "database/sql"
+    "errors"
     "fmt"
     "net/http"
 
     "github.com/gin-gonic/gin"
     "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+    "github.com/wheel-tracker/backend/internal/services"
 )
 
 type AccountHandler struct {
-    db *sql.DB
+    db       *sql.DB
+    service  *services.TradeService
+    accounts *repository.AccountRepo
+    trades   *repository.TradeRepo
 }
 
-func NewAccountHandler(db *sql.DB) *AccountHandler {
-    return &AccountHandler{db: db}
+func NewAccountHandler(db *sql.DB, service *services.TradeService) *AccountHandler {
+    return &AccountHandler{
+        db:       db,
+        service:  service,
+        accounts: repository.NewAccountRepo(db),
+        trades:   repository.NewTradeRepo(db),
+    }
 }
 
 // Lista todas las cuentas sin filtrar por is_active
 func (h *AccountHandler) ListAllAccounts(c *gin.Context) {
-    rows, err := h.db.Query(`
-        SELECT account_id, name, broker, currency, initial_balance,
-               current_balance, is_active, account_type, margin_multiplier, created_at, updated_at
-        FROM accounts
-        ORDER BY account_id
-    `)
+    accounts, err := h.accounts.List(c.Request.Context(), false)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    defer rows.Close()
-
-    var accounts []models.Account
-    for rows.Next() {
-        var acc models.Account
-        err := rows.Scan(&acc.AccountID, &acc.Name, &acc.Broker, &acc.Currency,
-            &acc.InitialBalance, &acc.CurrentBalance, &acc.IsActive,
-            &acc.AccountType, &acc.MarginMultiplier, &acc.CreatedAt, &acc.UpdatedAt)
-        if err != nil {
-            continue
-        }
-        accounts = append(accounts, acc)
-    }
 
     c.JSON(http.StatusOK, accounts)
 }
 
 // Lista las cuentas activas
 func (h *AccountHandler) ListAccounts(c *gin.Context) {
-    rows, err := h.db.Query(`
-        SELECT account_id, name, broker, currency, initial_balance,
-               current_balance, is_active, account_type, margin_multiplier, created_at, updated_at
-        FROM accounts WHERE is_active = 1
-    `)
+    accounts, err := h.accounts.List(c.Request.Context(), true)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    defer rows.Close()
-
-    var accounts []models.Account
-    for rows.Next() {
-        var acc models.Account
-        err := rows.Scan(&acc.AccountID, &acc.Name, &acc.Broker, &acc.Currency,
-            &acc.InitialBalance, &acc.CurrentBalance, &acc.IsActive,
-            &acc.AccountType, &acc.MarginMultiplier, &acc.CreatedAt, &acc.UpdatedAt)
-        if err != nil {
-            continue
-        }
-        accounts = append(accounts, acc)
-    }
 
     c.JSON(http.StatusOK, accounts)
 }
 
 func (h *AccountHandler) GetAccount(c *gin.Context) {
-    id := c.Param("id")
-
-    var acc models.Account
-    err := h.db.QueryRow(`
-        SELECT account_id, name, broker, currency, initial_balance,
-               current_balance, is_active, account_type, margin_multiplier, created_at, updated_at
-        FROM accounts WHERE account_id = ?
-    `, id).Scan(&acc.AccountID, &acc.Name, &acc.Broker, &acc.Currency,
-        &acc.InitialBalance, &acc.CurrentBalance, &acc.IsActive,
-        &acc.AccountType, &acc.MarginMultiplier, &acc.CreatedAt, &acc.UpdatedAt)
-
-    if err == sql.ErrNoRows {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+    id, ok := paramID(c)
+    if !ok {
         return
     }
+
+    acc, err := h.accounts.Get(c.Request.Context(), id)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Account not found")
         return
     }
 
@@ -104,39 +71,38 @@ func (h *AccountHandler) CreateAccount(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if acc.LotMethod != "" && !services.ValidLotMethod(acc.LotMethod) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "lot_method must be FIFO, LIFO, HIFO or SPECIFIC"})
+        return
+    }
 
-    result, err := h.db.Exec(`
-        INSERT INTO accounts (name, broker, currency, initial_balance, current_balance, account_type, margin_multiplier)
-        VALUES (?, ?, ?, ?, ?, ?, ?)
-    `, acc.Name, acc.Broker, acc.Currency, acc.InitialBalance, acc.CurrentBalance, acc.AccountType, acc.MarginMultiplier)
-
-    if err != nil {
+    if err := h.accounts.Create(c.Request.Context(), &acc); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    id, _ := result.LastInsertId()
-    acc.AccountID = int(id)
     c.JSON(http.StatusCreated, acc)
 }
 
 func (h *AccountHandler) UpdateAccount(c *gin.Context) {
-    id := c.Param("id")
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
     var acc models.Account
 
     if err := c.ShouldBindJSON(&acc); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if acc.LotMethod != "" && !services.ValidLotMethod(acc.LotMethod) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "lot_method must be FIFO, LIFO, HIFO or SPECIFIC"})
+        return
+    }
 
-    _, err := h.db.Exec(`
-        UPDATE accounts
-        SET name = ?, broker = ?, currency = ?, current_balance = ?, account_type = ?, margin_multiplier = ?, updated_at = CURRENT_TIMESTAMP
-        WHERE account_id = ?
-    `, acc.Name, acc.Broker, acc.Currency, acc.CurrentBalance, acc.AccountType, acc.MarginMultiplier, id)
-
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    acc.AccountID = id
+    if err := h.accounts.Update(c.Request.Context(), acc); err != nil {
+        respondError(c, err, "Account not found")
         return
     }
 
@@ -158,27 +124,18 @@ func (h *AccountHandler) ActivateAccount(c *gin.Context) {
         return
     }
 
-    _, err = tx.Exec("UPDATE accounts SET is_active = 0")
-    if err != nil {
+    err = h.accounts.WithTx(tx).Activate(c.Request.Context(), req.AccountID)
+    if errors.Is(err, repository.ErrNotFound) {
         tx.Rollback()
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
         return
     }
-
-    res, err := tx.Exec("UPDATE accounts SET is_active = 1 WHERE account_id = ?", req.AccountID)
     if err != nil {
         tx.Rollback()
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    rowsAffected, err := res.RowsAffected()
-    if err != nil || rowsAffected == 0 {
-        tx.Rollback()
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Account not found"})
-        return
-    }
-
     if err := tx.Commit(); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -188,7 +145,11 @@ func (h *AccountHandler) ActivateAccount(c *gin.Context) {
 }
 
 func (h *AccountHandler) DeleteAccount(c *gin.Context) {
-    id := c.Param("id")
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+    ctx := c.Request.Context()
 
     tx, err := h.db.Begin()
     if err != nil {
@@ -197,18 +158,16 @@ func (h *AccountHandler) DeleteAccount(c *gin.Context) {
     }
 
     // Elimina los trades relacionados
-    _, err = tx.Exec("DELETE FROM trades WHERE account_id = ?", id)
-    if err != nil {
+    if err := h.trades.WithTx(tx).DeleteByAccount(ctx, id); err != nil {
         tx.Rollback()
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
     // Elimina la cuenta
-    _, err = tx.Exec("DELETE FROM accounts WHERE account_id = ?", id)
-    if err != nil {
+    if err := h.accounts.WithTx(tx).Delete(ctx, id); err != nil {
         tx.Rollback()
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Account not found")
         return
     }
 
@@ -221,7 +180,10 @@ func (h *AccountHandler) DeleteAccount(c *gin.Context) {
 }
 
 func (h *AccountHandler) Deposit(c *gin.Context) {
-    id := c.Param("id")
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
     var req struct {
         Amount float64 `json:"amount"`
         Notes  string  `json:"notes"`
@@ -230,38 +192,30 @@ func (h *AccountHandler) Deposit(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-
-    tx, err := h.db.Begin()
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    if req.Amount <= 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
         return
     }
+    ctx := c.Request.Context()
 
-    result, err := tx.Exec(`
-        UPDATE accounts
-        SET current_balance = current_balance + ?, updated_at = CURRENT_TIMESTAMP
-        WHERE account_id = ? AND is_active = 1
-    `, req.Amount, id)
-
+    tx, err := h.db.Begin()
     if err != nil {
-        tx.Rollback()
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    accounts := h.accounts.WithTx(tx)
 
-    rowsAffected, _ := result.RowsAffected()
-    if rowsAffected == 0 {
+    active, err := accounts.IsActive(ctx, id)
+    if err == nil && !active {
+        err = repository.ErrNotFound
+    }
+    if err != nil {
         tx.Rollback()
-        c.JSON(http.StatusNotFound, gin.H{"error": "Account not found or inactive"})
+        respondError(c, err, "Account not found or inactive")
         return
     }
 
-    _, err = tx.Exec(`
-        INSERT INTO account_transactions (account_id, transaction_type, amount, notes)
-        VALUES (?, 'DEPOSIT', ?, ?)
-    `, id, req.Amount, req.Notes)
-
-    if err != nil {
+    if err := accounts.PostCash(ctx, id, "DEPOSIT", req.Amount, req.Notes); err != nil {
         tx.Rollback()
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -276,7 +230,10 @@ func (h *AccountHandler) Deposit(c *gin.Context) {
 }
 
 func (h *AccountHandler) Withdrawal(c *gin.Context) {
-    id := c.Param("id")
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
     var req struct {
         Amount float64 `json:"amount"`
         Notes  string  `json:"notes"`
@@ -285,103 +242,94 @@ func (h *AccountHandler) Withdrawal(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if req.Amount <= 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+        return
+    }
+    ctx := c.Request.Context()
 
     tx, err := h.db.Begin()
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    accounts := h.accounts.WithTx(tx)
 
-    var currentBalance float64
-    err = tx.QueryRow(`
-        SELECT current_balance FROM accounts
-        WHERE account_id = ? AND is_active = 1
-    `, id).Scan(&currentBalance)
-
-    if err == sql.ErrNoRows {
+    acc, err := accounts.Get(ctx, id)
+    if err == nil && !acc.IsActive {
+        err = repository.ErrNotFound
+    }
+    if err != nil {
         tx.Rollback()
-        c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+        respondError(c, err, "Account not found")
         return
     }
 
-    if currentBalance < req.Amount {
+    if acc.CurrentBalance < req.Amount {
         tx.Rollback()
-        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Insufficient balance. Current: %.2f, Requested: %.2f", currentBalance, req.Amount)})
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Insufficient balance. Current: %.2f, Requested: %.2f", acc.CurrentBalance, req.Amount)})
         return
     }
 
-    result, err := tx.Exec(`
-        UPDATE accounts
-        SET current_balance = current_balance - ?, updated_at = CURRENT_TIMESTAMP
-        WHERE account_id = ? AND is_active = 1
-    `, req.Amount, id)
-
-    if err != nil {
+    if err := accounts.PostCash(ctx, id, "WITHDRAWAL", -req.Amount, req.Notes); err != nil {
         tx.Rollback()
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    rowsAffected, _ := result.RowsAffected()
-    if rowsAffected == 0 {
-        tx.Rollback()
-        c.JSON(http.StatusNotFound, gin.H{"error": "Account not found or inactive"})
+    if err := tx.Commit(); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    _, err = tx.Exec(`
-        INSERT INTO account_transactions (account_id, transaction_type, amount, notes)
-        VALUES (?, 'WITHDRAWAL', ?, ?)
-    `, id, req.Amount, req.Notes)
+    c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+}
 
-    if err != nil {
-        tx.Rollback()
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *AccountHandler) GetTransactionHistory(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
         return
     }
 
-    if err := tx.Commit(); err != nil {
+    transactions, err := h.accounts.ListTransactions(c.Request.Context(), id)
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful"})
+    c.JSON(http.StatusOK, transactions)
 }
 
-func (h *AccountHandler) GetTransactionHistory(c *gin.Context) {
-    id := c.Param("id")
-
-    rows, err := h.db.Query(`
-        SELECT transaction_id, account_id, transaction_type, amount, transaction_date, notes
-        FROM account_transactions
-        WHERE account_id = ?
-        ORDER BY transaction_date DESC
-    `, id)
+// Reconcile checks the account's ledger against its trades and income and
+// its stored balance against the ledger
+func (h *AccountHandler) Reconcile(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
 
+    report, err := h.service.Reconcile(c.Request.Context(), id)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Account not found")
         return
     }
-    defer rows.Close()
 
-    type Transaction struct {
-        TransactionID   int     `json:"transaction_id"`
-        AccountID       int     `json:"account_id"`
-        TransactionType string  `json:"transaction_type"`
-        Amount          float64 `json:"amount"`
-        TransactionDate string  `json:"transaction_date"`
-        Notes           string  `json:"notes"`
+    c.JSON(http.StatusOK, report)
+}
+
+// GetBuyingPower returns the cash, the collateral reserved by open puts and
+// strategies and the free buying power of the account
+func (h *AccountHandler) GetBuyingPower(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
     }
 
-    var transactions []Transaction
-    for rows.Next() {
-        var t Transaction
-        err := rows.Scan(&t.TransactionID, &t.AccountID, &t.TransactionType, &t.Amount, &t.TransactionDate, &t.Notes)
-        if err != nil {
-            continue
-        }
-        transactions = append(transactions, t)
+    bp, err := h.service.GetBuyingPower(c.Request.Context(), id)
+    if err != nil {
+        respondError(c, err, "Account not found")
+        return
     }
 
-    c.JSON(http.StatusOK, transactions)
+    c.JSON(http.StatusOK, bp)
 }
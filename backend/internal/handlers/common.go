@@ -0,0 +1,36 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wheel-tracker/backend/internal/repository"
+    "github.com/wheel-tracker/backend/internal/services"
+)
+
+// paramID parses the :id route parameter, answering 400 when it is not a number
+func paramID(c *gin.Context) (int, bool) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+        return 0, false
+    }
+    return id, true
+}
+
+// respondError maps repository.ErrNotFound to 404 with notFoundMsg, service
+// validation errors to 400 and anything else to 500
+func respondError(c *gin.Context, err error, notFoundMsg string) {
+    var invalid *services.ValidationError
+    if errors.Is(err, repository.ErrNotFound) {
+        c.JSON(http.StatusNotFound, gin.H{"error": notFoundMsg})
+        return
+    }
+    if errors.As(err, &invalid) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Message})
+        return
+    }
+    c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wheel-tracker/backend/internal/services"
+)
+
+// CorporateActionHandler serves splits, reverse splits and symbol changes
+type CorporateActionHandler struct {
+    service *services.TradeService
+}
+
+func NewCorporateActionHandler(service *services.TradeService) *CorporateActionHandler {
+    return &CorporateActionHandler{service: service}
+}
+
+// ListCorporateActions returns the corporate actions applied, optionally
+// filtered by symbol
+func (h *CorporateActionHandler) ListCorporateActions(c *gin.Context) {
+    actions, err := h.service.ListCorporateActions(c.Request.Context(), c.Query("symbol"))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, actions)
+}
+
+// GetCorporateAction returns an action with the positions and trades it adjusted
+func (h *CorporateActionHandler) GetCorporateAction(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    action, err := h.service.GetCorporateAction(c.Request.Context(), id)
+    if err != nil {
+        respondError(c, err, "Corporate action not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, action)
+}
+
+// ApplyCorporateAction records a corporate action and adjusts the open
+// positions and trades of its symbol
+func (h *CorporateActionHandler) ApplyCorporateAction(c *gin.Context) {
+    var req services.CorporateActionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    action, err := h.service.ApplyCorporateAction(c.Request.Context(), req)
+    if err != nil {
+        respondError(c, err, "Corporate action not found")
+        return
+    }
+
+    c.JSON(http.StatusCreated, action)
+}
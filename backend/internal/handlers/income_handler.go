@@ -3,59 +3,45 @@ package handlers
 import (
     "database/sql"
     "net/http"
+    "strconv"
 
     "github.com/gin-gonic/gin"
     "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+    "github.com/wheel-tracker/backend/internal/services"
 )
 
 type IncomeHandler struct {
-    db *sql.DB
+    db      *sql.DB
+    service *services.TradeService
+    income  *repository.IncomeRepo
 }
 
-func NewIncomeHandler(db *sql.DB) *IncomeHandler {
-    return &IncomeHandler{db: db}
+func NewIncomeHandler(db *sql.DB, service *services.TradeService) *IncomeHandler {
+    return &IncomeHandler{
+        db:      db,
+        service: service,
+        income:  repository.NewIncomeRepo(db),
+    }
 }
 
 func (h *IncomeHandler) ListIncome(c *gin.Context) {
-    accountID := c.Query("account_id")
-    incomeType := c.Query("income_type")
-
-    query := `
-        SELECT income_id, account_id, symbol, income_type, amount, 
-               payment_date, currency, notes, created_at
-        FROM dividends_income WHERE 1=1
-    `
-    var args []interface{}
+    filter := repository.IncomeFilter{IncomeType: c.Query("income_type")}
 
-    if accountID != "" {
-        query += " AND account_id = ?"
-        args = append(args, accountID)
-    }
-
-    if incomeType != "" {
-        query += " AND income_type = ?"
-        args = append(args, incomeType)
+    if accountID := c.Query("account_id"); accountID != "" {
+        id, err := strconv.Atoi(accountID)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+            return
+        }
+        filter.AccountID = id
     }
 
-    query += " ORDER BY payment_date DESC"
-
-    rows, err := h.db.Query(query, args...)
+    incomes, err := h.income.List(c.Request.Context(), filter)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    defer rows.Close()
-
-    var incomes []models.Income
-    for rows.Next() {
-        var inc models.Income
-        err := rows.Scan(&inc.IncomeID, &inc.AccountID, &inc.Symbol, &inc.IncomeType,
-            &inc.Amount, &inc.PaymentDate, &inc.Currency, &inc.Notes, &inc.CreatedAt)
-        if err != nil {
-            continue
-        }
-        incomes = append(incomes, inc)
-    }
 
     c.JSON(http.StatusOK, incomes)
 }
@@ -67,27 +53,22 @@ func (h *IncomeHandler) CreateIncome(c *gin.Context) {
         return
     }
 
-    result, err := h.db.Exec(`
-        INSERT INTO dividends_income (account_id, symbol, income_type, amount, payment_date, currency, notes)
-        VALUES (?, ?, ?, ?, ?, ?, ?)
-    `, inc.AccountID, inc.Symbol, inc.IncomeType, inc.Amount, inc.PaymentDate, inc.Currency, inc.Notes)
-    
-    if err != nil {
+    if err := h.service.CreateIncome(c.Request.Context(), &inc); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    id, _ := result.LastInsertId()
-    inc.IncomeID = int(id)
     c.JSON(http.StatusCreated, inc)
 }
 
 func (h *IncomeHandler) DeleteIncome(c *gin.Context) {
-    id := c.Param("id")
-    
-    _, err := h.db.Exec("DELETE FROM dividends_income WHERE income_id = ?", id)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    if err := h.service.DeleteIncome(c.Request.Context(), id); err != nil {
+        respondError(c, err, "Income not found")
         return
     }
 
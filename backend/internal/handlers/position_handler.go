@@ -3,72 +3,62 @@ package handlers
 import (
     "database/sql"
     "net/http"
+    "strconv"
 
     "github.com/gin-gonic/gin"
     "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+    "github.com/wheel-tracker/backend/internal/services"
 )
 
 type PositionHandler struct {
-    db *sql.DB
+    db        *sql.DB
+    service   *services.TradeService
+    positions *repository.PositionRepo
 }
 
-func NewPositionHandler(db *sql.DB) *PositionHandler {
-    return &PositionHandler{db: db}
+func NewPositionHandler(db *sql.DB, service *services.TradeService) *PositionHandler {
+    return &PositionHandler{
+        db:        db,
+        service:   service,
+        positions: repository.NewPositionRepo(db),
+    }
 }
 
 func (h *PositionHandler) ListPositions(c *gin.Context) {
     status := c.DefaultQuery("status", "OPEN")
 
-    rows, err := h.db.Query(`
-        SELECT position_id, account_id, symbol, shares, cost_basis_per_share,
-               acquired_date, sold_date, sold_price_per_share, status, is_covered,
-               wheel_id, notes, created_at, updated_at
-        FROM positions WHERE status = ?
-        ORDER BY acquired_date DESC
-    `, status)
+    positions, err := h.positions.List(c.Request.Context(), repository.PositionFilter{Status: status})
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    defer rows.Close()
-
-    var positions []models.Position
-    for rows.Next() {
-        var p models.Position
-        err := rows.Scan(&p.PositionID, &p.AccountID, &p.Symbol, &p.Shares,
-            &p.CostBasisPerShare, &p.AcquiredDate, &p.SoldDate, &p.SoldPricePerShare,
-            &p.Status, &p.IsCovered, &p.WheelID, &p.Notes, &p.CreatedAt, &p.UpdatedAt)
-        if err != nil {
-            continue
-        }
-        positions = append(positions, p)
-    }
-
-    if positions == nil {
-        positions = make([]models.Position, 0)
+    if err := h.service.AnnotatePositions(c.Request.Context(), positions); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
     }
 
     c.JSON(http.StatusOK, positions)
 }
 
 func (h *PositionHandler) GetPosition(c *gin.Context) {
-    id := c.Param("id")
-
-    var p models.Position
-    err := h.db.QueryRow(`
-        SELECT position_id, account_id, symbol, shares, cost_basis_per_share,
-               acquired_date, sold_date, sold_price_per_share, status, is_covered,
-               wheel_id, notes, created_at, updated_at
-        FROM positions WHERE position_id = ?
-    `, id).Scan(&p.PositionID, &p.AccountID, &p.Symbol, &p.Shares,
-        &p.CostBasisPerShare, &p.AcquiredDate, &p.SoldDate, &p.SoldPricePerShare,
-        &p.Status, &p.IsCovered, &p.WheelID, &p.Notes, &p.CreatedAt, &p.UpdatedAt)
-
-    if err == sql.ErrNoRows {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Position not found"})
+    id, ok := paramID(c)
+    if !ok {
         return
     }
+
+    p, err := h.positions.Get(c.Request.Context(), id)
     if err != nil {
+        respondError(c, err, "Position not found")
+        return
+    }
+    positions := []models.Position{p}
+    if err := h.service.AnnotatePositions(c.Request.Context(), positions); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    p = positions[0]
+    if err := h.service.AnnotateWashSales(c.Request.Context(), &p); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
@@ -83,23 +73,21 @@ func (h *PositionHandler) CreatePosition(c *gin.Context) {
         return
     }
 
-    result, err := h.db.Exec(`
-        INSERT INTO positions (account_id, symbol, shares, cost_basis_per_share, acquired_date, status, notes)
-        VALUES (?, ?, ?, ?, ?, 'OPEN', ?)
-    `, p.AccountID, p.Symbol, p.Shares, p.CostBasisPerShare, p.AcquiredDate, p.Notes)
-
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    p.Status = "OPEN"
+    p.SoldDate, p.SoldPricePerShare = nil, nil
+    if err := h.service.CreatePosition(c.Request.Context(), &p); err != nil {
+        respondError(c, err, "Wheel not found")
         return
     }
 
-    id, _ := result.LastInsertId()
-    p.PositionID = int(id)
     c.JSON(http.StatusCreated, p)
 }
 
 func (h *PositionHandler) UpdatePosition(c *gin.Context) {
-    id := c.Param("id")
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
     var p models.Position
 
     if err := c.ShouldBindJSON(&p); err != nil {
@@ -107,14 +95,9 @@ func (h *PositionHandler) UpdatePosition(c *gin.Context) {
         return
     }
 
-    _, err := h.db.Exec(`
-        UPDATE positions
-        SET shares = ?, cost_basis_per_share = ?, is_covered = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-        WHERE position_id = ?
-    `, p.Shares, p.CostBasisPerShare, p.IsCovered, p.Notes, id)
-
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    p.PositionID = id
+    if err := h.service.UpdatePosition(c.Request.Context(), p); err != nil {
+        respondError(c, err, "Position not found")
         return
     }
 
@@ -122,40 +105,70 @@ func (h *PositionHandler) UpdatePosition(c *gin.Context) {
 }
 
 func (h *PositionHandler) ClosePosition(c *gin.Context) {
-    id := c.Param("id")
-
-    var req struct {
-        SoldDate          string  `json:"sold_date" binding:"required"`
-        SoldPricePerShare float64 `json:"sold_price_per_share" binding:"required"`
+    id, ok := paramID(c)
+    if !ok {
+        return
     }
 
+    var req services.ClosePositionRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    _, err := h.db.Exec(`
-        UPDATE positions
-        SET sold_date = ?, sold_price_per_share = ?, status = 'CLOSED', updated_at = CURRENT_TIMESTAMP
-        WHERE position_id = ?
-    `, req.SoldDate, req.SoldPricePerShare, id)
-
+    sales, err := h.service.ClosePosition(c.Request.Context(), id, req)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Position not found")
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Position closed successfully"})
+    c.JSON(http.StatusOK, gin.H{"message": "Position closed successfully", "lot_sales": sales})
 }
 
 func (h *PositionHandler) DeletePosition(c *gin.Context) {
-    id := c.Param("id")
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    if err := h.service.DeletePosition(c.Request.Context(), id); err != nil {
+        respondError(c, err, "Position not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Position deleted successfully"})
+}
+
+// Lista las ventas por lote con su P&L realizado a corto y largo plazo
+func (h *PositionHandler) ListLotSales(c *gin.Context) {
+    filter := repository.LotSaleFilter{Symbol: c.Query("symbol"), Term: c.Query("term")}
+
+    if accountID := c.Query("account_id"); accountID != "" {
+        id, err := strconv.Atoi(accountID)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+            return
+        }
+        filter.AccountID = id
+    }
+    if year := c.Query("year"); year != "" {
+        y, err := strconv.Atoi(year)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+            return
+        }
+        filter.Year = y
+    }
+    if filter.Term != "" && filter.Term != "SHORT" && filter.Term != "LONG" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "term must be SHORT or LONG"})
+        return
+    }
 
-    _, err := h.db.Exec("DELETE FROM positions WHERE position_id = ?", id)
+    report, err := h.service.ListLotSales(c.Request.Context(), filter)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Position deleted successfully"})
+    c.JSON(http.StatusOK, report)
 }
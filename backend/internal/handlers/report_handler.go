@@ -0,0 +1,136 @@
+package handlers
+
+import (
+    "encoding/csv"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wheel-tracker/backend/internal/services"
+)
+
+type ReportHandler struct {
+    service *services.TradeService
+}
+
+func NewReportHandler(service *services.TradeService) *ReportHandler {
+    return &ReportHandler{service: service}
+}
+
+// form8949Header are the columns of Form 8949 plus the part each row goes in
+var form8949Header = []string{
+    "Part",
+    "(a) Description of property",
+    "(b) Date acquired",
+    "(c) Date sold or disposed of",
+    "(d) Proceeds",
+    "(e) Cost or other basis",
+    "(f) Code(s)",
+    "(g) Amount of adjustment",
+    "(h) Gain or (loss)",
+    "Notes",
+}
+
+// Informe fiscal de ganancias realizadas en un año; format=csv lo exporta
+// como filas del Form 8949
+func (h *ReportHandler) TaxReport(c *gin.Context) {
+    year, err := strconv.Atoi(c.Query("year"))
+    if err != nil || year < 1900 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "year must be given as YYYY"})
+        return
+    }
+    var accountID int
+    if id := c.Query("account_id"); id != "" {
+        if accountID, err = strconv.Atoi(id); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+            return
+        }
+    }
+
+    report, err := h.service.TaxReport(c.Request.Context(), year, accountID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    switch c.DefaultQuery("format", "json") {
+    case "json":
+        c.JSON(http.StatusOK, report)
+    case "csv":
+        c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=form8949-%d.csv", year))
+        c.Header("Content-Type", "text/csv")
+        c.Status(http.StatusOK)
+        if err := writeForm8949(c.Writer, report); err != nil {
+            c.Error(err)
+        }
+    default:
+        c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+    }
+}
+
+// Lista las pérdidas diferidas por wash sales, por símbolo y año de la pérdida
+func (h *ReportHandler) WashSales(c *gin.Context) {
+    var year int
+    if y := c.Query("year"); y != "" {
+        var err error
+        if year, err = strconv.Atoi(y); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+            return
+        }
+    }
+
+    washes, err := h.service.WashSales(c.Request.Context(), c.Query("symbol"), year)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, washes)
+}
+
+// writeForm8949 writes one row per event, short-term (Part I) before long-term (Part II)
+func writeForm8949(w http.ResponseWriter, report *services.TaxReport) error {
+    out := csv.NewWriter(w)
+    if err := out.Write(form8949Header); err != nil {
+        return err
+    }
+    money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+    for _, e := range report.Events {
+        part := "I"
+        if e.Term == "LONG" {
+            part = "II"
+        }
+        adjustment := ""
+        if e.WashSaleDisallowed != 0 {
+            adjustment = money(e.WashSaleDisallowed)
+        }
+        row := []string{
+            part,
+            e.Description,
+            form8949Date(e.AcquiredDate),
+            form8949Date(e.DisposedDate),
+            money(e.Proceeds),
+            money(e.CostBasis),
+            e.Code,
+            adjustment,
+            money(e.Gain),
+            e.Notes,
+        }
+        if err := out.Write(row); err != nil {
+            return err
+        }
+    }
+    out.Flush()
+    return out.Error()
+}
+
+// form8949Date turns YYYY-MM-DD into the MM/DD/YYYY the form uses
+func form8949Date(date string) string {
+    d, err := time.Parse("2006-01-02", date)
+    if err != nil {
+        return date
+    }
+    return d.Format("01/02/2006")
+}
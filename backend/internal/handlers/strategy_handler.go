@@ -0,0 +1,102 @@
+package handlers
+
+import (
+    "errors"
+    "io"
+    "log"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wheel-tracker/backend/internal/repository"
+    "github.com/wheel-tracker/backend/internal/services"
+)
+
+// StrategyHandler serves multi-leg option strategies
+type StrategyHandler struct {
+    service *services.TradeService
+}
+
+func NewStrategyHandler(service *services.TradeService) *StrategyHandler {
+    return &StrategyHandler{service: service}
+}
+
+// ListStrategies returns strategy groups, optionally filtered by status,
+// account_id and symbol
+func (h *StrategyHandler) ListStrategies(c *gin.Context) {
+    filter := repository.StrategyGroupFilter{Status: c.Query("status"), Symbol: c.Query("symbol")}
+    if accountID := c.Query("account_id"); accountID != "" {
+        id, err := strconv.Atoi(accountID)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+            return
+        }
+        filter.AccountID = id
+    }
+
+    strategies, err := h.service.ListStrategies(c.Request.Context(), filter)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusOK, strategies)
+}
+
+func (h *StrategyHandler) GetStrategy(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    strategy, err := h.service.GetStrategy(c.Request.Context(), id)
+    if err != nil {
+        respondError(c, err, "Strategy not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, strategy)
+}
+
+// OpenStrategy opens every leg of a spread, strangle, iron condor or collar
+func (h *StrategyHandler) OpenStrategy(c *gin.Context) {
+    var req services.StrategyRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    // Con allow_shortfall=true se abre aunque supere el buying power y se avisa
+    allowShortfall := c.Query("allow_shortfall") == "true"
+    strategy, err := h.service.OpenStrategy(c.Request.Context(), req, allowShortfall)
+    if err != nil {
+        respondError(c, err, "Position not found")
+        return
+    }
+    for _, w := range strategy.Warnings {
+        log.Printf("Strategy %d opened with a warning: %s\n", strategy.GroupID, w)
+    }
+
+    c.JSON(http.StatusCreated, strategy)
+}
+
+// CloseStrategy closes every open leg of the strategy with one order
+func (h *StrategyHandler) CloseStrategy(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+    var req services.CloseStrategyRequest
+    if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    strategy, err := h.service.CloseStrategy(c.Request.Context(), id, req)
+    if err != nil {
+        respondError(c, err, "Strategy not found or already closed")
+        return
+    }
+
+    c.JSON(http.StatusOK, strategy)
+}
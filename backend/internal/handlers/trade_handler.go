@@ -2,8 +2,9 @@ package handlers
 
 import (
     "bytes"
+    "context"
     "database/sql"
-    "fmt"
+    "errors"
     "io/ioutil"
     "log"
     "net/http"
@@ -11,94 +12,63 @@ import (
 
     "github.com/gin-gonic/gin"
     "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+    "github.com/wheel-tracker/backend/internal/services"
 )
 
 type TradeHandler struct {
-    db *sql.DB
+    db       *sql.DB
+    service  *services.TradeService
+    trades   *repository.TradeRepo
+    accounts *repository.AccountRepo
 }
 
-func NewTradeHandler(db *sql.DB) *TradeHandler {
-    return &TradeHandler{db: db}
-}
-
-func (h *TradeHandler) getActiveAccountID() (int, error) {
-    var accountID int
-    err := h.db.QueryRow("SELECT account_id FROM accounts WHERE is_active = 1 LIMIT 1").Scan(&accountID)
-    if err != nil {
-        return 0, err
+func NewTradeHandler(db *sql.DB, service *services.TradeService) *TradeHandler {
+    return &TradeHandler{
+        db:       db,
+        service:  service,
+        trades:   repository.NewTradeRepo(db),
+        accounts: repository.NewAccountRepo(db),
     }
-    return accountID, nil
 }
 
 func (h *TradeHandler) ListTrades(c *gin.Context) {
-    status := c.DefaultQuery("status", "OPEN")
-    accountID := c.Query("account_id")
+    filter := repository.TradeFilter{Status: c.DefaultQuery("status", "OPEN")}
 
-    if accountID == "" {
-        activeID, err := h.getActiveAccountID()
+    if accountID := c.Query("account_id"); accountID != "" {
+        id, err := strconv.Atoi(accountID)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+            return
+        }
+        filter.AccountID = id
+    } else {
+        activeID, err := h.accounts.GetActiveID(c.Request.Context())
         if err != nil {
             c.JSON(http.StatusBadRequest, gin.H{"error": "No active account found"})
             return
         }
-        accountID = fmt.Sprintf("%d", activeID)
-    }
-
-    query := `
-        SELECT trade_id, account_id, symbol, trade_type, contracts, strike_price,
-               premium_per_share, delta, open_date, expiration_date, close_date, close_method,
-               close_price, fees, status, tags, notes, wheel_id, created_at, updated_at
-        FROM trades WHERE status = ?
-    `
-    args := []interface{}{status}
-
-    if accountID != "" {
-        query += " AND account_id = ?"
-        args = append(args, accountID)
+        filter.AccountID = activeID
     }
 
-    query += " ORDER BY expiration_date DESC"
-
-    rows, err := h.db.Query(query, args...)
+    trades, err := h.trades.List(c.Request.Context(), filter)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    defer rows.Close()
-
-    var trades []models.Trade
-    for rows.Next() {
-        var t models.Trade
-        err := rows.Scan(&t.TradeID, &t.AccountID, &t.Symbol, &t.TradeType, &t.Contracts,
-            &t.StrikePrice, &t.PremiumPerShare, &t.Delta, &t.OpenDate, &t.ExpirationDate,
-            &t.CloseDate, &t.CloseMethod, &t.ClosePrice, &t.Fees, &t.Status,
-            &t.Tags, &t.Notes, &t.WheelID, &t.CreatedAt, &t.UpdatedAt)
-        if err != nil {
-            continue
-        }
-        trades = append(trades, t)
-    }
 
     c.JSON(http.StatusOK, trades)
 }
 
 func (h *TradeHandler) GetTrade(c *gin.Context) {
-    id := c.Param("id")
-    var t models.Trade
-    err := h.db.QueryRow(`
-        SELECT trade_id, account_id, symbol, trade_type, contracts, strike_price,
-               premium_per_share, delta, open_date, expiration_date, close_date, close_method,
-               close_price, fees, status, tags, notes, wheel_id, created_at, updated_at
-        FROM trades WHERE trade_id = ?
-    `, id).Scan(&t.TradeID, &t.AccountID, &t.Symbol, &t.TradeType, &t.Contracts,
-        &t.StrikePrice, &t.PremiumPerShare, &t.Delta, &t.OpenDate, &t.ExpirationDate,
-        &t.CloseDate, &t.CloseMethod, &t.ClosePrice, &t.Fees, &t.Status,
-        &t.Tags, &t.Notes, &t.WheelID, &t.CreatedAt, &t.UpdatedAt)
-    if err == sql.ErrNoRows {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Trade not found"})
+    id, ok := paramID(c)
+    if !ok {
         return
     }
+    t, err := h.service.GetTrade(c.Request.Context(), id)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Trade not found")
         return
     }
     c.JSON(http.StatusOK, t)
@@ -138,6 +108,14 @@ func (h *TradeHandler) CreateTrade(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Premium per share must be zero or positive"})
         return
     }
+    if t.Side != "" && t.Side != "STO" && t.Side != "BTO" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Side must be STO or BTO"})
+        return
+    }
+    if t.StrategyGroupID != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Strategy legs are opened through POST /strategies"})
+        return
+    }
     if t.OpenDate == "" || t.ExpirationDate == "" {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Open date and expiration date are required"})
         return
@@ -146,212 +124,313 @@ func (h *TradeHandler) CreateTrade(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Expiration date must be equal or after open date"})
         return
     }
+    ctx := c.Request.Context()
     if t.AccountID == 0 {
-        activeID, err := h.getActiveAccountID()
+        activeID, err := h.accounts.GetActiveID(ctx)
         if err != nil {
             c.JSON(http.StatusBadRequest, gin.H{"error": "No active account found"})
             return
         }
         t.AccountID = activeID
     }
-    var isActive int
-    err = h.db.QueryRow("SELECT is_active FROM accounts WHERE account_id = ?", t.AccountID).Scan(&isActive)
-    if err != nil || isActive != 1 {
+    active, err := h.accounts.IsActive(ctx, t.AccountID)
+    if err != nil || !active {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Associated account does not exist or is not active"})
         return
     }
-    result, err := h.db.Exec("INSERT INTO trades (account_id, symbol, trade_type, contracts, strike_price, premium_per_share, delta, open_date, expiration_date, fees, status, tags, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'OPEN', ?, ?)",
-        t.AccountID, t.Symbol, t.TradeType, t.Contracts, t.StrikePrice, t.PremiumPerShare, t.Delta, t.OpenDate, t.ExpirationDate, t.Fees, t.Tags, t.Notes)
-    if err != nil {
+
+    // Un trade nuevo siempre nace abierto
+    t.Status = "OPEN"
+    t.CloseDate, t.CloseMethod, t.ClosePrice = nil, nil, nil
+    // Con allow_shortfall=true se abre aunque supere el buying power y se avisa;
+    // una call que deja calls vendidas al descubierto también se abre con aviso
+    allowShortfall := c.Query("allow_shortfall") == "true"
+    // La cuenta, la rueda o el trade padre que falten se devuelven como 400 con su propio mensaje
+    if err := h.service.OpenTrade(ctx, &t, allowShortfall); err != nil {
         log.Printf("DB insert error: %v\n", err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Trade not found")
+        return
+    }
+    for _, w := range t.Warnings {
+        log.Printf("Trade %d opened with a warning: %s\n", t.TradeID, w)
+    }
+    // Releer el trade para devolver lo que asignó la base de datos, como created_at
+    created, err := h.trades.Get(ctx, t.TradeID)
+    if err != nil {
+        respondError(c, err, "Trade not found")
         return
     }
-    id, _ := result.LastInsertId()
-    t.TradeID = int(id)
-    c.JSON(http.StatusCreated, t)
+    created.Warnings = t.Warnings
+    c.JSON(http.StatusCreated, created)
 }
 
 func (h *TradeHandler) UpdateTrade(c *gin.Context) {
-    id := c.Param("id")
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
     var t models.Trade
     if err := c.ShouldBindJSON(&t); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err})
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    _, err := h.db.Exec("UPDATE trades SET symbol = ?, trade_type = ?, contracts = ?, strike_price = ?, premium_per_share = ?, delta = ?, fees = ?, tags = ?, notes = ?, updated_at = CURRENT_TIMESTAMP WHERE trade_id = ?",
-        t.Symbol, t.TradeType, t.Contracts, t.StrikePrice, t.PremiumPerShare, t.Delta, t.Fees, t.Tags, t.Notes, id)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    t.TradeID = id
+    // Con allow_shortfall=true se guarda aunque la edición supere el buying power
+    allowShortfall := c.Query("allow_shortfall") == "true"
+    if err := h.service.UpdateTrade(c.Request.Context(), &t, allowShortfall); err != nil {
+        respondError(c, err, "Trade not found")
         return
     }
-    c.JSON(http.StatusOK, gin.H{"message": "Trade updated"})
+    response := gin.H{"message": "Trade updated"}
+    for _, w := range t.Warnings {
+        log.Printf("Trade %d updated with a warning: %s\n", t.TradeID, w)
+        response["warnings"] = t.Warnings
+    }
+    c.JSON(http.StatusOK, response)
 }
 
 func (h *TradeHandler) DeleteTrade(c *gin.Context) {
-    id := c.Param("id")
-    result, err := h.db.Exec("DELETE FROM trades WHERE trade_id = ?", id)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    id, ok := paramID(c)
+    if !ok {
         return
     }
-    rowsAffected, err := result.RowsAffected()
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        return
-    }
-    if rowsAffected == 0 {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Trade not found"})
+    if err := h.service.DeleteTrade(c.Request.Context(), id); err != nil {
+        respondError(c, err, "Trade not found")
         return
     }
     c.JSON(http.StatusOK, gin.H{"message": "Trade deleted"})
 }
 
 func (h *TradeHandler) CloseTrade(c *gin.Context) {
-    id := c.Param("id")
-    var payload struct {
-        CloseDate   string  "json:\"close_date\""
-        CloseMethod string  "json:\"close_method\""
-        ClosePrice  float64 "json:\"close_price\""
-        Fees        float64 "json:\"fees\""
-        Notes       string  "json:\"notes\""
+    id, ok := paramID(c)
+    if !ok {
+        return
     }
+    var payload services.CloseTradeRequest
     if err := c.ShouldBindJSON(&payload); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    result, err := h.db.Exec("UPDATE trades SET close_date = ?, close_method = ?, close_price = ?, fees = ?, status = 'CLOSED', notes = ? WHERE trade_id = ? AND status = 'OPEN'",
-        payload.CloseDate, payload.CloseMethod, payload.ClosePrice, payload.Fees, payload.Notes, id)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+    result, err := h.service.CloseTrade(c.Request.Context(), id, payload)
+    if errors.Is(err, repository.ErrNotFound) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Trade not found or already closed"})
         return
     }
-    rowsAffected, err := result.RowsAffected()
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        return
-    }
-    if rowsAffected == 0 {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Trade not found or already closed"})
+        respondError(c, err, "Trade not found")
         return
     }
-    c.JSON(http.StatusOK, gin.H{"message": "Trade closed"})
+    c.JSON(http.StatusOK, gin.H{
+        "message":     "Trade closed",
+        "trade":       result.Trade,
+        "position":    result.Position,
+        "called_away": result.CalledAway,
+        "strategy":    result.Strategy,
+        "remaining":   result.Remaining,
+    })
 }
 
-func (h *TradeHandler) BuyStocks(c *gin.Context) {
-    var payload struct {
-        TradeID int     "json:\"trade_id\""
-        Shares  int     "json:\"shares\""
-        Price   float64 "json:\"price\""
-        Date    string  "json:\"date\""
+func (h *TradeHandler) RollTrade(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
     }
+    var payload services.RollTradeRequest
     if err := c.ShouldBindJSON(&payload); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    c.JSON(http.StatusOK, gin.H{"message": "BuyStocks method is a stub, implement as needed"})
-}
 
-func (h *TradeHandler) SellStocks(c *gin.Context) {
-    var payload struct {
-        TradeID int     "json:\"trade_id\""
-        Shares  int     "json:\"shares\""
-        Price   float64 "json:\"price\""
-        Date    string  "json:\"date\""
+    // Con allow_shortfall=true se rola aunque el nuevo trade supere el buying power
+    allowShortfall := c.Query("allow_shortfall") == "true"
+    result, err := h.service.RollTrade(c.Request.Context(), id, payload, allowShortfall)
+    if errors.Is(err, repository.ErrNotFound) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Trade not found or already closed"})
+        return
     }
-    if err := c.ShouldBindJSON(&payload); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    if err != nil {
+        respondError(c, err, "Trade not found")
         return
     }
-    c.JSON(http.StatusOK, gin.H{"message": "SellStocks method is a stub, implement as needed"})
+    for _, w := range result.Opened.Warnings {
+        log.Printf("Trade %d rolled with a warning: %s\n", result.Opened.TradeID, w)
+    }
+    c.JSON(http.StatusOK, gin.H{
+        "message":               "Trade rolled",
+        "closed":                result.Closed,
+        "opened":                result.Opened,
+        "roll_chain_net_credit": result.RollChainNetCredit,
+    })
 }
 
-func (h *TradeHandler) GetDashboard(c *gin.Context) {
-    var dashboard models.Dashboard
-
-    accountIDStr := c.Query("account_id")
-    if accountIDStr == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "account_id is required"})
-        return
+// ListExpirationLog returns what the expiration job has done, optionally
+// filtered by trade_id and action
+func (h *TradeHandler) ListExpirationLog(c *gin.Context) {
+    filter := repository.ExpirationLogFilter{Action: c.Query("action")}
+    if tradeID := c.Query("trade_id"); tradeID != "" {
+        id, err := strconv.Atoi(tradeID)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade_id"})
+            return
+        }
+        filter.TradeID = id
     }
 
-    accountID, err := strconv.Atoi(accountIDStr)
+    entries, err := h.service.ListExpirationLog(c.Request.Context(), filter)
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    c.JSON(http.StatusOK, entries)
+}
 
-    err = h.db.QueryRow("SELECT COUNT(*) FROM trades WHERE account_id = ?", accountID).Scan(&dashboard.TotalTrades)
+// RunExpirations triggers the expiration job without waiting for the scheduler
+func (h *TradeHandler) RunExpirations(c *gin.Context) {
+    run, err := h.service.ProcessExpirations(c.Request.Context(), c.Query("as_of"))
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    c.JSON(http.StatusOK, run)
+}
 
-    err = h.db.QueryRow("SELECT COUNT(*) FROM trades WHERE account_id = ? AND status = 'OPEN'", accountID).Scan(&dashboard.OpenTrades)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// ListExecutions returns the open and close fills of a trade
+func (h *TradeHandler) ListExecutions(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
         return
     }
 
-    err = h.db.QueryRow("SELECT COUNT(*) FROM trades WHERE account_id = ? AND status = 'CLOSED'", accountID).Scan(&dashboard.ClosedTrades)
+    executions, err := h.service.ListExecutions(c.Request.Context(), id)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Trade not found")
         return
     }
+    c.JSON(http.StatusOK, executions)
+}
 
-    var wins int
-    err = h.db.QueryRow("SELECT COUNT(*) FROM trades WHERE account_id = ? AND status = 'CLOSED' AND close_price > strike_price", accountID).Scan(&wins)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// AddExecution records a fill of an open trade; the closing fill that covers
+// the last contract closes it
+func (h *TradeHandler) AddExecution(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
         return
     }
-    if dashboard.ClosedTrades > 0 {
-        dashboard.WinRate = float64(wins) / float64(dashboard.ClosedTrades)
+    var payload services.ExecutionRequest
+    if err := c.ShouldBindJSON(&payload); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
     }
 
-    err = h.db.QueryRow("SELECT IFNULL(SUM(premium_per_share * contracts), 0) FROM trades WHERE account_id = ?", accountID).Scan(&dashboard.TotalNetPremiums)
+    // Con allow_shortfall=true un fill de apertura se registra aunque supere el buying power
+    allowShortfall := c.Query("allow_shortfall") == "true"
+    result, err := h.service.AddExecution(c.Request.Context(), id, payload, allowShortfall)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Trade not found")
         return
     }
+    for _, w := range result.Trade.Warnings {
+        log.Printf("Execution added to trade %d with a warning: %s\n", id, w)
+    }
+    c.JSON(http.StatusCreated, result)
+}
 
-    err = h.db.QueryRow("SELECT IFNULL(SUM(COALESCE(close_price,0) - strike_price) * contracts, 0) FROM trades WHERE account_id = ? AND status = 'OPEN'", accountID).Scan(&dashboard.OpenPositionsPL)
+func (h *TradeHandler) DeleteExecution(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+    executionID, err := strconv.Atoi(c.Param("execution_id"))
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid execution_id"})
         return
     }
 
-    err = h.db.QueryRow("SELECT IFNULL(SUM((close_price - strike_price) * contracts), 0) FROM trades WHERE account_id = ? AND status = 'CLOSED'", accountID).Scan(&dashboard.ClosedPositionsPL)
+    result, err := h.service.DeleteExecution(c.Request.Context(), id, executionID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Execution not found")
         return
     }
+    c.JSON(http.StatusOK, result)
+}
 
-    dashboard.TotalPL = dashboard.OpenPositionsPL + dashboard.ClosedPositionsPL
+func (h *TradeHandler) BuyStocks(c *gin.Context) {
+    h.stockOrder(c, h.service.BuyStocks)
+}
 
-    err = h.db.QueryRow("SELECT IFNULL(SUM(strike_price * contracts), 0) FROM trades WHERE account_id = ? AND status = 'OPEN'", accountID).Scan(&dashboard.OpenTradesCapital)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *TradeHandler) SellStocks(c *gin.Context) {
+    h.stockOrder(c, h.service.SellStocks)
+}
+
+// stockOrder binds a stock order and runs it through place
+func (h *TradeHandler) stockOrder(c *gin.Context, place func(context.Context, services.StockOrderRequest) (*services.StockOrderResult, error)) {
+    var payload services.StockOrderRequest
+    if err := c.ShouldBindJSON(&payload); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    err = h.db.QueryRow("SELECT IFNULL(SUM(strike_price * contracts), 0) FROM trades WHERE account_id = ?", accountID).Scan(&dashboard.TotalCapital)
+    result, err := place(c.Request.Context(), payload)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Account not found")
         return
     }
+    c.JSON(http.StatusOK, result)
+}
 
-    if dashboard.TotalCapital > 0 {
-        dashboard.AverageYield = dashboard.TotalNetPremiums / dashboard.TotalCapital
+// GetDashboard aggregates the account's trades with the pnl package. Open
+// trades have no option quote, so they are marked at 0: their P&L is the
+// credit currently held.
+func (h *TradeHandler) GetDashboard(c *gin.Context) {
+    var dashboard models.Dashboard
+
+    accountIDStr := c.Query("account_id")
+    if accountIDStr == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "account_id is required"})
+        return
     }
 
-    err = h.db.QueryRow("SELECT IFNULL(SUM(premium_per_share * contracts), 0) FROM trades WHERE account_id = ? AND status = 'OPEN'", accountID).Scan(&dashboard.OpenTradesNetPremium)
+    accountID, err := strconv.Atoi(accountIDStr)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+        return
+    }
+
+    trades, err := h.trades.List(c.Request.Context(), repository.TradeFilter{AccountID: accountID})
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
+    var wins int
+    for _, t := range trades {
+        dashboard.TotalTrades++
+        dashboard.TotalNetPremiums += pnl.Premium(t) - t.Fees
+        dashboard.TotalCapital += pnl.Capital(t)
+
+        if t.Status == "CLOSED" {
+            dashboard.ClosedTrades++
+            realized := pnl.Realized(t)
+            dashboard.ClosedPositionsPL += realized
+            if realized > 0 {
+                wins++
+            }
+            continue
+        }
+        dashboard.OpenTrades++
+        dashboard.OpenPositionsPL += pnl.Unrealized(t, 0)
+        dashboard.OpenTradesCapital += pnl.Capital(t)
+        dashboard.OpenTradesNetPremium += pnl.Premium(t) - t.Fees
+    }
+
+    if dashboard.ClosedTrades > 0 {
+        dashboard.WinRate = float64(wins) / float64(dashboard.ClosedTrades)
+    }
+    dashboard.TotalPL = dashboard.OpenPositionsPL + dashboard.ClosedPositionsPL
+    if dashboard.TotalCapital > 0 {
+        dashboard.AverageYield = dashboard.TotalNetPremiums / dashboard.TotalCapital
+    }
     dashboard.PremiumCollected = dashboard.TotalNetPremiums
 
     c.JSON(http.StatusOK, dashboard)
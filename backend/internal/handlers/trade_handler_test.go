@@ -0,0 +1,130 @@
+package handlers
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/wheel-tracker/backend/internal/database"
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/services"
+)
+
+// newTestRouter serves the trade routes over a migrated and seeded database
+// in a temporary directory; account 1 starts with 10000 in cash
+func newTestRouter(t *testing.T) *gin.Engine {
+    t.Helper()
+    db, err := database.NewDB(filepath.Join(t.TempDir(), "trades.db"))
+    if err != nil {
+        t.Fatalf("open database: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+
+    gin.SetMode(gin.TestMode)
+    h := NewTradeHandler(db.DB, services.NewTradeService(db))
+    router := gin.New()
+    router.GET("/trades/:id", h.GetTrade)
+    router.POST("/trades", h.CreateTrade)
+    router.PUT("/trades/:id", h.UpdateTrade)
+    return router
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest(method, path, strings.NewReader(body))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    router.ServeHTTP(w, req)
+    return w
+}
+
+const testPut = `"symbol":"ABC","trade_type":"CSP","contracts":1,"strike_price":50,"premium_per_share":1,` +
+    `"open_date":"2026-01-05","expiration_date":"2026-02-20"`
+
+func TestCreateTrade(t *testing.T) {
+    tests := []struct {
+        name   string
+        path   string
+        body   string
+        status int
+        error  string
+    }{
+        {"opened", "/trades", `{"account_id":1,` + testPut + `}`, http.StatusCreated, ""},
+        {"lowercase symbol", "/trades", `{"account_id":1,` + strings.Replace(testPut, "ABC", "abc", 1) + `}`,
+            http.StatusBadRequest, "Symbol must contain only uppercase letters (A-Z)"},
+        {"no contracts", "/trades", `{"account_id":1,` + strings.Replace(testPut, `"contracts":1`, `"contracts":0`, 1) + `}`,
+            http.StatusBadRequest, "Contracts must be a positive integer"},
+        {"expires before it opens", "/trades", `{"account_id":1,` + strings.Replace(testPut, "2026-02-20", "2025-12-19", 1) + `}`,
+            http.StatusBadRequest, "Expiration date must be equal or after open date"},
+        {"missing account", "/trades", `{"account_id":9,` + testPut + `}`,
+            http.StatusBadRequest, "Associated account does not exist or is not active"},
+        {"missing wheel", "/trades", `{"account_id":1,"wheel_id":9,` + testPut + `}`,
+            http.StatusBadRequest, "wheel 9 not found"},
+        {"missing roll parent", "/trades", `{"account_id":1,"parent_trade_id":9,` + testPut + `}`,
+            http.StatusBadRequest, "parent trade 9 not found"},
+        {"past buying power", "/trades", `{"account_id":1,` + strings.Replace(testPut, `"contracts":1`, `"contracts":3`, 1) + `}`,
+            http.StatusBadRequest, "Insufficient buying power. Available: 10000.00, Required: 15000.00"},
+        {"past buying power with allow_shortfall", "/trades?allow_shortfall=true",
+            `{"account_id":1,` + strings.Replace(testPut, `"contracts":1`, `"contracts":3`, 1) + `}`, http.StatusCreated, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := serve(newTestRouter(t), http.MethodPost, tt.path, tt.body)
+            if w.Code != tt.status {
+                t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+            }
+            if tt.error != "" {
+                var resp struct{ Error string }
+                if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error != tt.error {
+                    t.Errorf("error = %q, want %q", resp.Error, tt.error)
+                }
+                return
+            }
+            var trade models.Trade
+            if err := json.Unmarshal(w.Body.Bytes(), &trade); err != nil {
+                t.Fatalf("decode trade: %v", err)
+            }
+            // The body is the trade as stored
+            if trade.TradeID == 0 || trade.CreatedAt.IsZero() || trade.WheelID == nil {
+                t.Errorf("trade = %+v, want its id, created_at and wheel", trade)
+            }
+        })
+    }
+}
+
+func TestUpdateTrade(t *testing.T) {
+    router := newTestRouter(t)
+    if w := serve(router, http.MethodPost, "/trades", `{"account_id":1,`+testPut+`}`); w.Code != http.StatusCreated {
+        t.Fatalf("open trade: %d %s", w.Code, w.Body)
+    }
+
+    tests := []struct {
+        name     string
+        path     string
+        body     string
+        status   int
+        warnings int
+    }{
+        {"missing trade", "/trades/9", `{` + testPut + `}`, http.StatusNotFound, 0},
+        {"bad id", "/trades/abc", `{` + testPut + `}`, http.StatusBadRequest, 0},
+        {"within buying power", "/trades/1", `{` + strings.Replace(testPut, `"strike_price":50`, `"strike_price":60`, 1) + `}`, http.StatusOK, 0},
+        {"past buying power", "/trades/1", `{` + strings.Replace(testPut, `"contracts":1`, `"contracts":3`, 1) + `}`, http.StatusBadRequest, 0},
+        {"past buying power with allow_shortfall", "/trades/1?allow_shortfall=true",
+            `{` + strings.Replace(testPut, `"contracts":1`, `"contracts":3`, 1) + `}`, http.StatusOK, 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            w := serve(router, http.MethodPut, tt.path, tt.body)
+            if w.Code != tt.status {
+                t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+            }
+            var resp struct{ Warnings []string }
+            if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Warnings) != tt.warnings {
+                t.Errorf("warnings = %v, want %d", resp.Warnings, tt.warnings)
+            }
+        })
+    }
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/wheel-tracker/backend/internal/models"
+	"github.com/wheel-tracker/backend/internal/pnl"
 	"github.com/wheel-tracker/backend/internal/services"
 )
 
@@ -69,19 +70,15 @@ func (h *TradeImportHandler) Import(c *gin.Context) {
 			continue
 		}
 
-		// Lógica para marcar trades abiertos según expiration_date y close fields
-		expired, err := isExpired(trade.ExpirationDate)
-		if err != nil {
+		if _, err := isExpired(trade.ExpirationDate); err != nil {
 			parseErrors = append(parseErrors, fmt.Sprintf("Line %d: invalid expiration_date: %v", lineNum, err))
 			continue
 		}
 
-		// Si no está expirado y no tiene close_date ni close_price, status Open
-		if !expired && (trade.CloseDate == nil || *trade.CloseDate == "") && trade.ClosePrice == nil {
+		// Sin close_date ni close_price el trade queda OPEN; si ya venció,
+		// el job de expiraciones lo cierra o lo marca para revisión
+		if (trade.CloseDate == nil || *trade.CloseDate == "") && trade.ClosePrice == nil {
 			trade.Status = "OPEN"
-		} else if expired && (trade.CloseDate == nil || *trade.CloseDate == "") {
-			// Si expiró pero no tiene close_date, marcar status Expired
-			trade.Status = "Expired"
 		}
 
 		// Auto-set close_method a BTC si close_price existe pero no close_method
@@ -101,7 +98,9 @@ func (h *TradeImportHandler) Import(c *gin.Context) {
 		return
 	}
 
-	importedCount, transactionErrors, err := h.TradeService.SaveTradesTransaction(trades)
+	// Con allow_shortfall=true se importan aunque superen el buying power
+	allowShortfall := c.Query("allow_shortfall") == "true"
+	importedCount, transactionErrors, err := h.TradeService.SaveTradesTransaction(c.Request.Context(), trades, allowShortfall)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message":            err.Error(),
@@ -272,6 +271,137 @@ func (h *TradeImportHandler) parseTradeRecord(record []string, headers []string)
 	return trade, nil
 }
 
+// ImportExecutions agrega fills a trades existentes desde CSV; cada fila es
+// una apertura o cierre parcial de un trade ya guardado
+func (h *TradeImportHandler) ImportExecutions(c *gin.Context) {
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get file"})
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	headers, err := reader.Read()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read CSV headers"})
+		return
+	}
+
+	requiredHeaders := []string{"trade_id", "action", "contracts", "price"}
+	if !validateHeaders(headers, requiredHeaders) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("CSV headers mismatch. Required: %v", requiredHeaders),
+		})
+		return
+	}
+
+	var rows []services.ExecutionImport
+	var parseErrors []string
+	lineNum := 1
+
+	for {
+		lineNum++
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Sprintf("Line %d: %v", lineNum, err))
+			continue
+		}
+
+		row, err := parseExecutionRecord(record, headers)
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Sprintf("Line %d: %v", lineNum, err))
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":        "No valid executions found in CSV",
+			"parse_errors": parseErrors,
+		})
+		return
+	}
+
+	// Con allow_shortfall=true se importan aunque superen el buying power
+	allowShortfall := c.Query("allow_shortfall") == "true"
+	importedCount, transactionErrors, err := h.TradeService.ImportExecutions(c.Request.Context(), rows, allowShortfall)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message":            err.Error(),
+			"imported_count":     importedCount,
+			"transaction_errors": transactionErrors,
+			"parse_errors":       parseErrors,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Executions imported successfully",
+		"imported_count":     importedCount,
+		"total_attempted":    len(rows),
+		"transaction_errors": transactionErrors,
+		"parse_errors":       parseErrors,
+	})
+}
+
+func parseExecutionRecord(record []string, headers []string) (services.ExecutionImport, error) {
+	var row services.ExecutionImport
+
+	headerMap := make(map[string]int)
+	for i, header := range headers {
+		headerMap[header] = i
+	}
+	field := func(name string) string {
+		if idx, ok := headerMap[name]; ok && idx < len(record) {
+			return record[idx]
+		}
+		return ""
+	}
+
+	tradeID, err := strconv.Atoi(field("trade_id"))
+	if err != nil {
+		return row, fmt.Errorf("invalid trade_id: %v", err)
+	}
+	row.TradeID = tradeID
+
+	row.Action = field("action")
+	if row.Action == "" {
+		return row, fmt.Errorf("action cannot be empty")
+	}
+
+	contracts, err := strconv.Atoi(field("contracts"))
+	if err != nil {
+		return row, fmt.Errorf("invalid contracts: %v", err)
+	}
+	row.Contracts = contracts
+
+	price, err := strconv.ParseFloat(field("price"), 64)
+	if err != nil {
+		return row, fmt.Errorf("invalid price: %v", err)
+	}
+	row.Price = price
+
+	// campos opcionales
+	if value := field("fees"); value != "" {
+		fees, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return row, fmt.Errorf("invalid fees: %v", err)
+		}
+		row.Fees = fees
+	}
+	row.ExecutedAt = field("executed_at")
+
+	return row, nil
+}
+
 // ValidateCSV parsea el CSV para retorno de validación sin guardar
 func (h *TradeImportHandler) ValidateCSV(c *gin.Context) {
 	file, _, err := c.Request.FormFile("file")
@@ -328,17 +458,14 @@ func (h *TradeImportHandler) ValidateCSV(c *gin.Context) {
 			continue
 		}
 
-		expired, err := isExpired(trade.ExpirationDate)
-		if err != nil {
+		if _, err := isExpired(trade.ExpirationDate); err != nil {
 			parseErrors = append(parseErrors, fmt.Sprintf("Line %d: invalid expiration_date: %v", lineNum, err))
 			continue
 		}
 
-		// Lógica para status
-		if !expired && (trade.CloseDate == nil || *trade.CloseDate == "") && trade.ClosePrice == nil {
+		// Lógica para status; los vencidos los resuelve el job de expiraciones
+		if (trade.CloseDate == nil || *trade.CloseDate == "") && trade.ClosePrice == nil {
 			trade.Status = "OPEN"
-		} else if expired && (trade.CloseDate == nil || *trade.CloseDate == "") {
-			trade.Status = "Expired"
 		}
 
 		// Auto-set close_method si close_price existe
@@ -350,7 +477,7 @@ func (h *TradeImportHandler) ValidateCSV(c *gin.Context) {
 		// Calcular P/L según tipo de trade
 		var pl *float64
 		if trade.ClosePrice != nil {
-			plValue := calculateProfitLoss(trade)
+			plValue := pnl.Realized(trade)
 			pl = &plValue
 		}
 
@@ -391,17 +518,6 @@ func (h *TradeImportHandler) ValidateCSV(c *gin.Context) {
 	})
 }
 
-func calculateProfitLoss(trade models.Trade) float64 {
-	// Para opciones PUT y CALL, considerar contracts en el cálculo
-	switch trade.TradeType {
-	case "PUT", "CALL":
-		return (trade.PremiumPerShare - *trade.ClosePrice) * float64(trade.Contracts)
-	default:
-		// Para otros tipos, calculo simple
-		return trade.PremiumPerShare - *trade.ClosePrice
-	}
-}
-
 // ConfirmImport guarda los trades confirmados en base de datos
 func (h *TradeImportHandler) ConfirmImport(c *gin.Context) {
 	var req struct {
@@ -418,7 +534,9 @@ func (h *TradeImportHandler) ConfirmImport(c *gin.Context) {
 		return
 	}
 
-	importedCount, transactionErrors, err := h.TradeService.SaveTradesTransaction(req.Trades)
+	// Con allow_shortfall=true se importan aunque superen el buying power
+	allowShortfall := c.Query("allow_shortfall") == "true"
+	importedCount, transactionErrors, err := h.TradeService.SaveTradesTransaction(c.Request.Context(), req.Trades, allowShortfall)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message":            err.Error(),
@@ -2,132 +2,103 @@ package handlers
 
 import (
     "database/sql"
+    "errors"
+    "io"
     "net/http"
+    "strconv"
 
     "github.com/gin-gonic/gin"
     "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+    "github.com/wheel-tracker/backend/internal/services"
 )
 
 type WheelHandler struct {
-    db *sql.DB
+    db        *sql.DB
+    service   *services.TradeService
+    wheels    *repository.WheelRepo
+    trades    *repository.TradeRepo
+    positions *repository.PositionRepo
 }
 
-func NewWheelHandler(db *sql.DB) *WheelHandler {
-    return &WheelHandler{db: db}
+func NewWheelHandler(db *sql.DB, service *services.TradeService) *WheelHandler {
+    return &WheelHandler{
+        db:        db,
+        service:   service,
+        wheels:    repository.NewWheelRepo(db),
+        trades:    repository.NewTradeRepo(db),
+        positions: repository.NewPositionRepo(db),
+    }
 }
 
+// ListWheels returns the wheels with the given status and their return
+// metrics. sort orders them by a metric, highest first unless order=asc.
 func (h *WheelHandler) ListWheels(c *gin.Context) {
     status := c.DefaultQuery("status", "ACTIVE")
+    ctx := c.Request.Context()
 
-    rows, err := h.db.Query(`
-        SELECT wheel_id, account_id, symbol, start_date, end_date, status,
-               current_phase, total_premium, total_pnl, created_at, updated_at
-        FROM wheels WHERE status = ?
-        ORDER BY start_date DESC
-    `, status)
+    wheels, err := h.wheels.List(ctx, status)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
-    defer rows.Close()
 
-    var wheels []models.Wheel
-    for rows.Next() {
-        var w models.Wheel
-        err := rows.Scan(&w.WheelID, &w.AccountID, &w.Symbol, &w.StartDate,
-            &w.EndDate, &w.Status, &w.CurrentPhase, &w.TotalPremium,
-            &w.TotalPnL, &w.CreatedAt, &w.UpdatedAt)
-        if err != nil {
-            continue
-        }
-        wheels = append(wheels, w)
+    if err := h.service.AnnotateWheels(ctx, wheels); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if err := services.SortWheels(wheels, c.Query("sort"), c.Query("order") == "asc"); err != nil {
+        respondError(c, err, "Wheel not found")
+        return
     }
 
     c.JSON(http.StatusOK, wheels)
 }
 
 func (h *WheelHandler) GetWheel(c *gin.Context) {
-    id := c.Param("id")
-    
-    var w models.Wheel
-    err := h.db.QueryRow(`
-        SELECT wheel_id, account_id, symbol, start_date, end_date, status,
-               current_phase, total_premium, total_pnl, created_at, updated_at
-        FROM wheels WHERE wheel_id = ?
-    `, id).Scan(&w.WheelID, &w.AccountID, &w.Symbol, &w.StartDate,
-        &w.EndDate, &w.Status, &w.CurrentPhase, &w.TotalPremium,
-        &w.TotalPnL, &w.CreatedAt, &w.UpdatedAt)
-    
-    if err == sql.ErrNoRows {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Wheel not found"})
+    id, ok := paramID(c)
+    if !ok {
         return
     }
+    ctx := c.Request.Context()
+
+    w, err := h.wheels.Get(ctx, id)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        respondError(c, err, "Wheel not found")
         return
     }
 
     // Get associated trades
-    tradeRows, _ := h.db.Query(`
-        SELECT trade_id, symbol, trade_type, contracts, strike_price, premium_per_share,
-               open_date, expiration_date, status
-        FROM trades WHERE wheel_id = ?
-        ORDER BY open_date
-    `, id)
-    defer tradeRows.Close()
-
-    var trades []map[string]interface{}
-    for tradeRows.Next() {
-        var tradeID int
-        var symbol, tradeType, openDate, expirationDate, status string
-        var contracts int
-        var strikePrice, premiumPerShare float64
-        
-        tradeRows.Scan(&tradeID, &symbol, &tradeType, &contracts, &strikePrice,
-            &premiumPerShare, &openDate, &expirationDate, &status)
-        
-        trades = append(trades, map[string]interface{}{
-            "trade_id":          tradeID,
-            "symbol":            symbol,
-            "trade_type":        tradeType,
-            "contracts":         contracts,
-            "strike_price":      strikePrice,
-            "premium_per_share": premiumPerShare,
-            "open_date":         openDate,
-            "expiration_date":   expirationDate,
-            "status":            status,
-        })
+    trades, err := h.trades.List(ctx, repository.TradeFilter{WheelID: id})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
     }
 
     // Get associated positions
-    posRows, _ := h.db.Query(`
-        SELECT position_id, symbol, shares, cost_basis_per_share, acquired_date, status
-        FROM positions WHERE wheel_id = ?
-    `, id)
-    defer posRows.Close()
-
-    var positions []map[string]interface{}
-    for posRows.Next() {
-        var posID, shares int
-        var symbol, acquiredDate, status string
-        var costBasis float64
-        
-        posRows.Scan(&posID, &symbol, &shares, &costBasis, &acquiredDate, &status)
-        
-        positions = append(positions, map[string]interface{}{
-            "position_id":         posID,
-            "symbol":              symbol,
-            "shares":              shares,
-            "cost_basis_per_share": costBasis,
-            "acquired_date":       acquiredDate,
-            "status":              status,
-        })
+    positions, err := h.positions.List(ctx, repository.PositionFilter{WheelID: id})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    if err := h.service.AnnotatePositions(ctx, positions); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    annotated := []models.Wheel{w}
+    if err := h.service.AnnotateWheels(ctx, annotated); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
     }
 
     response := gin.H{
-        "wheel":     w,
+        "wheel":     annotated[0],
         "trades":    trades,
         "positions": positions,
+        "basis":     pnl.WheelBasis(trades, positions),
     }
 
     c.JSON(http.StatusOK, response)
@@ -140,40 +111,151 @@ func (h *WheelHandler) CreateWheel(c *gin.Context) {
         return
     }
 
-    result, err := h.db.Exec(`
-        INSERT INTO wheels (account_id, symbol, start_date, status, current_phase, total_premium, total_pnl)
-        VALUES (?, ?, ?, 'ACTIVE', 'CSP', 0.0, 0.0)
-    `, w.AccountID, w.Symbol, w.StartDate)
-    
-    if err != nil {
+    if err := h.wheels.Create(c.Request.Context(), &w); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    id, _ := result.LastInsertId()
-    w.WheelID = int(id)
     c.JSON(http.StatusCreated, w)
 }
 
 func (h *WheelHandler) UpdateWheel(c *gin.Context) {
-    id := c.Param("id")
-    var w models.Wheel
-    
-    if err := c.ShouldBindJSON(&w); err != nil {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+    var u services.WheelUpdate
+
+    if err := c.ShouldBindJSON(&u); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    w, err := h.service.UpdateWheel(c.Request.Context(), id, u)
+    if err != nil {
+        respondError(c, err, "Wheel not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Wheel updated successfully", "wheel": w})
+}
+
+// RecomputeWheel rebuilds the wheel's phase and totals from its trades and positions
+func (h *WheelHandler) RecomputeWheel(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    w, err := h.service.RecomputeWheel(c.Request.Context(), id)
+    if err != nil {
+        respondError(c, err, "Wheel not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, w)
+}
+
+// BackfillWheels links every trade without a wheel, reconstructing wheels for history
+func (h *WheelHandler) BackfillWheels(c *gin.Context) {
+    result, err := h.service.BackfillWheels(c.Request.Context())
+    if err != nil {
+        respondError(c, err, "Wheel not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, result)
+}
+
+// GetBreakevenHistory returns the wheel's breakeven after each trade and lot,
+// for picking covered call strikes above it
+func (h *WheelHandler) GetBreakevenHistory(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    history, err := h.service.BreakevenHistory(c.Request.Context(), id)
+    if err != nil {
+        respondError(c, err, "Wheel not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, history)
+}
+
+// GetTimeline returns the wheel's trades, lots and dividends in date order
+// with running premium and P&L
+func (h *WheelHandler) GetTimeline(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    events, err := h.service.WheelTimeline(c.Request.Context(), id)
+    if err != nil {
+        respondError(c, err, "Wheel not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, events)
+}
+
+// CloseWheel archives a finished wheel and returns its final performance.
+// The body is optional and may carry an end_date.
+func (h *WheelHandler) CloseWheel(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+    var req services.WheelCloseRequest
+    if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    _, err := h.db.Exec(`
-        UPDATE wheels 
-        SET current_phase = ?, total_premium = ?, total_pnl = ?, status = ?, updated_at = CURRENT_TIMESTAMP
-        WHERE wheel_id = ?
-    `, w.CurrentPhase, w.TotalPremium, w.TotalPnL, w.Status, id)
-    
+    summary, err := h.service.CloseWheel(c.Request.Context(), id, req)
+    if err != nil {
+        respondError(c, err, "Wheel not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Wheel closed", "summary": summary})
+}
+
+// GetSummary returns the performance snapshot taken when the wheel was closed
+func (h *WheelHandler) GetSummary(c *gin.Context) {
+    id, ok := paramID(c)
+    if !ok {
+        return
+    }
+
+    summary, err := h.service.WheelSummary(c.Request.Context(), id)
+    if err != nil {
+        respondError(c, err, "Wheel summary not found")
+        return
+    }
+
+    c.JSON(http.StatusOK, summary)
+}
+
+// ListSummaries compares closed wheels, optionally filtered by account_id and symbol
+func (h *WheelHandler) ListSummaries(c *gin.Context) {
+    filter := repository.WheelSummaryFilter{Symbol: c.Query("symbol")}
+    if accountID := c.Query("account_id"); accountID != "" {
+        id, err := strconv.Atoi(accountID)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+            return
+        }
+        filter.AccountID = id
+    }
+
+    summaries, err := h.service.ListWheelSummaries(c.Request.Context(), filter)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-    c.JSON(http.StatusOK, gin.H{"message": "Wheel updated successfully"})
+    c.JSON(http.StatusOK, summaries)
 }
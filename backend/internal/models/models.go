@@ -9,36 +9,104 @@ type Account struct {
     Broker          string    `json:"broker"`
     Currency        string    `json:"currency"`
     InitialBalance  float64   `json:"initial_balance"`
-    CurrentBalance  float64   `json:"current_balance"`
+    CurrentBalance  float64   `json:"current_balance"` // initial_balance más el libro de caja; no se edita
     IsActive        bool      `json:"is_active"`
     AccountType     string    `json:"account_type"`      // Nuevo campo tipo de cuenta: "cash" o "margin"
     MarginMultiplier float64   `json:"margin_multiplier"` // Nuevo campo multiplicador de margen, default 1.0
+    LotMethod       string    `json:"lot_method"`        // FIFO, LIFO, HIFO o SPECIFIC: qué lotes consume una venta
     CreatedAt       time.Time `json:"created_at"`
     UpdatedAt       time.Time `json:"updated_at"`
 }
 
+// AccountTransaction is an entry of the account's cash ledger. Amount is
+// signed: positive moves cash in, negative moves it out, and the type names
+// the other side of the entry. Trade and income entries point back at the
+// row they were posted for.
+type AccountTransaction struct {
+    TransactionID   int     `json:"transaction_id"`
+    AccountID       int     `json:"account_id"`
+    TransactionType string  `json:"transaction_type"`
+    Amount          float64 `json:"amount"`
+    TransactionDate string  `json:"transaction_date"`
+    Notes           string  `json:"notes"`
+    TradeID         *int    `json:"trade_id,omitempty"`
+    IncomeID        *int    `json:"income_id,omitempty"`
+    PositionID      *int    `json:"position_id,omitempty"` // lote comprado o vendido
+}
+
 // Trade represents an options trade
 type Trade struct {
-    TradeID         int        `json:"trade_id"`
-    AccountID       int        `json:"account_id"`
-    Symbol          string     `json:"symbol"`
-    TradeType       string     `json:"trade_type"`
-    Contracts       int        `json:"contracts"`
-    StrikePrice     float64    `json:"strike_price"`
-    PremiumPerShare float64    `json:"premium_per_share"`
-    Delta           *float64   `json:"delta,omitempty"`         // <--- CAMPO NUEVO
-    OpenDate        string     `json:"open_date"`
-    ExpirationDate  string     `json:"expiration_date"`
-    CloseDate       *string    `json:"close_date,omitempty"`
-    CloseMethod     *string    `json:"close_method,omitempty"`
-    ClosePrice      *float64   `json:"close_price,omitempty"`
-    Fees            float64    `json:"fees"`
-    Status          string     `json:"status"`
-    Tags            *string    `json:"tags,omitempty"`
-    Notes           *string    `json:"notes,omitempty"`
-    WheelID         *int       `json:"wheel_id,omitempty"`
-    CreatedAt       time.Time  `json:"created_at"`
-    UpdatedAt       time.Time  `json:"updated_at"`
+    TradeID          int       `json:"trade_id"`
+    AccountID        int       `json:"account_id"`
+    Symbol           string    `json:"symbol"`
+    TradeType        string    `json:"trade_type"`
+    Contracts        int       `json:"contracts"`
+    StrikePrice      float64   `json:"strike_price"`
+    PremiumPerShare  float64   `json:"premium_per_share"`
+    Delta            *float64  `json:"delta,omitempty"`               // <--- CAMPO NUEVO
+    OpenDate         string    `json:"open_date"`
+    ExpirationDate   string    `json:"expiration_date"`
+    CloseDate        *string   `json:"close_date,omitempty"`
+    CloseMethod      *string   `json:"close_method,omitempty"`
+    ClosePrice       *float64  `json:"close_price,omitempty"`
+    Fees             float64   `json:"fees"`
+    Status           string    `json:"status"`
+    Tags             *string   `json:"tags,omitempty"`
+    Notes            *string   `json:"notes,omitempty"`
+    WheelID          *int      `json:"wheel_id,omitempty"`
+    ParentTradeID    *int      `json:"parent_trade_id,omitempty"`     // trade cerrado por el roll que abrió éste
+    RollGroup        *int      `json:"roll_group,omitempty"`          // trade_id del primer trade de la cadena de rolls
+    UnderlyingPrice  *float64  `json:"underlying_price,omitempty"`    // último precio conocido del subyacente
+    Side             string    `json:"side"`                          // STO vendida para abrir, BTO comprada para abrir
+    StrategyGroupID  *int      `json:"strategy_group_id,omitempty"`   // estrategia de varias patas a la que pertenece
+    SplitFromTradeID *int      `json:"split_from_trade_id,omitempty"` // trade del que se separaron estos contratos al cerrarlos en parte
+    Deliverable      int       `json:"deliverable"`                   // acciones que entrega cada contrato, 100 salvo tras un split
+    CreatedAt        time.Time `json:"created_at"`
+    UpdatedAt        time.Time `json:"updated_at"`
+
+    // Calculado, no se guarda: crédito neto acumulado de toda la cadena de rolls
+    RollChainNetCredit *float64 `json:"roll_chain_net_credit,omitempty"`
+    // Avisos al abrirlo: buying power superado o calls vendidas al descubierto
+    Warnings []string `json:"warnings,omitempty"`
+}
+
+// Execution is one fill of the order that opened or closed a trade. The
+// trade's contracts, average premium, close price and fees follow from its fills.
+type Execution struct {
+    ExecutionID int       `json:"execution_id"`
+    TradeID     int       `json:"trade_id"`
+    Action      string    `json:"action"` // OPEN o CLOSE
+    Contracts   int       `json:"contracts"`
+    Price       float64   `json:"price"`
+    Fees        float64   `json:"fees"`
+    ExecutedAt  string    `json:"executed_at"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+// StrategyGroup ties together the legs of a multi-leg option strategy. A
+// COLLAR also names the position whose shares it protects.
+type StrategyGroup struct {
+    GroupID    int       `json:"group_id"`
+    AccountID  int       `json:"account_id"`
+    Symbol     string    `json:"symbol"`
+    Strategy   string    `json:"strategy"`
+    PositionID *int      `json:"position_id,omitempty"`
+    OpenDate   string    `json:"open_date"`
+    CloseDate  *string   `json:"close_date,omitempty"`
+    Status     string    `json:"status"`
+    Notes      *string   `json:"notes,omitempty"`
+    CreatedAt  time.Time `json:"created_at"`
+    UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ExpirationLogEntry records what the expiration job did with a trade
+type ExpirationLogEntry struct {
+    LogID           int       `json:"log_id"`
+    TradeID         int       `json:"trade_id"`
+    Action          string    `json:"action"`
+    UnderlyingPrice *float64  `json:"underlying_price,omitempty"`
+    Message         *string   `json:"message,omitempty"`
+    CreatedAt       time.Time `json:"created_at"`
 }
 
 // Position represents a stock position
@@ -52,11 +120,107 @@ type Position struct {
     SoldDate          *string   `json:"sold_date,omitempty"`
     SoldPricePerShare *float64  `json:"sold_price_per_share,omitempty"`
     Status            string    `json:"status"`
-    IsCovered         bool      `json:"is_covered"`
+    IsCovered         bool      `json:"is_covered"` // calculado: todas sus acciones cubren calls vendidas abiertas
     WheelID           *int      `json:"wheel_id,omitempty"`
+    SourceTradeID     *int      `json:"source_trade_id,omitempty"` // trade cuya asignación creó la posición
     Notes             *string   `json:"notes,omitempty"`
+    SplitFromPositionID *int    `json:"split_from_position_id,omitempty"` // lote del que se separaron las acciones vendidas
+    SplitRatio        float64   `json:"split_ratio"`                      // acciones actuales por cada acción adquirida, según los splits aplicados
     CreatedAt         time.Time `json:"created_at"`
     UpdatedAt         time.Time `json:"updated_at"`
+
+    // Calculados, no se guardan: base de coste neta de las primas de la rueda
+    RawBasisPerShare      *float64 `json:"raw_basis_per_share,omitempty"`
+    AdjustedBasisPerShare *float64 `json:"adjusted_basis_per_share,omitempty"`
+    BreakevenPerShare     *float64 `json:"breakeven_per_share,omitempty"`
+
+    // Calculados: acciones comprometidas por las calls vendidas abiertas de la cuenta y el símbolo
+    CoveredShares   *int `json:"covered_shares,omitempty"`
+    UncoveredShares *int `json:"uncovered_shares,omitempty"`
+
+    // Calculados: pérdidas diferidas por wash sale sumadas a la base del lote
+    WashSaleAdjustment *float64   `json:"wash_sale_adjustment,omitempty"`
+    WashSales          []WashSale `json:"wash_sales,omitempty"`
+}
+
+// WashSale is a loss disallowed because the same symbol was bought again, as
+// shares, a sold put or a bought call, within 30 days of the sale. Shares of
+// the loss are matched to the replacement, whose cost basis absorbs the
+// disallowed part. Neither is stored; both are replayed from the trades and
+// lot sales. LossLotID is the lot a stock loss came from.
+type WashSale struct {
+    Symbol            string  `json:"symbol"`
+    LossSource        string  `json:"loss_source"` // lot_sale o trade
+    LossSourceID      int     `json:"loss_source_id"`
+    LossLotID         *int    `json:"loss_lot_id,omitempty"`
+    LossDate          string  `json:"loss_date"`
+    Loss              float64 `json:"loss"`
+    Shares            int     `json:"shares"`
+    Disallowed        float64 `json:"disallowed"`
+    ReplacementSource string  `json:"replacement_source"` // position o trade
+    ReplacementID     int     `json:"replacement_id"`
+    ReplacementDate   string  `json:"replacement_date"`
+}
+
+// LotSale is the realized gain of the shares a sale took from one lot.
+// LotID is the lot they came from and PositionID the closed row that holds
+// them, the same row when the whole lot was sold. Fees of the sale are shared
+// between its lots by shares and already taken off RealizedPnL. Term is LONG
+// when the shares were held for more than a year. TradeID is the assigned
+// call that sold them, if any.
+type LotSale struct {
+    SaleID            int       `json:"sale_id"`
+    AccountID         int       `json:"account_id"`
+    Symbol            string    `json:"symbol"`
+    LotID             int       `json:"lot_id"`
+    PositionID        int       `json:"position_id"`
+    Shares            int       `json:"shares"`
+    AcquiredDate      string    `json:"acquired_date"`
+    SoldDate          string    `json:"sold_date"`
+    CostBasisPerShare float64   `json:"cost_basis_per_share"`
+    SalePricePerShare float64   `json:"sale_price_per_share"`
+    Fees              float64   `json:"fees"`
+    RealizedPnL       float64   `json:"realized_pnl"`
+    Term              string    `json:"term"`
+    LotMethod         string    `json:"lot_method"`
+    TradeID           *int      `json:"trade_id,omitempty"`
+    CreatedAt         time.Time `json:"created_at"`
+}
+
+// CorporateAction is a split, reverse split or symbol change applied to the
+// open positions and trades of a symbol. A split turns SplitFrom old shares
+// into SplitTo new ones. Closed rows are history and never change.
+type CorporateAction struct {
+    ActionID      int       `json:"action_id"`
+    Symbol        string    `json:"symbol"`
+    ActionType    string    `json:"action_type"`
+    SplitFrom     *int      `json:"split_from,omitempty"`
+    SplitTo       *int      `json:"split_to,omitempty"`
+    NewSymbol     *string   `json:"new_symbol,omitempty"`
+    EffectiveDate string    `json:"effective_date"`
+    Notes         *string   `json:"notes,omitempty"`
+    CreatedAt     time.Time `json:"created_at"`
+
+    Adjustments []CorporateActionAdjustment `json:"adjustments,omitempty"`
+}
+
+// CorporateActionAdjustment records how an action changed one position or
+// trade. Quantity is shares or contracts and price the cost basis per share
+// or the strike; deliverable is only set for trades.
+type CorporateActionAdjustment struct {
+    AdjustmentID   int       `json:"adjustment_id"`
+    ActionID       int       `json:"action_id"`
+    PositionID     *int      `json:"position_id,omitempty"`
+    TradeID        *int      `json:"trade_id,omitempty"`
+    OldSymbol      string    `json:"old_symbol"`
+    NewSymbol      string    `json:"new_symbol"`
+    OldQuantity    int       `json:"old_quantity"`
+    NewQuantity    int       `json:"new_quantity"`
+    OldPrice       float64   `json:"old_price"`
+    NewPrice       float64   `json:"new_price"`
+    OldDeliverable *int      `json:"old_deliverable,omitempty"`
+    NewDeliverable *int      `json:"new_deliverable,omitempty"`
+    CreatedAt      time.Time `json:"created_at"`
 }
 
 // Wheel represents a complete wheel strategy cycle
@@ -72,6 +236,46 @@ type Wheel struct {
     TotalPnL     float64   `json:"total_pnl"`
     CreatedAt    time.Time `json:"created_at"`
     UpdatedAt    time.Time `json:"updated_at"`
+
+    // Calculados, no se guardan: rentabilidad sobre el capital comprometido
+    DaysActive       *int     `json:"days_active,omitempty"`
+    CapitalAtRisk    *float64 `json:"capital_at_risk,omitempty"`
+    ReturnOnCapital  *float64 `json:"return_on_capital,omitempty"`
+    AnnualizedReturn *float64 `json:"annualized_return,omitempty"`
+    PremiumPerDay    *float64 `json:"premium_per_day,omitempty"`
+}
+
+// WheelSummary is the performance snapshot stored when a wheel is closed, kept
+// so finished wheels can be compared. Returns are fractions.
+type WheelSummary struct {
+    WheelID          int       `json:"wheel_id"`
+    AccountID        int       `json:"account_id"`
+    Symbol           string    `json:"symbol"`
+    StartDate        string    `json:"start_date"`
+    EndDate          string    `json:"end_date"`
+    DaysHeld         int       `json:"days_held"`
+    TradeCount       int       `json:"trade_count"`
+    TotalPremium     float64   `json:"total_premium"`
+    RealizedPnL      float64   `json:"realized_pnl"`
+    CapitalAtRisk    float64   `json:"capital_at_risk"`
+    ReturnOnCapital  float64   `json:"return_on_capital"`
+    AnnualizedReturn float64   `json:"annualized_return"`
+    CreatedAt        time.Time `json:"created_at"`
+}
+
+// WheelEvent is one step of a wheel's timeline. Amount is the cash the step
+// moved; the running figures and days in cycle are as of the step.
+type WheelEvent struct {
+    Date           string  `json:"date"`
+    Type           string  `json:"type"` // CSP_OPENED, CC_SOLD, ROLLED, BOUGHT_BACK, EXPIRED, ASSIGNED, CALLED_AWAY, SHARES_BOUGHT, SHARES_SOLD, DIVIDEND
+    Description    string  `json:"description"`
+    TradeID        *int    `json:"trade_id,omitempty"`
+    PositionID     *int    `json:"position_id,omitempty"`
+    IncomeID       *int    `json:"income_id,omitempty"`
+    Amount         float64 `json:"amount"`
+    RunningPremium float64 `json:"running_premium"`
+    RunningPnL     float64 `json:"running_pnl"`
+    DaysInCycle    int     `json:"days_in_cycle"`
 }
 
 // Income represents dividends and other income
@@ -0,0 +1,162 @@
+package pnl
+
+import (
+    "sort"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+// Basis is the per-share cost picture of the shares a wheel still holds.
+// RawBasis is what was paid for them, AdjustedBasis nets off every option
+// credit of the cycle, and Breakeven also absorbs stock gains or losses
+// already realized on shares sold earlier in the cycle. Per-share values
+// are nil while the wheel holds no shares.
+type Basis struct {
+    Shares           int      `json:"shares"`
+    NetOptionCredit  float64  `json:"net_option_credit"`
+    RealizedStockPnL float64  `json:"realized_stock_pnl"`
+    RawBasis         *float64 `json:"raw_basis_per_share,omitempty"`
+    AdjustedBasis    *float64 `json:"adjusted_basis_per_share,omitempty"`
+    Breakeven        *float64 `json:"breakeven_per_share,omitempty"`
+    CreditPerShare   *float64 `json:"credit_per_share,omitempty"`
+    RealizedPerShare *float64 `json:"realized_per_share,omitempty"`
+}
+
+// BreakevenPoint is the basis of a wheel right after one ledger event
+type BreakevenPoint struct {
+    Date  string `json:"date"`
+    Event string `json:"event"`
+    Basis
+}
+
+// RawBasis is the purchase price per share of a lot. Shares put to us are
+// valued at the strike, since a premium folded into cost_basis_per_share on
+// assignment is already counted as option credit. A split since then spreads
+// the strike over the lot's split ratio.
+func RawBasis(p models.Position, strikes map[int]float64) float64 {
+    if p.SourceTradeID != nil {
+        if strike, ok := strikes[*p.SourceTradeID]; ok {
+            if p.SplitRatio > 0 {
+                return strike / p.SplitRatio
+            }
+            return strike
+        }
+    }
+    return p.CostBasisPerShare
+}
+
+// Strikes maps trade ids to the strike per share delivered for RawBasis
+func Strikes(trades []models.Trade) map[int]float64 {
+    strikes := make(map[int]float64, len(trades))
+    for _, t := range trades {
+        strikes[t.TradeID] = Capital(t) / float64(Shares(t))
+    }
+    return strikes
+}
+
+// WheelBasis computes the current basis of a wheel from all of its trades and positions
+func WheelBasis(trades []models.Trade, positions []models.Position) Basis {
+    strikes := Strikes(trades)
+    var b Basis
+    var cost float64
+    for _, t := range trades {
+        b.NetOptionCredit += NetCredit(t)
+    }
+    for _, p := range positions {
+        raw := RawBasis(p, strikes)
+        if p.Status == "OPEN" {
+            b.Shares += p.Shares
+            cost += raw * float64(p.Shares)
+        } else if p.SoldPricePerShare != nil {
+            b.RealizedStockPnL += Stock(p.Shares, raw, *p.SoldPricePerShare)
+        }
+    }
+    b.fill(cost)
+    return b
+}
+
+// fill derives the per-share figures from the open cost
+func (b *Basis) fill(cost float64) {
+    b.RawBasis, b.AdjustedBasis, b.Breakeven = nil, nil, nil
+    b.CreditPerShare, b.RealizedPerShare = nil, nil
+    if b.Shares <= 0 {
+        return
+    }
+    shares := float64(b.Shares)
+    raw := cost / shares
+    credit := b.NetOptionCredit / shares
+    realized := b.RealizedStockPnL / shares
+    adjusted := raw - credit
+    breakeven := adjusted - realized
+    b.RawBasis, b.AdjustedBasis, b.Breakeven = &raw, &adjusted, &breakeven
+    b.CreditPerShare, b.RealizedPerShare = &credit, &realized
+}
+
+// BreakevenHistory replays a wheel's ledger in date order and records the
+// basis after every option opened or closed and every lot bought or sold
+func BreakevenHistory(trades []models.Trade, positions []models.Position) []BreakevenPoint {
+    type event struct {
+        date  string
+        order int
+        label string
+        apply func(b *Basis, cost *float64)
+    }
+    strikes := Strikes(trades)
+    var events []event
+
+    for _, t := range trades {
+        t := t
+        events = append(events, event{dateOnly(t.OpenDate), 0, "Sold " + t.TradeType, func(b *Basis, _ *float64) {
+            b.NetOptionCredit += Unrealized(t, 0)
+        }})
+        if t.Status == "CLOSED" && t.CloseDate != nil {
+            label := "Closed " + t.TradeType
+            if t.CloseMethod != nil {
+                label += " by " + *t.CloseMethod
+            }
+            events = append(events, event{dateOnly(*t.CloseDate), 1, label, func(b *Basis, _ *float64) {
+                b.NetOptionCredit -= ClosePrice(t) * Multiplier(t)
+            }})
+        }
+    }
+    for _, p := range positions {
+        p := p
+        raw := RawBasis(p, strikes)
+        events = append(events, event{dateOnly(p.AcquiredDate), 2, "Acquired shares", func(b *Basis, cost *float64) {
+            b.Shares += p.Shares
+            *cost += raw * float64(p.Shares)
+        }})
+        if p.Status == "CLOSED" && p.SoldDate != nil && p.SoldPricePerShare != nil {
+            events = append(events, event{dateOnly(*p.SoldDate), 3, "Sold shares", func(b *Basis, cost *float64) {
+                b.Shares -= p.Shares
+                *cost -= raw * float64(p.Shares)
+                b.RealizedStockPnL += Stock(p.Shares, raw, *p.SoldPricePerShare)
+            }})
+        }
+    }
+
+    sort.SliceStable(events, func(i, j int) bool {
+        if events[i].date != events[j].date {
+            return events[i].date < events[j].date
+        }
+        return events[i].order < events[j].order
+    })
+
+    history := make([]BreakevenPoint, 0, len(events))
+    var b Basis
+    var cost float64
+    for _, e := range events {
+        e.apply(&b, &cost)
+        b.fill(cost)
+        history = append(history, BreakevenPoint{Date: e.date, Event: e.label, Basis: b})
+    }
+    return history
+}
+
+// dateOnly trims a DATE column to YYYY-MM-DD
+func dateOnly(s string) string {
+    if len(s) > 10 {
+        return s[:10]
+    }
+    return s
+}
@@ -0,0 +1,126 @@
+package pnl
+
+import (
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+// Performance is the result of a finished wheel. CapitalAtRisk is the
+// average capital tied up per day of the wheel: the cash securing each put
+// while it was open plus the raw cost of each lot while it was held. Calls
+// are covered by the shares and add nothing. Returns are fractions and the
+// annualized return scales ReturnOnCapital linearly to 365 days.
+type Performance struct {
+    DaysHeld         int     `json:"days_held"`
+    TotalPremium     float64 `json:"total_premium"`
+    RealizedPnL      float64 `json:"realized_pnl"`
+    CapitalAtRisk    float64 `json:"capital_at_risk"`
+    ReturnOnCapital  float64 `json:"return_on_capital"`
+    AnnualizedReturn float64 `json:"annualized_return"`
+}
+
+// WheelPerformance measures a wheel from startDate to endDate. Anything still
+// open is counted as held until endDate.
+func WheelPerformance(trades []models.Trade, positions []models.Position, startDate, endDate string) Performance {
+    strikes := Strikes(trades)
+    p := Performance{DaysHeld: days(startDate, endDate)}
+    var capitalDays float64
+
+    for _, t := range trades {
+        p.TotalPremium += Premium(t)
+        if t.Status == "CLOSED" {
+            p.RealizedPnL += Realized(t)
+        }
+        if t.TradeType == "CC" || t.TradeType == "CALL" {
+            continue
+        }
+        until := endDate
+        if t.CloseDate != nil {
+            until = *t.CloseDate
+        }
+        capitalDays += Capital(t) * float64(days(t.OpenDate, until))
+    }
+
+    for _, pos := range positions {
+        raw := RawBasis(pos, strikes)
+        until := endDate
+        if pos.SoldDate != nil {
+            until = *pos.SoldDate
+        }
+        capitalDays += raw * float64(pos.Shares) * float64(days(pos.AcquiredDate, until))
+        if pos.Status == "CLOSED" && pos.SoldPricePerShare != nil {
+            p.RealizedPnL += Stock(pos.Shares, raw, *pos.SoldPricePerShare)
+        }
+    }
+
+    p.CapitalAtRisk = capitalDays / float64(p.DaysHeld)
+    if p.CapitalAtRisk > 0 {
+        p.ReturnOnCapital = p.RealizedPnL / p.CapitalAtRisk
+        p.AnnualizedReturn = p.ReturnOnCapital * 365 / float64(p.DaysHeld)
+    }
+    return p
+}
+
+// days counts the calendar days from one date to another, at least 1 so a
+// position opened and closed on the same day still ties up its capital
+func days(from, to string) int {
+    start, err1 := time.Parse("2006-01-02", dateOnly(from))
+    end, err2 := time.Parse("2006-01-02", dateOnly(to))
+    if err1 != nil || err2 != nil {
+        return 1
+    }
+    if n := int(end.Sub(start).Hours() / 24); n > 1 {
+        return n
+    }
+    return 1
+}
+
+// Metrics is how a wheel is doing so far. CapitalAtRisk is what the wheel
+// ties up now: the cash securing its open puts plus the raw cost of the
+// shares it holds. Once nothing is open it is the average capital of
+// Performance, so a finished wheel reports the same return as its summary.
+type Metrics struct {
+    DaysActive       int     `json:"days_active"`
+    CapitalAtRisk    float64 `json:"capital_at_risk"`
+    ReturnOnCapital  float64 `json:"return_on_capital"`
+    AnnualizedReturn float64 `json:"annualized_return"`
+    PremiumPerDay    float64 `json:"premium_per_day"`
+}
+
+// WheelMetrics measures a wheel from startDate to asOf, the end date of a
+// finished wheel or today for one still running
+func WheelMetrics(trades []models.Trade, positions []models.Position, startDate, asOf string) Metrics {
+    perf := WheelPerformance(trades, positions, startDate, asOf)
+    m := Metrics{
+        DaysActive:    perf.DaysHeld,
+        CapitalAtRisk: OpenCapital(trades, positions),
+        PremiumPerDay: perf.TotalPremium / float64(perf.DaysHeld),
+    }
+    if m.CapitalAtRisk == 0 {
+        m.CapitalAtRisk = perf.CapitalAtRisk
+    }
+    if m.CapitalAtRisk > 0 {
+        m.ReturnOnCapital = perf.RealizedPnL / m.CapitalAtRisk
+        m.AnnualizedReturn = m.ReturnOnCapital * 365 / float64(m.DaysActive)
+    }
+    return m
+}
+
+// OpenCapital is the strike notional of the open puts plus the raw cost of
+// the open lots; calls are covered by those lots
+func OpenCapital(trades []models.Trade, positions []models.Position) float64 {
+    strikes := Strikes(trades)
+    var capital float64
+    for _, t := range trades {
+        if t.Status == "OPEN" && t.TradeType != "CC" && t.TradeType != "CALL" {
+            capital += Capital(t)
+        }
+    }
+    for _, p := range positions {
+        if p.Status == "OPEN" {
+            capital += RawBasis(p, strikes) * float64(p.Shares)
+        }
+    }
+    return capital
+}
@@ -0,0 +1,78 @@
+package pnl
+
+import (
+    "math"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestWheelPerformance(t *testing.T) {
+    put := models.Trade{
+        TradeID: 1, TradeType: "CSP", Contracts: 1, StrikePrice: 50, PremiumPerShare: 1,
+        OpenDate: "2026-01-01", CloseDate: ptr("2026-01-11"), Status: "CLOSED",
+    }
+    expired, assigned := put, put
+    expired.CloseMethod = ptr("EXPIRATION")
+    assigned.CloseMethod = ptr("ASSIGNMENT")
+    call := models.Trade{
+        TradeID: 2, TradeType: "CC", Contracts: 1, StrikePrice: 52, PremiumPerShare: 0.5,
+        OpenDate: "2026-01-11", CloseDate: ptr("2026-01-21"), CloseMethod: ptr("ASSIGNMENT"), Status: "CLOSED",
+    }
+    // Bought at the strike with the premium folded into its basis
+    lot := models.Position{
+        Shares: 100, CostBasisPerShare: 49, SourceTradeID: ptr(1), AcquiredDate: "2026-01-11",
+        SoldDate: ptr("2026-01-21"), SoldPricePerShare: ptr(52.0), Status: "CLOSED",
+    }
+
+    tests := []struct {
+        name      string
+        trades    []models.Trade
+        positions []models.Position
+        end       string
+        want      Performance
+    }{
+        {
+            name:   "put expired",
+            trades: []models.Trade{expired},
+            end:    "2026-01-11",
+            want: Performance{
+                DaysHeld: 10, TotalPremium: 100, RealizedPnL: 100,
+                CapitalAtRisk: 5000, ReturnOnCapital: 0.02, AnnualizedReturn: 0.73,
+            },
+        },
+        {
+            // The shares count from the strike, not the reduced basis
+            name:      "assigned and called away",
+            trades:    []models.Trade{assigned, call},
+            positions: []models.Position{lot},
+            end:       "2026-01-21",
+            want: Performance{
+                DaysHeld: 20, TotalPremium: 150, RealizedPnL: 100 + 50 + 200,
+                CapitalAtRisk: 5000, ReturnOnCapital: 0.07, AnnualizedReturn: 0.07 * 365 / 20,
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := WheelPerformance(tt.trades, tt.positions, "2026-01-01", tt.end)
+            if got.DaysHeld != tt.want.DaysHeld {
+                t.Errorf("DaysHeld = %d, want %d", got.DaysHeld, tt.want.DaysHeld)
+            }
+            for _, f := range []struct {
+                field     string
+                got, want float64
+            }{
+                {"TotalPremium", got.TotalPremium, tt.want.TotalPremium},
+                {"RealizedPnL", got.RealizedPnL, tt.want.RealizedPnL},
+                {"CapitalAtRisk", got.CapitalAtRisk, tt.want.CapitalAtRisk},
+                {"ReturnOnCapital", got.ReturnOnCapital, tt.want.ReturnOnCapital},
+                {"AnnualizedReturn", got.AnnualizedReturn, tt.want.AnnualizedReturn},
+            } {
+                if math.Abs(f.got-f.want) > 1e-9 {
+                    t.Errorf("%s = %.4f, want %.4f", f.field, f.got, f.want)
+                }
+            }
+        })
+    }
+}
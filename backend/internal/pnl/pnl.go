@@ -0,0 +1,84 @@
+// Package pnl is the single place where option and stock profit and loss is
+// computed. Wheel trades are short options, so P&L is the premium received
+// less whatever it cost to close the position and the fees; the bought legs
+// of a strategy group work the other way round.
+package pnl
+
+import "github.com/wheel-tracker/backend/internal/models"
+
+// SharesPerContract is the standard equity option deliverable
+const SharesPerContract = 100
+
+// Multiplier converts a per-share amount of t into dollars. Premium and strike
+// keep the standard multiplier even after a split changed the deliverable.
+func Multiplier(t models.Trade) float64 {
+    return float64(t.Contracts * SharesPerContract)
+}
+
+// Shares is how many shares change hands if t is assigned
+func Shares(t models.Trade) int {
+    if t.Deliverable == 0 {
+        return t.Contracts * SharesPerContract
+    }
+    return t.Contracts * t.Deliverable
+}
+
+// Sign is 1 for a leg sold to open and -1 for one bought to open
+func Sign(t models.Trade) float64 {
+    if t.Side == "BTO" {
+        return -1
+    }
+    return 1
+}
+
+// Premium is the gross premium received when t was sold, negative for the
+// premium paid on a bought leg
+func Premium(t models.Trade) float64 {
+    return Sign(t) * t.PremiumPerShare * Multiplier(t)
+}
+
+// Capital is the notional the option controls, i.e. the cash a put secures
+func Capital(t models.Trade) float64 {
+    return t.StrikePrice * Multiplier(t)
+}
+
+// ClosePrice is the per-share price paid to close t. Expired and assigned
+// options leave the book at 0; the shares that change hands on assignment
+// are accounted for as stock, not here.
+func ClosePrice(t models.Trade) float64 {
+    if t.CloseMethod != nil {
+        switch *t.CloseMethod {
+        case "EXPIRATION", "ASSIGNMENT":
+            return 0
+        }
+    }
+    if t.ClosePrice == nil {
+        return 0
+    }
+    return *t.ClosePrice
+}
+
+// Realized is the P&L of t once closed: (premium - close price) * contracts * 100 - fees,
+// with the price difference reversed for a bought leg
+func Realized(t models.Trade) float64 {
+    return Sign(t)*(t.PremiumPerShare-ClosePrice(t))*Multiplier(t) - t.Fees
+}
+
+// Unrealized is the P&L of an open t if it were closed at mark per share
+func Unrealized(t models.Trade, mark float64) float64 {
+    return Sign(t)*(t.PremiumPerShare-mark)*Multiplier(t) - t.Fees
+}
+
+// NetCredit is the cash t has brought in so far: its realized P&L once
+// closed, or the premium net of fees while it is still open
+func NetCredit(t models.Trade) float64 {
+    if t.Status == "CLOSED" {
+        return Realized(t)
+    }
+    return Unrealized(t, 0)
+}
+
+// Stock is the realized P&L of shares sold at soldPrice against basis
+func Stock(shares int, basis, soldPrice float64) float64 {
+    return (soldPrice - basis) * float64(shares)
+}
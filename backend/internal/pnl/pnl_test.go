@@ -0,0 +1,132 @@
+package pnl
+
+import (
+    "math"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func ptr[T any](v T) *T {
+    return &v
+}
+
+func TestRealized(t *testing.T) {
+    tests := []struct {
+        name  string
+        trade models.Trade
+        want  float64
+    }{
+        {
+            name:  "put bought back",
+            trade: models.Trade{TradeType: "CSP", Contracts: 2, PremiumPerShare: 1.5, ClosePrice: ptr(0.5), CloseMethod: ptr("BTC"), Fees: 2},
+            want:  (1.5-0.5)*200 - 2,
+        },
+        {
+            name:  "put expired",
+            trade: models.Trade{TradeType: "CSP", Contracts: 1, PremiumPerShare: 1, ClosePrice: ptr(0.75), CloseMethod: ptr("EXPIRATION")},
+            want:  100,
+        },
+        {
+            name:  "call assigned keeps its whole premium",
+            trade: models.Trade{TradeType: "CC", Contracts: 1, PremiumPerShare: 0.8, ClosePrice: ptr(3.0), CloseMethod: ptr("ASSIGNMENT"), Fees: 1},
+            want:  79,
+        },
+        {
+            name:  "bought leg sold for more",
+            trade: models.Trade{TradeType: "PUT", Side: "BTO", Contracts: 1, PremiumPerShare: 0.5, ClosePrice: ptr(1.25), CloseMethod: ptr("STC"), Fees: 1},
+            want:  74,
+        },
+        {
+            name:  "bought leg expired worthless",
+            trade: models.Trade{TradeType: "CALL", Side: "BTO", Contracts: 3, PremiumPerShare: 0.2, CloseMethod: ptr("EXPIRATION")},
+            want:  -60,
+        },
+        {
+            name:  "bought back for more than it was sold",
+            trade: models.Trade{TradeType: "CSP", Contracts: 1, PremiumPerShare: 1, ClosePrice: ptr(2.5), CloseMethod: ptr("BTC"), Fees: 0.65},
+            want:  -150.65,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Realized(tt.trade); math.Abs(got-tt.want) > 1e-9 {
+                t.Errorf("Realized = %.4f, want %.4f", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestOpenTradeValues(t *testing.T) {
+    tests := []struct {
+        name       string
+        trade      models.Trade
+        mark       float64
+        unrealized float64
+        netCredit  float64
+        capital    float64
+        shares     int
+    }{
+        {
+            name:       "short put",
+            trade:      models.Trade{TradeType: "CSP", Contracts: 2, StrikePrice: 45, PremiumPerShare: 1.2, Fees: 1.3, Status: "OPEN"},
+            mark:       0.7,
+            unrealized: (1.2-0.7)*200 - 1.3,
+            netCredit:  240 - 1.3,
+            capital:    9000,
+            shares:     200,
+        },
+        {
+            name:       "long call",
+            trade:      models.Trade{TradeType: "CALL", Side: "BTO", Contracts: 1, StrikePrice: 60, PremiumPerShare: 2, Fees: 0.65, Status: "OPEN"},
+            mark:       2.5,
+            unrealized: 50 - 0.65,
+            netCredit:  -200 - 0.65,
+            capital:    6000,
+            shares:     100,
+        },
+        {
+            name:       "covered call after a 3 for 2 split",
+            trade:      models.Trade{TradeType: "CC", Contracts: 1, StrikePrice: 20, PremiumPerShare: 0.4, Deliverable: 150, Status: "OPEN"},
+            mark:       0.1,
+            unrealized: 30,
+            netCredit:  40,
+            capital:    2000,
+            shares:     150,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Unrealized(tt.trade, tt.mark); math.Abs(got-tt.unrealized) > 1e-9 {
+                t.Errorf("Unrealized = %.4f, want %.4f", got, tt.unrealized)
+            }
+            if got := NetCredit(tt.trade); math.Abs(got-tt.netCredit) > 1e-9 {
+                t.Errorf("NetCredit = %.4f, want %.4f", got, tt.netCredit)
+            }
+            if got := Capital(tt.trade); got != tt.capital {
+                t.Errorf("Capital = %.2f, want %.2f", got, tt.capital)
+            }
+            if got := Shares(tt.trade); got != tt.shares {
+                t.Errorf("Shares = %d, want %d", got, tt.shares)
+            }
+        })
+    }
+}
+
+func TestStock(t *testing.T) {
+    tests := []struct {
+        shares    int
+        basis     float64
+        soldPrice float64
+        want      float64
+    }{
+        {100, 48.5, 52, 350},
+        {200, 30, 27.25, -550},
+        {0, 10, 20, 0},
+    }
+    for _, tt := range tests {
+        if got := Stock(tt.shares, tt.basis, tt.soldPrice); math.Abs(got-tt.want) > 1e-9 {
+            t.Errorf("Stock(%d, %.2f, %.2f) = %.2f, want %.2f", tt.shares, tt.basis, tt.soldPrice, got, tt.want)
+        }
+    }
+}
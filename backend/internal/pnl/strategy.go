@@ -0,0 +1,110 @@
+package pnl
+
+import (
+    "sort"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+// StrategyRisk is the payoff at expiration of a group of legs opened
+// together. MaxProfit and MaxLoss are nil when unlimited; MaxLoss is given
+// as a positive amount. Breakevens are underlying prices, lowest first.
+type StrategyRisk struct {
+    NetCredit  float64   `json:"net_credit"`
+    MaxProfit  *float64  `json:"max_profit"`
+    MaxLoss    *float64  `json:"max_loss"`
+    Breakevens []float64 `json:"breakevens"`
+}
+
+// Intrinsic is the value per share of t at expiration with the underlying at price
+func Intrinsic(t models.Trade, price float64) float64 {
+    var value float64
+    if t.TradeType == "CC" || t.TradeType == "CALL" {
+        value = price - t.StrikePrice
+    } else {
+        value = t.StrikePrice - price
+    }
+    if value < 0 {
+        return 0
+    }
+    return value
+}
+
+// ExpirationPayoff is the P&L of legs, plus shares held at basis, if every
+// leg is held to expiration with the underlying at price
+func ExpirationPayoff(legs []models.Trade, shares int, basis, price float64) float64 {
+    total := Stock(shares, basis, price)
+    for _, t := range legs {
+        total += Unrealized(t, Intrinsic(t, price))
+    }
+    return total
+}
+
+// Strategy works out the risk of legs held to expiration. The payoff is
+// piecewise linear with kinks at the strikes, so its extremes lie at zero,
+// at a strike or, when the slope above the highest strike is not flat, at
+// infinity. shares and basis describe stock the legs are written against,
+// as in a collar; pass 0 when there is none.
+func Strategy(legs []models.Trade, shares int, basis float64) StrategyRisk {
+    risk := StrategyRisk{Breakevens: make([]float64, 0)}
+    for _, t := range legs {
+        risk.NetCredit += Unrealized(t, 0)
+    }
+
+    prices := []float64{0}
+    seen := map[float64]bool{0: true}
+    for _, t := range legs {
+        if !seen[t.StrikePrice] {
+            seen[t.StrikePrice] = true
+            prices = append(prices, t.StrikePrice)
+        }
+    }
+    sort.Float64s(prices)
+
+    values := make([]float64, len(prices))
+    for i, price := range prices {
+        values[i] = ExpirationPayoff(legs, shares, basis, price)
+    }
+    // Above the highest strike only calls and shares still move with the price
+    slope := float64(shares)
+    for _, t := range legs {
+        if t.TradeType == "CC" || t.TradeType == "CALL" {
+            slope -= Sign(t) * Multiplier(t)
+        }
+    }
+
+    maxValue, minValue := values[0], values[0]
+    for _, v := range values {
+        if v > maxValue {
+            maxValue = v
+        }
+        if v < minValue {
+            minValue = v
+        }
+    }
+    if slope <= 0 {
+        risk.MaxProfit = &maxValue
+    }
+    if slope >= 0 {
+        loss := 0.0
+        if minValue < 0 {
+            loss = -minValue
+        }
+        risk.MaxLoss = &loss
+    }
+
+    for i := range prices {
+        if values[i] == 0 {
+            risk.Breakevens = append(risk.Breakevens, prices[i])
+        }
+        if i+1 < len(prices) && values[i]*values[i+1] < 0 {
+            x := prices[i] + (prices[i+1]-prices[i])*values[i]/(values[i]-values[i+1])
+            risk.Breakevens = append(risk.Breakevens, x)
+        }
+    }
+    last := len(prices) - 1
+    if slope != 0 && values[last] != 0 && -values[last]/slope > 0 {
+        risk.Breakevens = append(risk.Breakevens, prices[last]-values[last]/slope)
+    }
+    return risk
+}
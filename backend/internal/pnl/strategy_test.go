@@ -0,0 +1,94 @@
+package pnl
+
+import (
+    "fmt"
+    "math"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestStrategy(t *testing.T) {
+    leg := func(side, tradeType string, strike, premium float64) models.Trade {
+        return models.Trade{Side: side, TradeType: tradeType, Contracts: 1, StrikePrice: strike, PremiumPerShare: premium}
+    }
+    tests := []struct {
+        name       string
+        legs       []models.Trade
+        shares     int
+        basis      float64
+        netCredit  float64
+        maxProfit  *float64 // nil when unlimited
+        maxLoss    *float64 // nil when unlimited
+        breakevens []float64
+    }{
+        {
+            name:       "bull put spread",
+            legs:       []models.Trade{leg("STO", "PUT", 50, 2), leg("BTO", "PUT", 45, 0.5)},
+            netCredit:  150,
+            maxProfit:  ptr(150.0),
+            maxLoss:    ptr(350.0),
+            breakevens: []float64{48.5},
+        },
+        {
+            name:       "short strangle",
+            legs:       []models.Trade{leg("STO", "PUT", 40, 1), leg("STO", "CALL", 60, 1)},
+            netCredit:  200,
+            maxProfit:  ptr(200.0),
+            breakevens: []float64{38, 62},
+        },
+        {
+            name:       "long call",
+            legs:       []models.Trade{leg("BTO", "CALL", 30, 2)},
+            netCredit:  -200,
+            maxLoss:    ptr(200.0),
+            breakevens: []float64{32},
+        },
+        {
+            name:       "collar on 100 shares",
+            legs:       []models.Trade{leg("BTO", "PUT", 45, 1), leg("STO", "CALL", 55, 1)},
+            shares:     100,
+            basis:      50,
+            netCredit:  0,
+            maxProfit:  ptr(500.0),
+            maxLoss:    ptr(500.0),
+            breakevens: []float64{50},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            risk := Strategy(tt.legs, tt.shares, tt.basis)
+            if math.Abs(risk.NetCredit-tt.netCredit) > 1e-9 {
+                t.Errorf("NetCredit = %.2f, want %.2f", risk.NetCredit, tt.netCredit)
+            }
+            if !sameBound(risk.MaxProfit, tt.maxProfit) {
+                t.Errorf("MaxProfit = %v, want %v", bound(risk.MaxProfit), bound(tt.maxProfit))
+            }
+            if !sameBound(risk.MaxLoss, tt.maxLoss) {
+                t.Errorf("MaxLoss = %v, want %v", bound(risk.MaxLoss), bound(tt.maxLoss))
+            }
+            if len(risk.Breakevens) != len(tt.breakevens) {
+                t.Fatalf("Breakevens = %v, want %v", risk.Breakevens, tt.breakevens)
+            }
+            for i := range tt.breakevens {
+                if math.Abs(risk.Breakevens[i]-tt.breakevens[i]) > 1e-9 {
+                    t.Errorf("Breakevens = %v, want %v", risk.Breakevens, tt.breakevens)
+                }
+            }
+        })
+    }
+}
+
+func sameBound(got, want *float64) bool {
+    if got == nil || want == nil {
+        return got == want
+    }
+    return math.Abs(*got-*want) < 1e-9
+}
+
+func bound(v *float64) string {
+    if v == nil {
+        return "unlimited"
+    }
+    return fmt.Sprintf("%.2f", *v)
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const accountColumns = `
+    account_id, name, broker, currency, initial_balance,
+    current_balance, is_active, account_type, margin_multiplier, lot_method, created_at, updated_at`
+
+func scanAccount(s scanner) (models.Account, error) {
+    var acc models.Account
+    err := s.Scan(&acc.AccountID, &acc.Name, &acc.Broker, &acc.Currency,
+        &acc.InitialBalance, &acc.CurrentBalance, &acc.IsActive,
+        &acc.AccountType, &acc.MarginMultiplier, &acc.LotMethod, &acc.CreatedAt, &acc.UpdatedAt)
+    return acc, err
+}
+
+func scanAccountTransaction(s scanner) (models.AccountTransaction, error) {
+    var t models.AccountTransaction
+    err := s.Scan(&t.TransactionID, &t.AccountID, &t.TransactionType, &t.Amount, &t.TransactionDate, &t.Notes,
+        &t.TradeID, &t.IncomeID, &t.PositionID)
+    return t, err
+}
+
+type AccountRepo struct {
+    db DBTX
+}
+
+func NewAccountRepo(db DBTX) *AccountRepo {
+    return &AccountRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *AccountRepo) WithTx(tx *sql.Tx) *AccountRepo {
+    return &AccountRepo{db: tx}
+}
+
+// List returns all accounts, or only active ones when activeOnly is set
+func (r *AccountRepo) List(ctx context.Context, activeOnly bool) ([]models.Account, error) {
+    query := "SELECT " + accountColumns + " FROM accounts"
+    if activeOnly {
+        query += " WHERE is_active = 1"
+    }
+    query += " ORDER BY account_id"
+    return queryList(ctx, r.db, scanAccount, query)
+}
+
+func (r *AccountRepo) Get(ctx context.Context, id int) (models.Account, error) {
+    return queryOne(ctx, r.db, scanAccount, "SELECT "+accountColumns+" FROM accounts WHERE account_id = ?", id)
+}
+
+// GetActiveID returns the id of the account currently marked active
+func (r *AccountRepo) GetActiveID(ctx context.Context) (int, error) {
+    return queryOne(ctx, r.db, func(s scanner) (int, error) {
+        var id int
+        err := s.Scan(&id)
+        return id, err
+    }, "SELECT account_id FROM accounts WHERE is_active = 1 LIMIT 1")
+}
+
+// IsActive reports whether the account exists and is active
+func (r *AccountRepo) IsActive(ctx context.Context, id int) (bool, error) {
+    var exists bool
+    err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM accounts WHERE account_id = ? AND is_active = 1)", id).Scan(&exists)
+    return exists, err
+}
+
+// Create inserts acc and sets its AccountID. A new account has an empty
+// ledger, so its balance starts at the initial balance; lots are sold FIFO
+// unless it names another method.
+func (r *AccountRepo) Create(ctx context.Context, acc *models.Account) error {
+    if acc.LotMethod == "" {
+        acc.LotMethod = "FIFO"
+    }
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO accounts (name, broker, currency, initial_balance, current_balance, account_type, margin_multiplier, lot_method)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `, acc.Name, acc.Broker, acc.Currency, acc.InitialBalance, acc.InitialBalance, acc.AccountType, acc.MarginMultiplier, acc.LotMethod))
+    if err != nil {
+        return err
+    }
+    acc.AccountID = id
+    acc.CurrentBalance = acc.InitialBalance
+    return nil
+}
+
+// Update rewrites the descriptive fields of an account; the balance only
+// changes through the ledger. An empty lot method keeps the current one.
+func (r *AccountRepo) Update(ctx context.Context, acc models.Account) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE accounts
+        SET name = ?, broker = ?, currency = ?, account_type = ?, margin_multiplier = ?,
+            lot_method = COALESCE(NULLIF(?, ''), lot_method), updated_at = CURRENT_TIMESTAMP
+        WHERE account_id = ?
+    `, acc.Name, acc.Broker, acc.Currency, acc.AccountType, acc.MarginMultiplier, acc.LotMethod, acc.AccountID))
+}
+
+func (r *AccountRepo) Delete(ctx context.Context, id int) error {
+    return requireRows(r.db.ExecContext(ctx, "DELETE FROM accounts WHERE account_id = ?", id))
+}
+
+// Activate makes id the only active account; run it inside a transaction
+func (r *AccountRepo) Activate(ctx context.Context, id int) error {
+    if _, err := r.db.ExecContext(ctx, "UPDATE accounts SET is_active = 0"); err != nil {
+        return err
+    }
+    return requireRows(r.db.ExecContext(ctx, "UPDATE accounts SET is_active = 1 WHERE account_id = ?", id))
+}
+
+// PostCash moves delta into (or, when negative, out of) the account by
+// posting it to the ledger as a transaction of the given type
+func (r *AccountRepo) PostCash(ctx context.Context, accountID int, transactionType string, delta float64, notes string) error {
+    return r.Post(ctx, &models.AccountTransaction{
+        AccountID:       accountID,
+        TransactionType: transactionType,
+        Amount:          delta,
+        Notes:           notes,
+    })
+}
+
+// Post records e in the ledger, dated now unless it carries a date, and
+// refreshes the account balance
+func (r *AccountRepo) Post(ctx context.Context, e *models.AccountTransaction) error {
+    var date interface{}
+    if e.TransactionDate != "" {
+        date = e.TransactionDate
+    }
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO account_transactions (
+            account_id, transaction_type, amount, transaction_date, notes, trade_id, income_id, position_id
+        ) VALUES (?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?)
+    `, e.AccountID, e.TransactionType, e.Amount, date, e.Notes, e.TradeID, e.IncomeID, e.PositionID))
+    if err != nil {
+        return err
+    }
+    e.TransactionID = id
+    return r.RefreshBalance(ctx, e.AccountID)
+}
+
+// DeleteTradeEntries removes what was posted for a trade so it can be posted again
+func (r *AccountRepo) DeleteTradeEntries(ctx context.Context, tradeID int) error {
+    _, err := r.db.ExecContext(ctx, "DELETE FROM account_transactions WHERE trade_id = ?", tradeID)
+    return err
+}
+
+// DeletePositionEntries removes what was posted for a position so it can be posted again
+func (r *AccountRepo) DeletePositionEntries(ctx context.Context, positionID int) error {
+    _, err := r.db.ExecContext(ctx, "DELETE FROM account_transactions WHERE position_id = ?", positionID)
+    return err
+}
+
+// RefreshBalance derives current_balance from the initial balance and the ledger
+func (r *AccountRepo) RefreshBalance(ctx context.Context, accountID int) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE accounts
+        SET current_balance = initial_balance + (
+                SELECT COALESCE(SUM(amount), 0) FROM account_transactions WHERE account_id = ?
+            ),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE account_id = ?
+    `, accountID, accountID))
+}
+
+// LedgerTotal is the sum of every entry posted to the account
+func (r *AccountRepo) LedgerTotal(ctx context.Context, accountID int) (float64, error) {
+    var total float64
+    err := r.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(amount), 0) FROM account_transactions WHERE account_id = ?", accountID).Scan(&total)
+    return total, err
+}
+
+// ListTransactions returns the cash history of an account, newest first
+func (r *AccountRepo) ListTransactions(ctx context.Context, accountID int) ([]models.AccountTransaction, error) {
+    return queryList(ctx, r.db, scanAccountTransaction, `
+        SELECT transaction_id, account_id, transaction_type, amount, transaction_date, COALESCE(notes, ''),
+            trade_id, income_id, position_id
+        FROM account_transactions
+        WHERE account_id = ?
+        ORDER BY transaction_date DESC, transaction_id DESC
+    `, accountID)
+}
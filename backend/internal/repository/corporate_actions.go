@@ -0,0 +1,105 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const corporateActionColumns = `
+    action_id, symbol, action_type, split_from, split_to, new_symbol, effective_date, notes, created_at`
+
+func scanCorporateAction(s scanner) (models.CorporateAction, error) {
+    var a models.CorporateAction
+    err := s.Scan(&a.ActionID, &a.Symbol, &a.ActionType, &a.SplitFrom, &a.SplitTo,
+        &a.NewSymbol, &a.EffectiveDate, &a.Notes, &a.CreatedAt)
+    return a, err
+}
+
+const adjustmentColumns = `
+    adjustment_id, action_id, position_id, trade_id, old_symbol, new_symbol, old_quantity,
+    new_quantity, old_price, new_price, old_deliverable, new_deliverable, created_at`
+
+func scanAdjustment(s scanner) (models.CorporateActionAdjustment, error) {
+    var a models.CorporateActionAdjustment
+    err := s.Scan(&a.AdjustmentID, &a.ActionID, &a.PositionID, &a.TradeID, &a.OldSymbol,
+        &a.NewSymbol, &a.OldQuantity, &a.NewQuantity, &a.OldPrice, &a.NewPrice,
+        &a.OldDeliverable, &a.NewDeliverable, &a.CreatedAt)
+    return a, err
+}
+
+// CorporateActionRepo stores the corporate actions applied and what each changed
+type CorporateActionRepo struct {
+    db DBTX
+}
+
+func NewCorporateActionRepo(db DBTX) *CorporateActionRepo {
+    return &CorporateActionRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *CorporateActionRepo) WithTx(tx *sql.Tx) *CorporateActionRepo {
+    return &CorporateActionRepo{db: tx}
+}
+
+// List returns the actions of a symbol, or of every symbol when it is empty, most recent first
+func (r *CorporateActionRepo) List(ctx context.Context, symbol string) ([]models.CorporateAction, error) {
+    query := "SELECT " + corporateActionColumns + " FROM corporate_actions WHERE 1=1"
+    var args []interface{}
+
+    if symbol != "" {
+        query += " AND (symbol = ? OR new_symbol = ?)"
+        args = append(args, symbol, symbol)
+    }
+    query += " ORDER BY effective_date DESC, action_id DESC"
+
+    return queryList(ctx, r.db, scanCorporateAction, query, args...)
+}
+
+func (r *CorporateActionRepo) Get(ctx context.Context, id int) (models.CorporateAction, error) {
+    return queryOne(ctx, r.db, scanCorporateAction, "SELECT "+corporateActionColumns+" FROM corporate_actions WHERE action_id = ?", id)
+}
+
+// Exists reports whether an action of the type was already applied to symbol on date
+func (r *CorporateActionRepo) Exists(ctx context.Context, symbol, actionType, date string) (bool, error) {
+    var n int
+    err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM corporate_actions WHERE symbol = ? AND action_type = ? AND date(effective_date) = date(?)
+    `, symbol, actionType, date).Scan(&n)
+    return n > 0, err
+}
+
+// Create inserts a and sets its ActionID
+func (r *CorporateActionRepo) Create(ctx context.Context, a *models.CorporateAction) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO corporate_actions (symbol, action_type, split_from, split_to, new_symbol, effective_date, notes)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `, a.Symbol, a.ActionType, a.SplitFrom, a.SplitTo, a.NewSymbol, a.EffectiveDate, a.Notes))
+    if err != nil {
+        return err
+    }
+    a.ActionID = id
+    return nil
+}
+
+// ListAdjustments returns what an action changed, in the order it was applied
+func (r *CorporateActionRepo) ListAdjustments(ctx context.Context, actionID int) ([]models.CorporateActionAdjustment, error) {
+    return queryList(ctx, r.db, scanAdjustment, "SELECT "+adjustmentColumns+" FROM corporate_action_adjustments WHERE action_id = ? ORDER BY adjustment_id", actionID)
+}
+
+// CreateAdjustment inserts a and sets its AdjustmentID
+func (r *CorporateActionRepo) CreateAdjustment(ctx context.Context, a *models.CorporateActionAdjustment) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO corporate_action_adjustments (
+            action_id, position_id, trade_id, old_symbol, new_symbol, old_quantity, new_quantity,
+            old_price, new_price, old_deliverable, new_deliverable
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, a.ActionID, a.PositionID, a.TradeID, a.OldSymbol, a.NewSymbol, a.OldQuantity, a.NewQuantity,
+        a.OldPrice, a.NewPrice, a.OldDeliverable, a.NewDeliverable))
+    if err != nil {
+        return err
+    }
+    a.AdjustmentID = id
+    return nil
+}
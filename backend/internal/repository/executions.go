@@ -0,0 +1,71 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const executionColumns = `
+    execution_id, trade_id, action, contracts, price, fees, executed_at, created_at`
+
+func scanExecution(s scanner) (models.Execution, error) {
+    var e models.Execution
+    err := s.Scan(&e.ExecutionID, &e.TradeID, &e.Action, &e.Contracts, &e.Price,
+        &e.Fees, &e.ExecutedAt, &e.CreatedAt)
+    return e, err
+}
+
+// ExecutionRepo stores the fills of each trade
+type ExecutionRepo struct {
+    db DBTX
+}
+
+func NewExecutionRepo(db DBTX) *ExecutionRepo {
+    return &ExecutionRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *ExecutionRepo) WithTx(tx *sql.Tx) *ExecutionRepo {
+    return &ExecutionRepo{db: tx}
+}
+
+// List returns the fills of a trade in the order they happened
+func (r *ExecutionRepo) List(ctx context.Context, tradeID int) ([]models.Execution, error) {
+    return queryList(ctx, r.db, scanExecution, "SELECT "+executionColumns+" FROM trade_executions WHERE trade_id = ? ORDER BY executed_at, execution_id", tradeID)
+}
+
+// Create inserts e and sets its ExecutionID
+func (r *ExecutionRepo) Create(ctx context.Context, e *models.Execution) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO trade_executions (trade_id, action, contracts, price, fees, executed_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `, e.TradeID, e.Action, e.Contracts, e.Price, e.Fees, e.ExecutedAt))
+    if err != nil {
+        return err
+    }
+    e.ExecutionID = id
+    return nil
+}
+
+// Update rewrites the trade, contracts, price and fees of a fill
+func (r *ExecutionRepo) Update(ctx context.Context, e models.Execution) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE trade_executions SET trade_id = ?, contracts = ?, price = ?, fees = ? WHERE execution_id = ?
+    `, e.TradeID, e.Contracts, e.Price, e.Fees, e.ExecutionID))
+}
+
+// Delete removes one fill of a trade
+func (r *ExecutionRepo) Delete(ctx context.Context, tradeID, id int) error {
+    return requireRows(r.db.ExecContext(ctx, "DELETE FROM trade_executions WHERE trade_id = ? AND execution_id = ?", tradeID, id))
+}
+
+// Split multiplies the contracts of every fill of a trade by factor and
+// divides their price by it, as a whole-number stock split does
+func (r *ExecutionRepo) Split(ctx context.Context, tradeID, factor int) error {
+    _, err := r.db.ExecContext(ctx, `
+        UPDATE trade_executions SET contracts = contracts * ?, price = price / ? WHERE trade_id = ?
+    `, factor, factor, tradeID)
+    return err
+}
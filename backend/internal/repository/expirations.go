@@ -0,0 +1,77 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const expirationLogColumns = `
+    log_id, trade_id, action, underlying_price, message, created_at`
+
+func scanExpirationLog(s scanner) (models.ExpirationLogEntry, error) {
+    var e models.ExpirationLogEntry
+    err := s.Scan(&e.LogID, &e.TradeID, &e.Action, &e.UnderlyingPrice, &e.Message, &e.CreatedAt)
+    return e, err
+}
+
+// ExpirationLogFilter narrows List; zero values are ignored
+type ExpirationLogFilter struct {
+    TradeID int
+    Action  string
+}
+
+// ExpirationLogRepo is the audit trail of the expiration job
+type ExpirationLogRepo struct {
+    db DBTX
+}
+
+func NewExpirationLogRepo(db DBTX) *ExpirationLogRepo {
+    return &ExpirationLogRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *ExpirationLogRepo) WithTx(tx *sql.Tx) *ExpirationLogRepo {
+    return &ExpirationLogRepo{db: tx}
+}
+
+// List returns log entries, newest first
+func (r *ExpirationLogRepo) List(ctx context.Context, f ExpirationLogFilter) ([]models.ExpirationLogEntry, error) {
+    query := "SELECT " + expirationLogColumns + " FROM trade_expiration_log WHERE 1=1"
+    var args []interface{}
+
+    if f.TradeID != 0 {
+        query += " AND trade_id = ?"
+        args = append(args, f.TradeID)
+    }
+    if f.Action != "" {
+        query += " AND action = ?"
+        args = append(args, f.Action)
+    }
+    query += " ORDER BY created_at DESC, log_id DESC"
+
+    return queryList(ctx, r.db, scanExpirationLog, query, args...)
+}
+
+// Create inserts e and sets its LogID
+func (r *ExpirationLogRepo) Create(ctx context.Context, e *models.ExpirationLogEntry) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO trade_expiration_log (trade_id, action, underlying_price, message)
+        VALUES (?, ?, ?, ?)
+    `, e.TradeID, e.Action, e.UnderlyingPrice, e.Message))
+    if err != nil {
+        return err
+    }
+    e.LogID = id
+    return nil
+}
+
+// Flagged reports whether the trade has already been flagged with action
+func (r *ExpirationLogRepo) Flagged(ctx context.Context, tradeID int, action string) (bool, error) {
+    var exists bool
+    err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM trade_expiration_log WHERE trade_id = ? AND action = ?)
+    `, tradeID, action).Scan(&exists)
+    return exists, err
+}
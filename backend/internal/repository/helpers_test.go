@@ -0,0 +1,57 @@
+package repository
+
+import (
+    "context"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/database"
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+// newTestDB opens a migrated and seeded database in memory; account 1 is the
+// default account. Every pooled connection to :memory: is a separate
+// database, so the pool is held to one.
+func newTestDB(t *testing.T) *database.DB {
+    t.Helper()
+    db, err := database.Open(":memory:")
+    if err != nil {
+        t.Fatalf("open database: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+    db.SetMaxOpenConns(1)
+    if _, err := db.MigrateUp(); err != nil {
+        t.Fatalf("migrate: %v", err)
+    }
+    return db
+}
+
+// createTestAccount adds a cash account and returns its id
+func createTestAccount(t *testing.T, db *database.DB, name string) int {
+    t.Helper()
+    acc := models.Account{Name: name, Broker: "IBKR", Currency: "USD", AccountType: "cash", MarginMultiplier: 1}
+    if err := NewAccountRepo(db).Create(context.Background(), &acc); err != nil {
+        t.Fatalf("create account: %v", err)
+    }
+    return acc.AccountID
+}
+
+// ids lists the id of each row returned, in order
+func ids[T any](rows []T, id func(T) int) []int {
+    out := make([]int, 0, len(rows))
+    for _, r := range rows {
+        out = append(out, id(r))
+    }
+    return out
+}
+
+func sameIDs(got, want []int) bool {
+    if len(got) != len(want) {
+        return false
+    }
+    for i := range got {
+        if got[i] != want[i] {
+            return false
+        }
+    }
+    return true
+}
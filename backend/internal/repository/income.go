@@ -0,0 +1,81 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const incomeColumns = `
+    income_id, account_id, symbol, income_type, amount,
+    payment_date, currency, notes, created_at`
+
+func scanIncome(s scanner) (models.Income, error) {
+    var inc models.Income
+    err := s.Scan(&inc.IncomeID, &inc.AccountID, &inc.Symbol, &inc.IncomeType,
+        &inc.Amount, &inc.PaymentDate, &inc.Currency, &inc.Notes, &inc.CreatedAt)
+    return inc, err
+}
+
+// IncomeFilter narrows List; zero values are ignored
+type IncomeFilter struct {
+    AccountID  int
+    IncomeType string
+    Symbol     string
+}
+
+type IncomeRepo struct {
+    db DBTX
+}
+
+func NewIncomeRepo(db DBTX) *IncomeRepo {
+    return &IncomeRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *IncomeRepo) WithTx(tx *sql.Tx) *IncomeRepo {
+    return &IncomeRepo{db: tx}
+}
+
+func (r *IncomeRepo) List(ctx context.Context, f IncomeFilter) ([]models.Income, error) {
+    query := "SELECT " + incomeColumns + " FROM dividends_income WHERE 1=1"
+    var args []interface{}
+
+    if f.AccountID != 0 {
+        query += " AND account_id = ?"
+        args = append(args, f.AccountID)
+    }
+    if f.IncomeType != "" {
+        query += " AND income_type = ?"
+        args = append(args, f.IncomeType)
+    }
+    if f.Symbol != "" {
+        query += " AND symbol = ?"
+        args = append(args, f.Symbol)
+    }
+    query += " ORDER BY payment_date DESC"
+
+    return queryList(ctx, r.db, scanIncome, query, args...)
+}
+
+func (r *IncomeRepo) Get(ctx context.Context, id int) (models.Income, error) {
+    return queryOne(ctx, r.db, scanIncome, "SELECT "+incomeColumns+" FROM dividends_income WHERE income_id = ?", id)
+}
+
+// Create inserts inc and sets its IncomeID
+func (r *IncomeRepo) Create(ctx context.Context, inc *models.Income) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO dividends_income (account_id, symbol, income_type, amount, payment_date, currency, notes)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `, inc.AccountID, inc.Symbol, inc.IncomeType, inc.Amount, inc.PaymentDate, inc.Currency, inc.Notes))
+    if err != nil {
+        return err
+    }
+    inc.IncomeID = id
+    return nil
+}
+
+func (r *IncomeRepo) Delete(ctx context.Context, id int) error {
+    return requireRows(r.db.ExecContext(ctx, "DELETE FROM dividends_income WHERE income_id = ?", id))
+}
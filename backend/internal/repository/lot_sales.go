@@ -0,0 +1,95 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const lotSaleColumns = `
+    sale_id, account_id, symbol, lot_id, position_id, shares, acquired_date, sold_date,
+    cost_basis_per_share, sale_price_per_share, fees, realized_pnl, term, lot_method, trade_id, created_at`
+
+func scanLotSale(s scanner) (models.LotSale, error) {
+    var l models.LotSale
+    err := s.Scan(&l.SaleID, &l.AccountID, &l.Symbol, &l.LotID, &l.PositionID, &l.Shares,
+        &l.AcquiredDate, &l.SoldDate, &l.CostBasisPerShare, &l.SalePricePerShare,
+        &l.Fees, &l.RealizedPnL, &l.Term, &l.LotMethod, &l.TradeID, &l.CreatedAt)
+    return l, err
+}
+
+// LotSaleFilter narrows List; zero values are ignored. Year matches the
+// year of the sale.
+type LotSaleFilter struct {
+    AccountID  int
+    Symbol     string
+    Term       string
+    Year       int
+    PositionID int
+    TradeID    int
+}
+
+// LotSaleRepo stores the realized gain of every lot a sale consumed
+type LotSaleRepo struct {
+    db DBTX
+}
+
+func NewLotSaleRepo(db DBTX) *LotSaleRepo {
+    return &LotSaleRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *LotSaleRepo) WithTx(tx *sql.Tx) *LotSaleRepo {
+    return &LotSaleRepo{db: tx}
+}
+
+// List returns lot sales, most recent first
+func (r *LotSaleRepo) List(ctx context.Context, f LotSaleFilter) ([]models.LotSale, error) {
+    query := "SELECT " + lotSaleColumns + " FROM lot_sales WHERE 1=1"
+    var args []interface{}
+
+    if f.AccountID != 0 {
+        query += " AND account_id = ?"
+        args = append(args, f.AccountID)
+    }
+    if f.Symbol != "" {
+        query += " AND symbol = ?"
+        args = append(args, f.Symbol)
+    }
+    if f.Term != "" {
+        query += " AND term = ?"
+        args = append(args, f.Term)
+    }
+    if f.Year != 0 {
+        query += " AND CAST(strftime('%Y', sold_date) AS INTEGER) = ?"
+        args = append(args, f.Year)
+    }
+    if f.PositionID != 0 {
+        query += " AND position_id = ?"
+        args = append(args, f.PositionID)
+    }
+    if f.TradeID != 0 {
+        query += " AND trade_id = ?"
+        args = append(args, f.TradeID)
+    }
+    query += " ORDER BY sold_date DESC, sale_id DESC"
+
+    return queryList(ctx, r.db, scanLotSale, query, args...)
+}
+
+// Create inserts l and sets its SaleID
+func (r *LotSaleRepo) Create(ctx context.Context, l *models.LotSale) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO lot_sales (
+            account_id, symbol, lot_id, position_id, shares, acquired_date, sold_date,
+            cost_basis_per_share, sale_price_per_share, fees, realized_pnl, term, lot_method, trade_id
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, l.AccountID, l.Symbol, l.LotID, l.PositionID, l.Shares, l.AcquiredDate, l.SoldDate,
+        l.CostBasisPerShare, l.SalePricePerShare, l.Fees, l.RealizedPnL, l.Term, l.LotMethod, l.TradeID))
+    if err != nil {
+        return err
+    }
+    l.SaleID = id
+    return nil
+}
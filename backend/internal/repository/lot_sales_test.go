@@ -0,0 +1,61 @@
+package repository
+
+import (
+    "context"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestLotSaleListFilters(t *testing.T) {
+    db := newTestDB(t)
+    ctx := context.Background()
+    other := createTestAccount(t, db, "Second")
+
+    positions := NewPositionRepo(db)
+    sales := NewLotSaleRepo(db)
+    var created []int
+    for _, l := range []models.LotSale{
+        {AccountID: 1, Symbol: "ABC", SoldDate: "2025-12-30", Term: "SHORT"},
+        {AccountID: 1, Symbol: "ABC", SoldDate: "2026-02-10", Term: "LONG"},
+        {AccountID: 1, Symbol: "XYZ", SoldDate: "2026-03-05", Term: "SHORT"},
+        {AccountID: other, Symbol: "ABC", SoldDate: "2026-04-01", Term: "SHORT"},
+    } {
+        p := models.Position{AccountID: l.AccountID, Symbol: l.Symbol, Shares: 100, CostBasisPerShare: 40, AcquiredDate: "2025-01-02"}
+        if err := positions.Create(ctx, &p); err != nil {
+            t.Fatalf("create position: %v", err)
+        }
+        l.LotID, l.PositionID, l.Shares, l.AcquiredDate = p.PositionID, p.PositionID, 100, p.AcquiredDate
+        l.CostBasisPerShare, l.SalePricePerShare, l.LotMethod = 40, 45, "FIFO"
+        if err := sales.Create(ctx, &l); err != nil {
+            t.Fatalf("create lot sale: %v", err)
+        }
+        created = append(created, l.SaleID)
+    }
+    old, long, xyz, otherSale := created[0], created[1], created[2], created[3]
+
+    tests := []struct {
+        name   string
+        filter LotSaleFilter
+        want   []int
+    }{
+        {"no filter, latest sale first", LotSaleFilter{}, []int{otherSale, xyz, long, old}},
+        {"account", LotSaleFilter{AccountID: other}, []int{otherSale}},
+        {"symbol", LotSaleFilter{Symbol: "ABC"}, []int{otherSale, long, old}},
+        {"term", LotSaleFilter{Term: "SHORT"}, []int{otherSale, xyz, old}},
+        {"year of the sale", LotSaleFilter{Year: 2025}, []int{old}},
+        {"combined", LotSaleFilter{AccountID: 1, Year: 2026, Symbol: "ABC"}, []int{long}},
+        {"nothing matches", LotSaleFilter{Year: 2024}, []int{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := sales.List(ctx, tt.filter)
+            if err != nil {
+                t.Fatalf("list: %v", err)
+            }
+            if ids := ids(got, func(l models.LotSale) int { return l.SaleID }); !sameIDs(ids, tt.want) {
+                t.Errorf("lot sales = %v, want %v", ids, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const positionColumns = `
+    position_id, account_id, symbol, shares, cost_basis_per_share,
+    acquired_date, sold_date, sold_price_per_share, status,
+    wheel_id, source_trade_id, notes, split_from_position_id, split_ratio, created_at, updated_at`
+
+func scanPosition(s scanner) (models.Position, error) {
+    var p models.Position
+    err := s.Scan(&p.PositionID, &p.AccountID, &p.Symbol, &p.Shares,
+        &p.CostBasisPerShare, &p.AcquiredDate, &p.SoldDate, &p.SoldPricePerShare,
+        &p.Status, &p.WheelID, &p.SourceTradeID, &p.Notes, &p.SplitFromPositionID,
+        &p.SplitRatio, &p.CreatedAt, &p.UpdatedAt)
+    return p, err
+}
+
+// PositionFilter narrows List; zero values are ignored
+type PositionFilter struct {
+    Status    string
+    AccountID int
+    Symbol    string
+    WheelID   int
+}
+
+type PositionRepo struct {
+    db DBTX
+}
+
+func NewPositionRepo(db DBTX) *PositionRepo {
+    return &PositionRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *PositionRepo) WithTx(tx *sql.Tx) *PositionRepo {
+    return &PositionRepo{db: tx}
+}
+
+func (r *PositionRepo) List(ctx context.Context, f PositionFilter) ([]models.Position, error) {
+    query := "SELECT " + positionColumns + " FROM positions WHERE 1=1"
+    var args []interface{}
+
+    if f.Status != "" {
+        query += " AND status = ?"
+        args = append(args, f.Status)
+    }
+    if f.AccountID != 0 {
+        query += " AND account_id = ?"
+        args = append(args, f.AccountID)
+    }
+    if f.Symbol != "" {
+        query += " AND symbol = ?"
+        args = append(args, f.Symbol)
+    }
+    if f.WheelID != 0 {
+        query += " AND wheel_id = ?"
+        args = append(args, f.WheelID)
+    }
+    query += " ORDER BY acquired_date DESC"
+
+    return queryList(ctx, r.db, scanPosition, query, args...)
+}
+
+func (r *PositionRepo) Get(ctx context.Context, id int) (models.Position, error) {
+    return queryOne(ctx, r.db, scanPosition, "SELECT "+positionColumns+" FROM positions WHERE position_id = ?", id)
+}
+
+// ListLot returns a lot and the rows its sold shares were split onto
+func (r *PositionRepo) ListLot(ctx context.Context, lotID int) ([]models.Position, error) {
+    return queryList(ctx, r.db, scanPosition, `
+        SELECT `+positionColumns+` FROM positions
+        WHERE position_id = ? OR split_from_position_id = ?
+        ORDER BY position_id
+    `, lotID, lotID)
+}
+
+// Create inserts p and sets its PositionID. An empty status defaults to OPEN.
+func (r *PositionRepo) Create(ctx context.Context, p *models.Position) error {
+    if p.Status == "" {
+        p.Status = "OPEN"
+    }
+    if p.SplitRatio == 0 {
+        p.SplitRatio = 1
+    }
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO positions (
+            account_id, symbol, shares, cost_basis_per_share, acquired_date,
+            sold_date, sold_price_per_share, status, wheel_id,
+            source_trade_id, notes, split_from_position_id, split_ratio
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, p.AccountID, p.Symbol, p.Shares, p.CostBasisPerShare, p.AcquiredDate,
+        p.SoldDate, p.SoldPricePerShare, p.Status, p.WheelID,
+        p.SourceTradeID, p.Notes, p.SplitFromPositionID, p.SplitRatio))
+    if err != nil {
+        return err
+    }
+    p.PositionID = id
+    return nil
+}
+
+// Update rewrites the editable fields of a position
+func (r *PositionRepo) Update(ctx context.Context, p models.Position) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE positions
+        SET shares = ?, cost_basis_per_share = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE position_id = ?
+    `, p.Shares, p.CostBasisPerShare, p.Notes, p.PositionID))
+}
+
+// Close marks the whole position as sold
+func (r *PositionRepo) Close(ctx context.Context, id int, soldDate string, soldPricePerShare float64) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE positions
+        SET sold_date = ?, sold_price_per_share = ?, status = 'CLOSED', updated_at = CURRENT_TIMESTAMP
+        WHERE position_id = ?
+    `, soldDate, soldPricePerShare, id))
+}
+
+// SetShares changes the share count of a position, used when part of it is sold
+func (r *PositionRepo) SetShares(ctx context.Context, id int, shares int) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE positions SET shares = ?, updated_at = CURRENT_TIMESTAMP WHERE position_id = ?
+    `, shares, id))
+}
+
+// Split applies a stock split to a position: it now holds shares at basis per
+// share, and ratio more shares than before for each one it was acquired with
+func (r *PositionRepo) Split(ctx context.Context, id, shares int, basis, ratio float64) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE positions
+        SET shares = ?, cost_basis_per_share = ?, split_ratio = split_ratio * ?, updated_at = CURRENT_TIMESTAMP
+        WHERE position_id = ?
+    `, shares, basis, ratio, id))
+}
+
+// SetSymbol moves a position to a new ticker after a symbol change
+func (r *PositionRepo) SetSymbol(ctx context.Context, id int, symbol string) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE positions SET symbol = ?, updated_at = CURRENT_TIMESTAMP WHERE position_id = ?
+    `, symbol, id))
+}
+
+// LinkToTradeWheels puts positions without a wheel on the wheel of the trade
+// whose assignment created them and returns the wheels that gained positions
+func (r *PositionRepo) LinkToTradeWheels(ctx context.Context) ([]int, error) {
+    wheelIDs, err := queryList(ctx, r.db, func(s scanner) (int, error) {
+        var id int
+        err := s.Scan(&id)
+        return id, err
+    }, `
+        SELECT DISTINCT t.wheel_id
+        FROM positions p JOIN trades t ON t.trade_id = p.source_trade_id
+        WHERE p.wheel_id IS NULL AND t.wheel_id IS NOT NULL
+    `)
+    if err != nil || len(wheelIDs) == 0 {
+        return wheelIDs, err
+    }
+
+    _, err = r.db.ExecContext(ctx, `
+        UPDATE positions
+        SET wheel_id = (SELECT t.wheel_id FROM trades t WHERE t.trade_id = positions.source_trade_id),
+            updated_at = CURRENT_TIMESTAMP
+        WHERE wheel_id IS NULL
+          AND EXISTS (SELECT 1 FROM trades t WHERE t.trade_id = positions.source_trade_id AND t.wheel_id IS NOT NULL)
+    `)
+    return wheelIDs, err
+}
+
+func (r *PositionRepo) Delete(ctx context.Context, id int) error {
+    return requireRows(r.db.ExecContext(ctx, "DELETE FROM positions WHERE position_id = ?", id))
+}
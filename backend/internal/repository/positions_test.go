@@ -0,0 +1,58 @@
+package repository
+
+import (
+    "context"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestPositionListFilters(t *testing.T) {
+    db := newTestDB(t)
+    ctx := context.Background()
+    other := createTestAccount(t, db, "Second")
+
+    wheel := models.Wheel{AccountID: 1, Symbol: "ABC", StartDate: "2026-01-05"}
+    if err := NewWheelRepo(db).Create(ctx, &wheel); err != nil {
+        t.Fatalf("create wheel: %v", err)
+    }
+    positions := NewPositionRepo(db)
+    var created []int
+    for _, p := range []models.Position{
+        {AccountID: 1, Symbol: "ABC", AcquiredDate: "2026-01-16", WheelID: &wheel.WheelID},
+        {AccountID: 1, Symbol: "ABC", AcquiredDate: "2026-02-02", Status: "CLOSED"},
+        {AccountID: 1, Symbol: "XYZ", AcquiredDate: "2026-03-02"},
+        {AccountID: other, Symbol: "ABC", AcquiredDate: "2026-04-01"},
+    } {
+        p.Shares, p.CostBasisPerShare = 100, 50
+        if err := positions.Create(ctx, &p); err != nil {
+            t.Fatalf("create position: %v", err)
+        }
+        created = append(created, p.PositionID)
+    }
+    assigned, sold, xyz, otherLot := created[0], created[1], created[2], created[3]
+
+    tests := []struct {
+        name   string
+        filter PositionFilter
+        want   []int
+    }{
+        {"no filter, latest bought first", PositionFilter{}, []int{otherLot, xyz, sold, assigned}},
+        {"status", PositionFilter{Status: "OPEN"}, []int{otherLot, xyz, assigned}},
+        {"account", PositionFilter{AccountID: 1}, []int{xyz, sold, assigned}},
+        {"symbol", PositionFilter{Symbol: "XYZ"}, []int{xyz}},
+        {"wheel", PositionFilter{WheelID: wheel.WheelID}, []int{assigned}},
+        {"combined", PositionFilter{AccountID: 1, Symbol: "ABC", Status: "OPEN"}, []int{assigned}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := positions.List(ctx, tt.filter)
+            if err != nil {
+                t.Fatalf("list: %v", err)
+            }
+            if ids := ids(got, func(p models.Position) int { return p.PositionID }); !sameIDs(ids, tt.want) {
+                t.Errorf("positions = %v, want %v", ids, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,77 @@
+// Package repository holds the SQL for every table the handlers and services
+// touch, so each query and its Scan live in exactly one place.
+package repository
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+)
+
+// ErrNotFound is returned when a lookup or update matches no row
+var ErrNotFound = errors.New("not found")
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so a repository can run
+// standalone or inside a transaction owned by the caller
+type DBTX interface {
+    ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+    QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+    QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+    Scan(dest ...interface{}) error
+}
+
+// queryList runs query and scans every row with scan
+func queryList[T any](ctx context.Context, db DBTX, scan func(scanner) (T, error), query string, args ...interface{}) ([]T, error) {
+    rows, err := db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    items := make([]T, 0)
+    for rows.Next() {
+        item, err := scan(rows)
+        if err != nil {
+            return nil, err
+        }
+        items = append(items, item)
+    }
+    return items, rows.Err()
+}
+
+// queryOne scans a single row, mapping sql.ErrNoRows to ErrNotFound
+func queryOne[T any](ctx context.Context, db DBTX, scan func(scanner) (T, error), query string, args ...interface{}) (T, error) {
+    item, err := scan(db.QueryRowContext(ctx, query, args...))
+    if errors.Is(err, sql.ErrNoRows) {
+        return item, ErrNotFound
+    }
+    return item, err
+}
+
+// requireRows turns an update or delete that touched nothing into ErrNotFound
+func requireRows(result sql.Result, err error) error {
+    if err != nil {
+        return err
+    }
+    n, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// insertID returns the new row id of an INSERT
+func insertID(result sql.Result, err error) (int, error) {
+    if err != nil {
+        return 0, err
+    }
+    id, err := result.LastInsertId()
+    return int(id), err
+}
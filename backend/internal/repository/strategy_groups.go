@@ -0,0 +1,94 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const strategyGroupColumns = `
+    group_id, account_id, symbol, strategy, position_id, open_date, close_date,
+    status, notes, created_at, updated_at`
+
+func scanStrategyGroup(s scanner) (models.StrategyGroup, error) {
+    var g models.StrategyGroup
+    err := s.Scan(&g.GroupID, &g.AccountID, &g.Symbol, &g.Strategy, &g.PositionID,
+        &g.OpenDate, &g.CloseDate, &g.Status, &g.Notes, &g.CreatedAt, &g.UpdatedAt)
+    return g, err
+}
+
+// StrategyGroupFilter narrows List; zero values are ignored
+type StrategyGroupFilter struct {
+    Status    string
+    AccountID int
+    Symbol    string
+}
+
+type StrategyGroupRepo struct {
+    db DBTX
+}
+
+func NewStrategyGroupRepo(db DBTX) *StrategyGroupRepo {
+    return &StrategyGroupRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *StrategyGroupRepo) WithTx(tx *sql.Tx) *StrategyGroupRepo {
+    return &StrategyGroupRepo{db: tx}
+}
+
+// List returns strategy groups, newest first
+func (r *StrategyGroupRepo) List(ctx context.Context, f StrategyGroupFilter) ([]models.StrategyGroup, error) {
+    query := "SELECT " + strategyGroupColumns + " FROM strategy_groups WHERE 1=1"
+    var args []interface{}
+
+    if f.Status != "" {
+        query += " AND status = ?"
+        args = append(args, f.Status)
+    }
+    if f.AccountID != 0 {
+        query += " AND account_id = ?"
+        args = append(args, f.AccountID)
+    }
+    if f.Symbol != "" {
+        query += " AND symbol = ?"
+        args = append(args, f.Symbol)
+    }
+    query += " ORDER BY open_date DESC, group_id DESC"
+
+    return queryList(ctx, r.db, scanStrategyGroup, query, args...)
+}
+
+func (r *StrategyGroupRepo) Get(ctx context.Context, id int) (models.StrategyGroup, error) {
+    return queryOne(ctx, r.db, scanStrategyGroup, "SELECT "+strategyGroupColumns+" FROM strategy_groups WHERE group_id = ?", id)
+}
+
+// Create inserts an OPEN group and sets its GroupID
+func (r *StrategyGroupRepo) Create(ctx context.Context, g *models.StrategyGroup) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO strategy_groups (account_id, symbol, strategy, position_id, open_date, status, notes)
+        VALUES (?, ?, ?, ?, ?, 'OPEN', ?)
+    `, g.AccountID, g.Symbol, g.Strategy, g.PositionID, g.OpenDate, g.Notes))
+    if err != nil {
+        return err
+    }
+    g.GroupID = id
+    g.Status = "OPEN"
+    return nil
+}
+
+// Close marks the group CLOSED once its last leg is closed
+func (r *StrategyGroupRepo) Close(ctx context.Context, id int, closeDate string) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE strategy_groups SET status = 'CLOSED', close_date = ?, updated_at = CURRENT_TIMESTAMP WHERE group_id = ?
+    `, closeDate, id))
+}
+
+// RenameSymbol moves the OPEN groups of a symbol to its new ticker
+func (r *StrategyGroupRepo) RenameSymbol(ctx context.Context, symbol, newSymbol string) error {
+    _, err := r.db.ExecContext(ctx, `
+        UPDATE strategy_groups SET symbol = ?, updated_at = CURRENT_TIMESTAMP WHERE symbol = ? AND status = 'OPEN'
+    `, newSymbol, symbol)
+    return err
+}
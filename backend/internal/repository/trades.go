@@ -0,0 +1,194 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const tradeColumns = `
+    trade_id, account_id, symbol, trade_type, contracts, strike_price,
+    premium_per_share, delta, open_date, expiration_date, close_date, close_method,
+    close_price, fees, status, tags, notes, wheel_id, parent_trade_id, roll_group,
+    underlying_price, side, strategy_group_id, split_from_trade_id, deliverable, created_at, updated_at`
+
+func scanTrade(s scanner) (models.Trade, error) {
+    var t models.Trade
+    err := s.Scan(&t.TradeID, &t.AccountID, &t.Symbol, &t.TradeType, &t.Contracts,
+        &t.StrikePrice, &t.PremiumPerShare, &t.Delta, &t.OpenDate, &t.ExpirationDate,
+        &t.CloseDate, &t.CloseMethod, &t.ClosePrice, &t.Fees, &t.Status,
+        &t.Tags, &t.Notes, &t.WheelID, &t.ParentTradeID, &t.RollGroup,
+        &t.UnderlyingPrice, &t.Side, &t.StrategyGroupID, &t.SplitFromTradeID,
+        &t.Deliverable, &t.CreatedAt, &t.UpdatedAt)
+    return t, err
+}
+
+// TradeFilter narrows List; zero values are ignored
+type TradeFilter struct {
+    Status          string
+    AccountID       int
+    Symbol          string
+    WheelID         int
+    StrategyGroupID int
+}
+
+// TradeClose holds the values written when a trade is closed
+type TradeClose struct {
+    CloseDate   string
+    CloseMethod string
+    ClosePrice  float64
+    Fees        float64
+    Notes       string
+}
+
+type TradeRepo struct {
+    db DBTX
+}
+
+func NewTradeRepo(db DBTX) *TradeRepo {
+    return &TradeRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *TradeRepo) WithTx(tx *sql.Tx) *TradeRepo {
+    return &TradeRepo{db: tx}
+}
+
+func (r *TradeRepo) List(ctx context.Context, f TradeFilter) ([]models.Trade, error) {
+    query := "SELECT " + tradeColumns + " FROM trades WHERE 1=1"
+    var args []interface{}
+
+    if f.Status != "" {
+        query += " AND status = ?"
+        args = append(args, f.Status)
+    }
+    if f.AccountID != 0 {
+        query += " AND account_id = ?"
+        args = append(args, f.AccountID)
+    }
+    if f.Symbol != "" {
+        query += " AND symbol = ?"
+        args = append(args, f.Symbol)
+    }
+    if f.StrategyGroupID != 0 {
+        query += " AND strategy_group_id = ?"
+        args = append(args, f.StrategyGroupID)
+    }
+    if f.WheelID != 0 {
+        query += " AND wheel_id = ?"
+        args = append(args, f.WheelID)
+    }
+    if f.WheelID != 0 || f.StrategyGroupID != 0 {
+        query += " ORDER BY open_date, trade_id"
+    } else {
+        query += " ORDER BY expiration_date DESC"
+    }
+
+    return queryList(ctx, r.db, scanTrade, query, args...)
+}
+
+func (r *TradeRepo) Get(ctx context.Context, id int) (models.Trade, error) {
+    return queryOne(ctx, r.db, scanTrade, "SELECT "+tradeColumns+" FROM trades WHERE trade_id = ?", id)
+}
+
+// Create inserts t and sets its TradeID. An empty status defaults to OPEN
+// and an empty side to STO.
+func (r *TradeRepo) Create(ctx context.Context, t *models.Trade) error {
+    if t.Status == "" {
+        t.Status = "OPEN"
+    }
+    if t.Side == "" {
+        t.Side = "STO"
+    }
+    if t.Deliverable == 0 {
+        t.Deliverable = 100
+    }
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO trades (
+            account_id, symbol, trade_type, contracts, strike_price,
+            premium_per_share, delta, open_date, expiration_date, close_date,
+            close_method, close_price, fees, status, tags, notes, wheel_id,
+            parent_trade_id, roll_group, underlying_price, side, strategy_group_id,
+            split_from_trade_id, deliverable
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `,
+        t.AccountID, t.Symbol, t.TradeType, t.Contracts, t.StrikePrice,
+        t.PremiumPerShare, t.Delta, t.OpenDate, t.ExpirationDate, t.CloseDate,
+        t.CloseMethod, t.ClosePrice, t.Fees, t.Status, t.Tags, t.Notes, t.WheelID,
+        t.ParentTradeID, t.RollGroup, t.UnderlyingPrice, t.Side, t.StrategyGroupID,
+        t.SplitFromTradeID, t.Deliverable,
+    ))
+    if err != nil {
+        return err
+    }
+    t.TradeID = id
+    return nil
+}
+
+// Update rewrites the editable fields of a trade; a zero deliverable keeps the stored one
+func (r *TradeRepo) Update(ctx context.Context, t models.Trade) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE trades
+        SET symbol = ?, trade_type = ?, contracts = ?, strike_price = ?, premium_per_share = ?,
+            delta = ?, fees = ?, tags = ?, notes = ?, underlying_price = ?,
+            deliverable = COALESCE(NULLIF(?, 0), deliverable), updated_at = CURRENT_TIMESTAMP
+        WHERE trade_id = ?
+    `, t.Symbol, t.TradeType, t.Contracts, t.StrikePrice, t.PremiumPerShare,
+        t.Delta, t.Fees, t.Tags, t.Notes, t.UnderlyingPrice, t.Deliverable, t.TradeID))
+}
+
+// Close marks an OPEN trade as CLOSED; ErrNotFound means missing or already closed
+func (r *TradeRepo) Close(ctx context.Context, id int, c TradeClose) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE trades
+        SET close_date = ?, close_method = ?, close_price = ?, fees = ?, status = 'CLOSED',
+            notes = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE trade_id = ? AND status = 'OPEN'
+    `, c.CloseDate, c.CloseMethod, c.ClosePrice, c.Fees, c.Notes, id))
+}
+
+// ListUnlinked returns the trades that belong to no wheel, oldest first
+func (r *TradeRepo) ListUnlinked(ctx context.Context) ([]models.Trade, error) {
+    return queryList(ctx, r.db, scanTrade, "SELECT "+tradeColumns+" FROM trades WHERE wheel_id IS NULL ORDER BY open_date, trade_id")
+}
+
+// SetWheel attaches a trade to a wheel
+func (r *TradeRepo) SetWheel(ctx context.Context, id int, wheelID int) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE trades SET wheel_id = ?, updated_at = CURRENT_TIMESTAMP WHERE trade_id = ?
+    `, wheelID, id))
+}
+
+// ListRollGroup returns every trade of a roll chain in the order they were opened
+func (r *TradeRepo) ListRollGroup(ctx context.Context, group int) ([]models.Trade, error) {
+    return queryList(ctx, r.db, scanTrade, "SELECT "+tradeColumns+" FROM trades WHERE roll_group = ? ORDER BY open_date, trade_id", group)
+}
+
+// SetRollGroup attaches a trade to a roll chain
+func (r *TradeRepo) SetRollGroup(ctx context.Context, id int, group int) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE trades SET roll_group = ?, updated_at = CURRENT_TIMESTAMP WHERE trade_id = ?
+    `, group, id))
+}
+
+// ListExpired returns the OPEN trades whose expiration date is before asOf (YYYY-MM-DD)
+func (r *TradeRepo) ListExpired(ctx context.Context, asOf string) ([]models.Trade, error) {
+    return queryList(ctx, r.db, scanTrade, "SELECT "+tradeColumns+" FROM trades WHERE status = 'OPEN' AND date(expiration_date) < date(?) ORDER BY expiration_date, trade_id", asOf)
+}
+
+// SetUnderlyingPrice stores the last known price of the trade's underlying
+func (r *TradeRepo) SetUnderlyingPrice(ctx context.Context, id int, price float64) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE trades SET underlying_price = ?, updated_at = CURRENT_TIMESTAMP WHERE trade_id = ?
+    `, price, id))
+}
+
+func (r *TradeRepo) Delete(ctx context.Context, id int) error {
+    return requireRows(r.db.ExecContext(ctx, "DELETE FROM trades WHERE trade_id = ?", id))
+}
+
+func (r *TradeRepo) DeleteByAccount(ctx context.Context, accountID int) error {
+    _, err := r.db.ExecContext(ctx, "DELETE FROM trades WHERE account_id = ?", accountID)
+    return err
+}
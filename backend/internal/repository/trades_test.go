@@ -0,0 +1,67 @@
+package repository
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestTradeListFilters(t *testing.T) {
+    db := newTestDB(t)
+    ctx := context.Background()
+    other := createTestAccount(t, db, "Second")
+
+    wheel := models.Wheel{AccountID: 1, Symbol: "ABC", StartDate: "2026-01-05"}
+    if err := NewWheelRepo(db).Create(ctx, &wheel); err != nil {
+        t.Fatalf("create wheel: %v", err)
+    }
+    trades := NewTradeRepo(db)
+    var created []int
+    for _, tr := range []models.Trade{
+        {AccountID: 1, Symbol: "ABC", TradeType: "CSP", ExpirationDate: "2026-02-20", WheelID: &wheel.WheelID},
+        {AccountID: 1, Symbol: "ABC", TradeType: "CC", ExpirationDate: "2026-03-20", WheelID: &wheel.WheelID, Status: "CLOSED"},
+        {AccountID: 1, Symbol: "XYZ", TradeType: "CSP", ExpirationDate: "2026-04-17"},
+        {AccountID: other, Symbol: "ABC", TradeType: "CSP", ExpirationDate: "2026-05-15"},
+    } {
+        tr.Contracts, tr.StrikePrice, tr.PremiumPerShare, tr.OpenDate = 1, 50, 1, "2026-01-05"
+        if err := trades.Create(ctx, &tr); err != nil {
+            t.Fatalf("create trade: %v", err)
+        }
+        created = append(created, tr.TradeID)
+    }
+    abcPut, abcCall, xyzPut, otherPut := created[0], created[1], created[2], created[3]
+
+    tests := []struct {
+        name   string
+        filter TradeFilter
+        want   []int
+    }{
+        {"no filter, latest expiration first", TradeFilter{}, []int{otherPut, xyzPut, abcCall, abcPut}},
+        {"status", TradeFilter{Status: "OPEN"}, []int{otherPut, xyzPut, abcPut}},
+        {"account", TradeFilter{AccountID: 1}, []int{xyzPut, abcCall, abcPut}},
+        {"symbol", TradeFilter{Symbol: "ABC"}, []int{otherPut, abcCall, abcPut}},
+        {"wheel, in the order opened", TradeFilter{WheelID: wheel.WheelID}, []int{abcPut, abcCall}},
+        {"combined", TradeFilter{AccountID: 1, Symbol: "ABC", Status: "CLOSED"}, []int{abcCall}},
+        {"nothing matches", TradeFilter{Symbol: "NONE"}, []int{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := trades.List(ctx, tt.filter)
+            if err != nil {
+                t.Fatalf("list: %v", err)
+            }
+            if ids := ids(got, func(tr models.Trade) int { return tr.TradeID }); !sameIDs(ids, tt.want) {
+                t.Errorf("trades = %v, want %v", ids, tt.want)
+            }
+        })
+    }
+}
+
+func TestTradeGetMissing(t *testing.T) {
+    db := newTestDB(t)
+    if _, err := NewTradeRepo(db).Get(context.Background(), 42); !errors.Is(err, ErrNotFound) {
+        t.Errorf("err = %v, want ErrNotFound", err)
+    }
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const wheelSummaryColumns = `
+    wheel_id, account_id, symbol, start_date, end_date, days_held, trade_count,
+    total_premium, realized_pnl, capital_at_risk, return_on_capital, annualized_return, created_at`
+
+func scanWheelSummary(s scanner) (models.WheelSummary, error) {
+    var w models.WheelSummary
+    err := s.Scan(&w.WheelID, &w.AccountID, &w.Symbol, &w.StartDate, &w.EndDate,
+        &w.DaysHeld, &w.TradeCount, &w.TotalPremium, &w.RealizedPnL,
+        &w.CapitalAtRisk, &w.ReturnOnCapital, &w.AnnualizedReturn, &w.CreatedAt)
+    return w, err
+}
+
+// WheelSummaryFilter narrows List; zero values are ignored
+type WheelSummaryFilter struct {
+    AccountID int
+    Symbol    string
+}
+
+// WheelSummaryRepo stores one performance snapshot per closed wheel
+type WheelSummaryRepo struct {
+    db DBTX
+}
+
+func NewWheelSummaryRepo(db DBTX) *WheelSummaryRepo {
+    return &WheelSummaryRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *WheelSummaryRepo) WithTx(tx *sql.Tx) *WheelSummaryRepo {
+    return &WheelSummaryRepo{db: tx}
+}
+
+// List returns snapshots, best annualized return first
+func (r *WheelSummaryRepo) List(ctx context.Context, f WheelSummaryFilter) ([]models.WheelSummary, error) {
+    query := "SELECT " + wheelSummaryColumns + " FROM wheel_summaries WHERE 1=1"
+    var args []interface{}
+
+    if f.AccountID != 0 {
+        query += " AND account_id = ?"
+        args = append(args, f.AccountID)
+    }
+    if f.Symbol != "" {
+        query += " AND symbol = ?"
+        args = append(args, f.Symbol)
+    }
+    query += " ORDER BY annualized_return DESC, wheel_id"
+
+    return queryList(ctx, r.db, scanWheelSummary, query, args...)
+}
+
+func (r *WheelSummaryRepo) Get(ctx context.Context, wheelID int) (models.WheelSummary, error) {
+    return queryOne(ctx, r.db, scanWheelSummary, "SELECT "+wheelSummaryColumns+" FROM wheel_summaries WHERE wheel_id = ?", wheelID)
+}
+
+// Save stores the snapshot of a wheel, replacing one taken at an earlier close
+func (r *WheelSummaryRepo) Save(ctx context.Context, w models.WheelSummary) error {
+    _, err := r.db.ExecContext(ctx, `
+        INSERT OR REPLACE INTO wheel_summaries (
+            wheel_id, account_id, symbol, start_date, end_date, days_held, trade_count,
+            total_premium, realized_pnl, capital_at_risk, return_on_capital, annualized_return
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, w.WheelID, w.AccountID, w.Symbol, w.StartDate, w.EndDate, w.DaysHeld, w.TradeCount,
+        w.TotalPremium, w.RealizedPnL, w.CapitalAtRisk, w.ReturnOnCapital, w.AnnualizedReturn)
+    return err
+}
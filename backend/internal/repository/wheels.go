@@ -0,0 +1,113 @@
+package repository
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+const wheelColumns = `
+    wheel_id, account_id, symbol, start_date, end_date, status,
+    current_phase, total_premium, total_pnl, created_at, updated_at`
+
+func scanWheel(s scanner) (models.Wheel, error) {
+    var w models.Wheel
+    err := s.Scan(&w.WheelID, &w.AccountID, &w.Symbol, &w.StartDate,
+        &w.EndDate, &w.Status, &w.CurrentPhase, &w.TotalPremium,
+        &w.TotalPnL, &w.CreatedAt, &w.UpdatedAt)
+    return w, err
+}
+
+type WheelRepo struct {
+    db DBTX
+}
+
+func NewWheelRepo(db DBTX) *WheelRepo {
+    return &WheelRepo{db: db}
+}
+
+// WithTx returns a copy of the repository bound to tx
+func (r *WheelRepo) WithTx(tx *sql.Tx) *WheelRepo {
+    return &WheelRepo{db: tx}
+}
+
+// List returns the wheels with the given status, newest first
+func (r *WheelRepo) List(ctx context.Context, status string) ([]models.Wheel, error) {
+    return queryList(ctx, r.db, scanWheel, "SELECT "+wheelColumns+" FROM wheels WHERE status = ? ORDER BY start_date DESC", status)
+}
+
+// FindOpen returns the newest ACTIVE, not yet COMPLETED wheel of an account
+// and symbol; ErrNotFound means a new wheel has to be started
+func (r *WheelRepo) FindOpen(ctx context.Context, accountID int, symbol string) (models.Wheel, error) {
+    return queryOne(ctx, r.db, scanWheel, `
+        SELECT `+wheelColumns+` FROM wheels
+        WHERE account_id = ? AND symbol = ? AND status = 'ACTIVE' AND COALESCE(current_phase, 'CSP') != 'COMPLETED'
+        ORDER BY start_date DESC, wheel_id DESC
+        LIMIT 1
+    `, accountID, symbol)
+}
+
+func (r *WheelRepo) Get(ctx context.Context, id int) (models.Wheel, error) {
+    return queryOne(ctx, r.db, scanWheel, "SELECT "+wheelColumns+" FROM wheels WHERE wheel_id = ?", id)
+}
+
+// Create inserts a new ACTIVE wheel in the CSP phase and sets its WheelID
+func (r *WheelRepo) Create(ctx context.Context, w *models.Wheel) error {
+    id, err := insertID(r.db.ExecContext(ctx, `
+        INSERT INTO wheels (account_id, symbol, start_date, status, current_phase, total_premium, total_pnl)
+        VALUES (?, ?, ?, 'ACTIVE', 'CSP', 0.0, 0.0)
+    `, w.AccountID, w.Symbol, w.StartDate))
+    if err != nil {
+        return err
+    }
+    phase := "CSP"
+    w.WheelID = id
+    w.Status = "ACTIVE"
+    w.CurrentPhase = &phase
+    return nil
+}
+
+// SetStatus marks the wheel ACTIVE or CLOSED
+func (r *WheelRepo) SetStatus(ctx context.Context, id int, status string) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE wheels SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE wheel_id = ?
+    `, status, id))
+}
+
+// SetPhase moves the wheel to phase without touching its totals
+func (r *WheelRepo) SetPhase(ctx context.Context, id int, phase string) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE wheels SET current_phase = ?, updated_at = CURRENT_TIMESTAMP WHERE wheel_id = ?
+    `, phase, id))
+}
+
+// SetTotals stores the premium and P&L rolled up from the wheel's trades and positions
+func (r *WheelRepo) SetTotals(ctx context.Context, id int, totalPremium, totalPnL float64) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE wheels SET total_premium = ?, total_pnl = ?, updated_at = CURRENT_TIMESTAMP WHERE wheel_id = ?
+    `, totalPremium, totalPnL, id))
+}
+
+// Complete moves the wheel to the COMPLETED phase ending on endDate
+func (r *WheelRepo) Complete(ctx context.Context, id int, endDate string) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE wheels SET current_phase = 'COMPLETED', end_date = ?, updated_at = CURRENT_TIMESTAMP WHERE wheel_id = ?
+    `, endDate, id))
+}
+
+// Close marks the wheel CLOSED and COMPLETED, ending on endDate
+func (r *WheelRepo) Close(ctx context.Context, id int, endDate string) error {
+    return requireRows(r.db.ExecContext(ctx, `
+        UPDATE wheels SET status = 'CLOSED', current_phase = 'COMPLETED', end_date = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE wheel_id = ?
+    `, endDate, id))
+}
+
+// RenameSymbol moves the ACTIVE wheels of a symbol to its new ticker
+func (r *WheelRepo) RenameSymbol(ctx context.Context, symbol, newSymbol string) error {
+    _, err := r.db.ExecContext(ctx, `
+        UPDATE wheels SET symbol = ?, updated_at = CURRENT_TIMESTAMP WHERE symbol = ? AND status = 'ACTIVE'
+    `, newSymbol, symbol)
+    return err
+}
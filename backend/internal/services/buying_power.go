@@ -0,0 +1,174 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// BuyingPower breaks down what an account can still commit. Open short puts
+// reserve their strike notional and open strategies their maximum loss, or
+// the notional of their short puts when the loss is unlimited. A margin
+// account gets margin_multiplier times its cash and only has to hold the
+// reserve divided by that multiplier as equity.
+type BuyingPower struct {
+    AccountID           int     `json:"account_id"`
+    AccountType         string  `json:"account_type"`
+    MarginMultiplier    float64 `json:"margin_multiplier"`
+    CashBalance         float64 `json:"cash_balance"`
+    TotalBuyingPower    float64 `json:"total_buying_power"`
+    OpenCSPs            int     `json:"open_csps"`
+    CSPCollateral       float64 `json:"csp_collateral"`
+    StrategyRequirement float64 `json:"strategy_requirement"`
+    Reserved            float64 `json:"reserved"`
+    MarginRequirement   float64 `json:"margin_requirement"`
+    FreeBuyingPower     float64 `json:"free_buying_power"`
+}
+
+// GetBuyingPower returns the buying power breakdown of an account
+func (s *TradeService) GetBuyingPower(ctx context.Context, accountID int) (*BuyingPower, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    acc, err := s.accounts.WithTx(tx).Get(ctx, accountID)
+    if err != nil {
+        return nil, err
+    }
+    return s.buyingPowerOf(ctx, tx, acc)
+}
+
+// buyingPowerOf works out the breakdown of acc from its open trades and strategies
+func (s *TradeService) buyingPowerOf(ctx context.Context, tx *sql.Tx, acc models.Account) (*BuyingPower, error) {
+    bp := &BuyingPower{
+        AccountID:        acc.AccountID,
+        AccountType:      acc.AccountType,
+        MarginMultiplier: marginMultiplier(acc),
+        CashBalance:      acc.CurrentBalance,
+        TotalBuyingPower: buyingPower(acc),
+    }
+
+    open, err := s.trades.WithTx(tx).List(ctx, repository.TradeFilter{AccountID: acc.AccountID, Status: "OPEN"})
+    if err != nil {
+        return nil, err
+    }
+    for _, t := range open {
+        if t.StrategyGroupID == nil && isShortPut(t) {
+            bp.OpenCSPs++
+            bp.CSPCollateral += pnl.Capital(t)
+        }
+    }
+
+    groups, err := s.strategies.WithTx(tx).List(ctx, repository.StrategyGroupFilter{AccountID: acc.AccountID, Status: "OPEN"})
+    if err != nil {
+        return nil, err
+    }
+    for _, g := range groups {
+        strategy, err := s.loadStrategy(ctx, tx, g.GroupID)
+        if err != nil {
+            return nil, err
+        }
+        bp.StrategyRequirement += strategyRequirement(strategy)
+    }
+
+    bp.Reserved = bp.CSPCollateral + bp.StrategyRequirement
+    bp.MarginRequirement = bp.Reserved / bp.MarginMultiplier
+    bp.FreeBuyingPower = bp.TotalBuyingPower - bp.Reserved
+    return bp, nil
+}
+
+// checkBuyingPower compares what opening t would commit with the free buying
+// power of its account. The returned message is empty when the account can
+// afford t.
+func (s *TradeService) checkBuyingPower(ctx context.Context, tx *sql.Tx, t models.Trade) (string, error) {
+    return s.checkCommitment(ctx, tx, t.AccountID, commitment(t))
+}
+
+// checkCommitment compares required, the collateral a change adds to an
+// account, with its free buying power. A change that adds none always passes.
+func (s *TradeService) checkCommitment(ctx context.Context, tx *sql.Tx, accountID int, required float64) (string, error) {
+    if required <= 0 {
+        return "", nil
+    }
+    free, err := s.freeBuyingPower(ctx, tx, accountID)
+    if err != nil {
+        return "", err
+    }
+    return shortfall(free, required), nil
+}
+
+// commitment is what t holds of its account's buying power while open, as
+// a trade without a status is stored. A bought leg commits the premium and
+// fees it pays and a short put its strike notional, unless it is a strategy
+// leg reserved through its strategy; a covered call commits nothing.
+func commitment(t models.Trade) float64 {
+    switch {
+    case t.Status == "CLOSED":
+        return 0
+    case t.Side == "BTO":
+        return -pnl.Premium(t) + t.Fees
+    case isShortPut(t) && t.StrategyGroupID == nil:
+        return pnl.Capital(t)
+    }
+    return 0
+}
+
+// freeBuyingPower is what an account can still commit
+func (s *TradeService) freeBuyingPower(ctx context.Context, tx *sql.Tx, accountID int) (float64, error) {
+    acc, err := s.accounts.WithTx(tx).Get(ctx, accountID)
+    if err != nil {
+        return 0, err
+    }
+    bp, err := s.buyingPowerOf(ctx, tx, acc)
+    if err != nil {
+        return 0, err
+    }
+    return bp.FreeBuyingPower, nil
+}
+
+// shortfall describes why free buying power does not cover required, or is
+// empty when it does
+func shortfall(free, required float64) string {
+    if required <= free {
+        return ""
+    }
+    return fmt.Sprintf("Insufficient buying power. Available: %.2f, Required: %.2f", free, required)
+}
+
+// strategyRequirement is what an open strategy reserves: its maximum loss, or
+// the notional of its open short puts when the loss is unlimited
+func strategyRequirement(strategy *Strategy) float64 {
+    if strategy.Risk.MaxLoss != nil {
+        return *strategy.Risk.MaxLoss
+    }
+    var required float64
+    for _, leg := range strategy.Legs {
+        if leg.Status == "OPEN" && isShortPut(leg) {
+            required += pnl.Capital(leg)
+        }
+    }
+    return required
+}
+
+// isShortPut reports whether t is a put sold to open, which has to be secured
+func isShortPut(t models.Trade) bool {
+    return t.Side != "BTO" && (t.TradeType == "CSP" || t.TradeType == "PUT")
+}
+
+func marginMultiplier(acc models.Account) float64 {
+    if acc.AccountType == "margin" && acc.MarginMultiplier > 1 {
+        return acc.MarginMultiplier
+    }
+    return 1
+}
+
+// buyingPower is the cash an account can commit before anything is reserved
+func buyingPower(acc models.Account) float64 {
+    return acc.CurrentBalance * marginMultiplier(acc)
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestRollChecksNetNewCollateral(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    put := openTestTrade(t, s, models.Trade{
+        Symbol:          "ABC",
+        TradeType:       "CSP",
+        Contracts:       1,
+        StrikePrice:     90,
+        PremiumPerShare: 1,
+        OpenDate:        "2026-01-05",
+        ExpirationDate:  "2026-01-16",
+    })
+
+    // Only 1100 is free, but buying the put back releases its 9000
+    rolled, err := s.RollTrade(ctx, put.TradeID, RollTradeRequest{
+        CloseDate:       "2026-01-12",
+        ClosePrice:      1,
+        StrikePrice:     95,
+        ExpirationDate:  "2026-02-20",
+        PremiumPerShare: 2,
+    }, false)
+    if err != nil {
+        t.Fatalf("roll within buying power: %v", err)
+    }
+
+    req := RollTradeRequest{
+        CloseDate:       "2026-01-20",
+        ClosePrice:      1,
+        StrikePrice:     95,
+        ExpirationDate:  "2026-03-20",
+        PremiumPerShare: 3,
+        Contracts:       2,
+    }
+    _, err = s.RollTrade(ctx, rolled.Opened.TradeID, req, false)
+    var invalid *ValidationError
+    if !errors.As(err, &invalid) {
+        t.Fatalf("rolling into 19000 of collateral: err = %v, want a validation error", err)
+    }
+    // The rejected roll left the trade open and the ledger untouched
+    assertBalanced(t, s, 10000+100-100+200)
+
+    rolled, err = s.RollTrade(ctx, rolled.Opened.TradeID, req, true)
+    if err != nil {
+        t.Fatalf("roll with allow_shortfall: %v", err)
+    }
+    if len(rolled.Opened.Warnings) != 1 {
+        t.Errorf("warnings = %v, want the shortfall", rolled.Opened.Warnings)
+    }
+}
+
+func TestOpenStrategyChecksBuyingPower(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    req := StrategyRequest{
+        AccountID:      1,
+        Symbol:         "ABC",
+        Strategy:       "STRANGLE",
+        OpenDate:       "2026-01-05",
+        ExpirationDate: "2026-02-20",
+        Legs: []StrategyLeg{
+            {Side: "STO", TradeType: "PUT", StrikePrice: 150, PremiumPerShare: 2, Contracts: 1},
+            {Side: "STO", TradeType: "CALL", StrikePrice: 170, PremiumPerShare: 2, Contracts: 1},
+        },
+    }
+    _, err := s.OpenStrategy(ctx, req, false)
+    var invalid *ValidationError
+    if !errors.As(err, &invalid) {
+        t.Fatalf("opening a strangle securing 15000: err = %v, want a validation error", err)
+    }
+    assertBalanced(t, s, 10000)
+
+    strategy, err := s.OpenStrategy(ctx, req, true)
+    if err != nil {
+        t.Fatalf("open with allow_shortfall: %v", err)
+    }
+    if len(strategy.Warnings) != 1 {
+        t.Errorf("warnings = %v, want the shortfall", strategy.Warnings)
+    }
+}
+
+// Scaling in, editing and importing commit collateral just as opening does,
+// so each is checked on what it adds
+func TestChangesCheckAddedCollateral(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    put := openTestTrade(t, s, models.Trade{
+        Symbol:          "ABC",
+        TradeType:       "CSP",
+        Contracts:       1,
+        StrikePrice:     60,
+        PremiumPerShare: 1,
+        OpenDate:        "2026-01-05",
+        ExpirationDate:  "2026-02-20",
+    })
+    // 10100 in cash, 6000 of it reserved
+    var invalid *ValidationError
+
+    fill := ExecutionRequest{Action: "OPEN", Contracts: 1, Price: 1, ExecutedAt: "2026-01-06"}
+    if _, err := s.AddExecution(ctx, put.TradeID, fill, false); !errors.As(err, &invalid) {
+        t.Fatalf("scaling into 12000 of collateral: err = %v, want a validation error", err)
+    }
+    result, err := s.AddExecution(ctx, put.TradeID, fill, true)
+    if err != nil {
+        t.Fatalf("scale in with allow_shortfall: %v", err)
+    }
+    if len(result.Trade.Warnings) != 1 {
+        t.Errorf("warnings = %v, want the shortfall", result.Trade.Warnings)
+    }
+
+    edit := result.Trade
+    edit.StrikePrice = 61
+    if err := s.UpdateTrade(ctx, &edit, false); !errors.As(err, &invalid) {
+        t.Fatalf("raising the strike while short: err = %v, want a validation error", err)
+    }
+    // Lowering it frees collateral and needs no check
+    edit.StrikePrice = 40
+    if err := s.UpdateTrade(ctx, &edit, false); err != nil {
+        t.Fatalf("lower the strike: %v", err)
+    }
+    if len(edit.Warnings) != 0 {
+        t.Errorf("warnings = %v, want none", edit.Warnings)
+    }
+
+    // 2000 is left free; the import commits 3000
+    imported := []models.Trade{{
+        AccountID:       1,
+        Symbol:          "XYZ",
+        TradeType:       "CSP",
+        Contracts:       1,
+        StrikePrice:     30,
+        PremiumPerShare: 0.5,
+        OpenDate:        "2026-01-07",
+        ExpirationDate:  "2026-02-20",
+        Status:          "OPEN",
+    }}
+    if _, failures, err := s.SaveTradesTransaction(ctx, imported, false); err == nil || len(failures) != 1 {
+        t.Fatalf("importing past buying power: failures = %v, err = %v, want one failure", failures, err)
+    }
+    if n, _, err := s.SaveTradesTransaction(ctx, imported, true); err != nil || n != 1 {
+        t.Fatalf("import with allow_shortfall: imported %d, err = %v", n, err)
+    }
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "math"
+    "strings"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// CorporateActionRequest is the payload of POST /corporate-actions. A split
+// or reverse split turns split_from old shares into split_to new ones; a
+// symbol change moves everything open to new_symbol.
+type CorporateActionRequest struct {
+    ActionType    string `json:"action_type"`
+    Symbol        string `json:"symbol"`
+    SplitFrom     int    `json:"split_from"`
+    SplitTo       int    `json:"split_to"`
+    NewSymbol     string `json:"new_symbol"`
+    EffectiveDate string `json:"effective_date"`
+    Notes         string `json:"notes"`
+}
+
+// ApplyCorporateAction records a corporate action and applies it, across
+// every account, to the positions and trades of the symbol that are open and
+// were entered on or before the effective date. Closed rows, lot sales and
+// the ledger are history and keep their values.
+//
+// A split multiplies the shares of a position and divides its basis, keeping
+// its total cost; fractions of a share are dropped and their cost stays with
+// the whole shares. Options follow the OCC: a whole-number forward split
+// multiplies contracts and divides strike and premium, fills included, while
+// any other ratio keeps them and changes the shares each contract delivers.
+// Either way the premium and strike notional of a trade do not change.
+func (s *TradeService) ApplyCorporateAction(ctx context.Context, req CorporateActionRequest) (*models.CorporateAction, error) {
+    req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+    req.NewSymbol = strings.ToUpper(strings.TrimSpace(req.NewSymbol))
+    if req.EffectiveDate == "" {
+        req.EffectiveDate = today()
+    }
+    if _, err := time.Parse(dateLayout, req.EffectiveDate); err != nil {
+        return nil, invalidf("effective_date must be YYYY-MM-DD")
+    }
+    if req.Symbol == "" {
+        return nil, invalidf("symbol is required")
+    }
+
+    action := models.CorporateAction{
+        Symbol:        req.Symbol,
+        ActionType:    req.ActionType,
+        EffectiveDate: req.EffectiveDate,
+    }
+    switch req.ActionType {
+    case "SPLIT", "REVERSE_SPLIT":
+        if req.SplitFrom <= 0 || req.SplitTo <= 0 {
+            return nil, invalidf("split_from and split_to must be positive")
+        }
+        if req.ActionType == "SPLIT" && req.SplitTo <= req.SplitFrom {
+            return nil, invalidf("a split gives more shares: split_to must be greater than split_from")
+        }
+        if req.ActionType == "REVERSE_SPLIT" && req.SplitTo >= req.SplitFrom {
+            return nil, invalidf("a reverse split gives fewer shares: split_to must be less than split_from")
+        }
+        action.SplitFrom, action.SplitTo = &req.SplitFrom, &req.SplitTo
+    case "SYMBOL_CHANGE":
+        if req.NewSymbol == "" || req.NewSymbol == req.Symbol {
+            return nil, invalidf("new_symbol is required and must differ from symbol")
+        }
+        action.NewSymbol = &req.NewSymbol
+    default:
+        return nil, invalidf("action_type must be SPLIT, REVERSE_SPLIT or SYMBOL_CHANGE")
+    }
+    if req.Notes != "" {
+        action.Notes = &req.Notes
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    actions := s.actions.WithTx(tx)
+    applied, err := actions.Exists(ctx, req.Symbol, req.ActionType, req.EffectiveDate)
+    if err != nil {
+        return nil, err
+    }
+    if applied {
+        return nil, invalidf("a %s of %s on %s was already applied", req.ActionType, req.Symbol, req.EffectiveDate)
+    }
+    if err := actions.Create(ctx, &action); err != nil {
+        return nil, err
+    }
+
+    wheels := make(map[int]bool)
+    if err := s.adjustPositions(ctx, tx, &action, wheels); err != nil {
+        return nil, err
+    }
+    if err := s.adjustTrades(ctx, tx, &action, wheels); err != nil {
+        return nil, err
+    }
+    if action.ActionType == "SYMBOL_CHANGE" {
+        if err := s.wheels.WithTx(tx).RenameSymbol(ctx, req.Symbol, req.NewSymbol); err != nil {
+            return nil, err
+        }
+        if err := s.strategies.WithTx(tx).RenameSymbol(ctx, req.Symbol, req.NewSymbol); err != nil {
+            return nil, err
+        }
+    }
+    for id := range wheels {
+        if _, err := s.syncWheel(ctx, tx, id, false); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return &action, nil
+}
+
+// adjustPositions applies action to the open lots of its symbol
+func (s *TradeService) adjustPositions(ctx context.Context, tx *sql.Tx, action *models.CorporateAction, wheels map[int]bool) error {
+    positions := s.positions.WithTx(tx)
+    open, err := positions.List(ctx, repository.PositionFilter{Status: "OPEN", Symbol: action.Symbol})
+    if err != nil {
+        return err
+    }
+    for _, p := range open {
+        if dateOnly(p.AcquiredDate) > action.EffectiveDate {
+            continue
+        }
+        positionID := p.PositionID
+        adj := models.CorporateActionAdjustment{
+            ActionID:    action.ActionID,
+            PositionID:  &positionID,
+            OldSymbol:   p.Symbol,
+            NewSymbol:   p.Symbol,
+            OldQuantity: p.Shares,
+            NewQuantity: p.Shares,
+            OldPrice:    p.CostBasisPerShare,
+            NewPrice:    p.CostBasisPerShare,
+        }
+        if action.NewSymbol != nil {
+            adj.NewSymbol = *action.NewSymbol
+            if err := positions.SetSymbol(ctx, p.PositionID, adj.NewSymbol); err != nil {
+                return err
+            }
+        } else {
+            from, to := *action.SplitFrom, *action.SplitTo
+            adj.NewQuantity = p.Shares * to / from
+            if adj.NewQuantity == 0 {
+                return invalidf("position %d holds %d shares, less than one after a %d-for-%d split", p.PositionID, p.Shares, to, from)
+            }
+            adj.NewPrice = p.CostBasisPerShare * float64(p.Shares) / float64(adj.NewQuantity)
+            if err := positions.Split(ctx, p.PositionID, adj.NewQuantity, adj.NewPrice, float64(to)/float64(from)); err != nil {
+                return err
+            }
+        }
+        if err := s.actions.WithTx(tx).CreateAdjustment(ctx, &adj); err != nil {
+            return err
+        }
+        action.Adjustments = append(action.Adjustments, adj)
+        if p.WheelID != nil {
+            wheels[*p.WheelID] = true
+        }
+    }
+    return nil
+}
+
+// adjustTrades applies action to the open options of its symbol and posts
+// them again, which leaves the ledger as it was
+func (s *TradeService) adjustTrades(ctx context.Context, tx *sql.Tx, action *models.CorporateAction, wheels map[int]bool) error {
+    trades := s.trades.WithTx(tx)
+    open, err := trades.List(ctx, repository.TradeFilter{Status: "OPEN", Symbol: action.Symbol})
+    if err != nil {
+        return err
+    }
+    var posted []int
+    for _, t := range open {
+        if dateOnly(t.OpenDate) > action.EffectiveDate {
+            continue
+        }
+        tradeID, oldDeliverable := t.TradeID, t.Deliverable
+        adj := models.CorporateActionAdjustment{
+            ActionID:       action.ActionID,
+            TradeID:        &tradeID,
+            OldSymbol:      t.Symbol,
+            OldQuantity:    t.Contracts,
+            OldPrice:       t.StrikePrice,
+            OldDeliverable: &oldDeliverable,
+        }
+        if action.NewSymbol != nil {
+            t.Symbol = *action.NewSymbol
+        } else if from, to := *action.SplitFrom, *action.SplitTo; to%from == 0 {
+            factor := to / from
+            t.Contracts *= factor
+            t.StrikePrice /= float64(factor)
+            t.PremiumPerShare /= float64(factor)
+            if err := s.executions.WithTx(tx).Split(ctx, t.TradeID, factor); err != nil {
+                return err
+            }
+        } else {
+            t.Deliverable = int(math.Round(float64(t.Deliverable*to) / float64(from)))
+            if t.Deliverable == 0 {
+                return invalidf("trade %d would deliver no shares after a %d-for-%d split", t.TradeID, to, from)
+            }
+        }
+        if err := trades.Update(ctx, t); err != nil {
+            return err
+        }
+        newDeliverable := t.Deliverable
+        adj.NewSymbol, adj.NewQuantity, adj.NewPrice = t.Symbol, t.Contracts, t.StrikePrice
+        adj.NewDeliverable = &newDeliverable
+        if err := s.actions.WithTx(tx).CreateAdjustment(ctx, &adj); err != nil {
+            return err
+        }
+        action.Adjustments = append(action.Adjustments, adj)
+        posted = append(posted, t.TradeID)
+        if t.WheelID != nil {
+            wheels[*t.WheelID] = true
+        }
+    }
+    return s.postTrades(ctx, tx, posted...)
+}
+
+// ListCorporateActions returns the actions recorded for a symbol, under its
+// old or new ticker, or for every symbol when it is empty
+func (s *TradeService) ListCorporateActions(ctx context.Context, symbol string) ([]models.CorporateAction, error) {
+    return s.actions.List(ctx, strings.ToUpper(symbol))
+}
+
+// GetCorporateAction returns an action with what it changed
+func (s *TradeService) GetCorporateAction(ctx context.Context, id int) (*models.CorporateAction, error) {
+    action, err := s.actions.Get(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if action.Adjustments, err = s.actions.ListAdjustments(ctx, id); err != nil {
+        return nil, err
+    }
+    return &action, nil
+}
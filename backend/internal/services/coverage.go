@@ -0,0 +1,120 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "sort"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// coverageKey is the account and symbol whose shares the open calls cover
+type coverageKey struct {
+    accountID int
+    symbol    string
+}
+
+// AnnotateCoverage sets how many shares of each open position the open short
+// calls of its account and symbol cover. Calls bought to open cover calls
+// sold first, as in a call spread; what is left covers the lots oldest first.
+func (s *TradeService) AnnotateCoverage(ctx context.Context, positions []models.Position) error {
+    covered := make(map[int]int)
+    seen := make(map[coverageKey]bool)
+    for _, p := range positions {
+        key := coverageKey{p.AccountID, p.Symbol}
+        if p.Status != "OPEN" || seen[key] {
+            continue
+        }
+        seen[key] = true
+
+        lots, err := s.positions.List(ctx, repository.PositionFilter{Status: "OPEN", AccountID: key.accountID, Symbol: key.symbol})
+        if err != nil {
+            return err
+        }
+        trades, err := s.trades.List(ctx, repository.TradeFilter{Status: "OPEN", AccountID: key.accountID, Symbol: key.symbol})
+        if err != nil {
+            return err
+        }
+        for id, shares := range allocateCoverage(lots, shortCallShares(trades)) {
+            covered[id] = shares
+        }
+    }
+
+    for i := range positions {
+        p := &positions[i]
+        if p.Status != "OPEN" {
+            continue
+        }
+        c := covered[p.PositionID]
+        uncovered := p.Shares - c
+        p.CoveredShares, p.UncoveredShares = &c, &uncovered
+        p.IsCovered = p.Shares > 0 && uncovered == 0
+    }
+    return nil
+}
+
+// checkCoverage warns when opening t, a call sold to open, would leave its
+// account short more calls than it holds shares of the symbol. The returned
+// message is empty when every call is covered.
+func (s *TradeService) checkCoverage(ctx context.Context, tx *sql.Tx, t models.Trade) (string, error) {
+    if t.Side == "BTO" || !isCallType(t.TradeType) {
+        return "", nil
+    }
+    lots, err := s.positions.WithTx(tx).List(ctx, repository.PositionFilter{Status: "OPEN", AccountID: t.AccountID, Symbol: t.Symbol})
+    if err != nil {
+        return "", err
+    }
+    trades, err := s.trades.WithTx(tx).List(ctx, repository.TradeFilter{Status: "OPEN", AccountID: t.AccountID, Symbol: t.Symbol})
+    if err != nil {
+        return "", err
+    }
+
+    held := 0
+    for _, p := range lots {
+        held += p.Shares
+    }
+    t.Status = "OPEN"
+    short := shortCallShares(append(trades, t))
+    if short <= held {
+        return "", nil
+    }
+    return fmt.Sprintf("Naked call: %d shares of %s held against %d shares of open short calls", held, t.Symbol, short), nil
+}
+
+// shortCallShares is how many shares the open calls sold in trades can be
+// called for, less those the open calls bought can deliver instead
+func shortCallShares(trades []models.Trade) int {
+    shares := 0
+    for _, t := range trades {
+        if t.Status != "OPEN" || !isCallType(t.TradeType) {
+            continue
+        }
+        if t.Side == "BTO" {
+            shares -= pnl.Shares(t)
+        } else {
+            shares += pnl.Shares(t)
+        }
+    }
+    return max(shares, 0)
+}
+
+// allocateCoverage spreads calls shares over lots, oldest first, and returns
+// the shares covered by position
+func allocateCoverage(lots []models.Position, calls int) map[int]int {
+    sort.SliceStable(lots, func(i, j int) bool {
+        if lots[i].AcquiredDate != lots[j].AcquiredDate {
+            return lots[i].AcquiredDate < lots[j].AcquiredDate
+        }
+        return lots[i].PositionID < lots[j].PositionID
+    })
+    covered := make(map[int]int, len(lots))
+    for _, p := range lots {
+        take := min(calls, p.Shares)
+        covered[p.PositionID] = take
+        calls -= take
+    }
+    return covered
+}
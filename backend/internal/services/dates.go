@@ -0,0 +1,18 @@
+package services
+
+import "time"
+
+const dateLayout = "2006-01-02"
+
+// dateOnly trims a DATE column to YYYY-MM-DD; the sqlite driver hands DATE
+// values back as full RFC3339 timestamps
+func dateOnly(s string) string {
+    if len(s) > len(dateLayout) {
+        return s[:len(dateLayout)]
+    }
+    return s
+}
+
+func today() string {
+    return time.Now().Format(dateLayout)
+}
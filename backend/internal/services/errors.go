@@ -0,0 +1,16 @@
+package services
+
+import "fmt"
+
+// ValidationError marks a request the caller has to fix; handlers answer 400
+type ValidationError struct {
+    Message string
+}
+
+func (e *ValidationError) Error() string {
+    return e.Message
+}
+
+func invalidf(format string, args ...interface{}) error {
+    return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
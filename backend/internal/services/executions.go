@@ -0,0 +1,436 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// executionLayout is how executed_at is stored, so fills sort by time as text
+const executionLayout = "2006-01-02 15:04:05"
+
+// ExecutionRequest is the payload of POST /trades/:id/executions. ExecutedAt
+// takes a date, a date and time or RFC3339, and defaults to now.
+type ExecutionRequest struct {
+    Action     string  `json:"action"`
+    Contracts  int     `json:"contracts"`
+    Price      float64 `json:"price"`
+    Fees       float64 `json:"fees"`
+    ExecutedAt string  `json:"executed_at"`
+}
+
+// ExecutionImport is one row of an executions CSV: a fill for an existing trade
+type ExecutionImport struct {
+    TradeID int
+    ExecutionRequest
+}
+
+// ExecutionResult is a trade after a change to its fills. Close is set by a
+// closing fill and describes the contracts it closed.
+type ExecutionResult struct {
+    Trade      models.Trade       `json:"trade"`
+    Executions []models.Execution `json:"executions"`
+    Close      *CloseTradeResult  `json:"close,omitempty"`
+}
+
+// fillTotals is what a trade's fills add up to
+type fillTotals struct {
+    OpenContracts  int
+    OpenPrice      float64 // average weighted by contracts
+    OpenFees       float64
+    CloseContracts int
+    ClosePrice     float64 // average weighted by contracts
+    Fees           float64
+}
+
+func sumFills(fills []models.Execution) fillTotals {
+    var f fillTotals
+    var openValue, closeValue float64
+    for _, e := range fills {
+        f.Fees += e.Fees
+        if e.Action == "OPEN" {
+            f.OpenContracts += e.Contracts
+            f.OpenFees += e.Fees
+            openValue += e.Price * float64(e.Contracts)
+            continue
+        }
+        f.CloseContracts += e.Contracts
+        closeValue += e.Price * float64(e.Contracts)
+    }
+    if f.OpenContracts > 0 {
+        f.OpenPrice = openValue / float64(f.OpenContracts)
+    }
+    if f.CloseContracts > 0 {
+        f.ClosePrice = closeValue / float64(f.CloseContracts)
+    }
+    return f
+}
+
+// parseExecutedAt normalizes the accepted timestamp forms to executionLayout
+func parseExecutedAt(s string) (string, error) {
+    if s == "" {
+        return time.Now().Format(executionLayout), nil
+    }
+    for _, layout := range []string{time.RFC3339, executionLayout, dateLayout} {
+        if at, err := time.Parse(layout, s); err == nil {
+            return at.Format(executionLayout), nil
+        }
+    }
+    return "", invalidf("executed_at must be YYYY-MM-DD, YYYY-MM-DD HH:MM:SS or RFC3339")
+}
+
+// ListExecutions returns the fills of a trade. A trade entered without fills
+// has none; its first fill is created when another one is added.
+func (s *TradeService) ListExecutions(ctx context.Context, tradeID int) ([]models.Execution, error) {
+    if _, err := s.trades.Get(ctx, tradeID); err != nil {
+        return nil, err
+    }
+    return s.executions.List(ctx, tradeID)
+}
+
+// AddExecution records a fill of an OPEN trade. OPEN fills scale into the
+// position, and the trade's contracts, average premium and fees are derived
+// from them. A CLOSE fill closes its contracts at its own price right away:
+// short of the whole trade, they are split off into a trade of their own
+// while the rest stays open on this one. An OPEN fill the account cannot
+// afford is rejected unless allowShortfall is set, as in OpenTrade.
+func (s *TradeService) AddExecution(ctx context.Context, tradeID int, req ExecutionRequest, allowShortfall bool) (*ExecutionResult, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    result, err := s.addExecution(ctx, tx, tradeID, req, allowShortfall)
+    if err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// ImportExecutions appends the fills of an executions CSV in one
+// transaction; any failing row rolls back all of them. allowShortfall lets
+// OPEN fills through that the account cannot afford, as in AddExecution.
+func (s *TradeService) ImportExecutions(ctx context.Context, rows []ExecutionImport, allowShortfall bool) (int, []string, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return 0, nil, fmt.Errorf("failed to begin transaction: %v", err)
+    }
+    defer tx.Rollback()
+
+    var imported int
+    var failures []string
+    for i, row := range rows {
+        result, err := s.addExecution(ctx, tx, row.TradeID, row.ExecutionRequest, allowShortfall)
+        if err != nil {
+            if errors.Is(err, repository.ErrNotFound) {
+                err = fmt.Errorf("trade not found")
+            }
+            failures = append(failures, fmt.Sprintf("Execution %d (trade %d): %v", i+1, row.TradeID, err))
+            continue
+        }
+        for _, w := range result.Trade.Warnings {
+            log.Printf("Execution %d (trade %d) imported with a warning: %s", i+1, row.TradeID, w)
+        }
+        imported++
+    }
+
+    if len(failures) > 0 {
+        return imported, failures, fmt.Errorf("transaction rolled back due to errors")
+    }
+    if err := tx.Commit(); err != nil {
+        return 0, nil, fmt.Errorf("failed to commit transaction: %v", err)
+    }
+    return imported, failures, nil
+}
+
+// DeleteExecution removes a fill of an OPEN trade and derives the trade again
+// from the fills left. The last opening fill cannot be removed, and nothing
+// can once a closing fill has been booked.
+func (s *TradeService) DeleteExecution(ctx context.Context, tradeID, executionID int) (*ExecutionResult, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    t, err := s.trades.WithTx(tx).Get(ctx, tradeID)
+    if err != nil {
+        return nil, err
+    }
+    if t.Status != "OPEN" {
+        return nil, invalidf("trade %d is closed; its fills can no longer change", tradeID)
+    }
+    executions := s.executions.WithTx(tx)
+    fills, err := executions.List(ctx, tradeID)
+    if err != nil {
+        return nil, err
+    }
+    if sumFills(fills).CloseContracts > 0 {
+        return nil, invalidf("trade %d has closing fills already booked; its fills can no longer change", tradeID)
+    }
+    if err := executions.Delete(ctx, tradeID, executionID); err != nil {
+        return nil, err
+    }
+    if fills, err = executions.List(ctx, tradeID); err != nil {
+        return nil, err
+    }
+    if sumFills(fills).OpenContracts == 0 {
+        return nil, invalidf("execution %d is the last opening fill of trade %d", executionID, tradeID)
+    }
+
+    result, err := s.applyFills(ctx, tx, t, fills)
+    if err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// addExecution does the work of AddExecution inside the caller's transaction
+func (s *TradeService) addExecution(ctx context.Context, tx *sql.Tx, tradeID int, req ExecutionRequest, allowShortfall bool) (*ExecutionResult, error) {
+    switch {
+    case req.Action != "OPEN" && req.Action != "CLOSE":
+        return nil, invalidf("action must be OPEN or CLOSE")
+    case req.Contracts <= 0:
+        return nil, invalidf("contracts must be positive")
+    case req.Price < 0 || req.Fees < 0:
+        return nil, invalidf("price and fees cannot be negative")
+    }
+    executedAt, err := parseExecutedAt(req.ExecutedAt)
+    if err != nil {
+        return nil, err
+    }
+
+    t, err := s.trades.WithTx(tx).Get(ctx, tradeID)
+    if err != nil {
+        return nil, err
+    }
+    switch {
+    case t.Status != "OPEN":
+        return nil, invalidf("trade %d is closed; its fills can no longer change", tradeID)
+    case req.Action == "CLOSE" && t.StrategyGroupID != nil:
+        return nil, invalidf("trade %d is a leg of strategy %d; close the strategy instead", tradeID, *t.StrategyGroupID)
+    case executedAt[:len(dateLayout)] < dateOnly(t.OpenDate):
+        return nil, invalidf("executed_at cannot be before the trade opened on %s", dateOnly(t.OpenDate))
+    }
+
+    executions := s.executions.WithTx(tx)
+    fills, err := executions.List(ctx, tradeID)
+    if err != nil {
+        return nil, err
+    }
+    if len(fills) == 0 {
+        // The trade as entered becomes its first fill
+        first := models.Execution{
+            TradeID:    tradeID,
+            Action:     "OPEN",
+            Contracts:  t.Contracts,
+            Price:      t.PremiumPerShare,
+            Fees:       t.Fees,
+            ExecutedAt: dateOnly(t.OpenDate) + " 00:00:00",
+        }
+        if err := executions.Create(ctx, &first); err != nil {
+            return nil, err
+        }
+        fills = append(fills, first)
+    }
+    if req.Action == "OPEN" && sumFills(fills).CloseContracts > 0 {
+        return nil, invalidf("trade %d already has closing fills; open a new trade instead", tradeID)
+    }
+    if req.Action == "CLOSE" && req.Contracts > t.Contracts {
+        return nil, invalidf("the closing fill covers %d contracts but only %d are open on trade %d", req.Contracts, t.Contracts, tradeID)
+    }
+    var shortfall string
+    if req.Action == "OPEN" {
+        // The fill commits what opening its contracts alone would
+        added := t
+        added.Contracts, added.PremiumPerShare, added.Fees = req.Contracts, req.Price, req.Fees
+        if shortfall, err = s.checkBuyingPower(ctx, tx, added); err != nil {
+            return nil, err
+        }
+        if shortfall != "" && !allowShortfall {
+            return nil, invalidf("%s", shortfall)
+        }
+    }
+
+    fill := models.Execution{
+        TradeID:    tradeID,
+        Action:     req.Action,
+        Contracts:  req.Contracts,
+        Price:      req.Price,
+        Fees:       req.Fees,
+        ExecutedAt: executedAt,
+    }
+    if err := executions.Create(ctx, &fill); err != nil {
+        return nil, err
+    }
+    if req.Action == "CLOSE" {
+        return s.closeFill(ctx, tx, t, fill)
+    }
+    result, err := s.applyFills(ctx, tx, t, append(fills, fill))
+    if err != nil {
+        return nil, err
+    }
+    if shortfall != "" {
+        result.Trade.Warnings = []string{shortfall}
+    }
+    return result, nil
+}
+
+// closeFill closes the contracts of a closing fill on its day. Contracts
+// short of the whole trade are split off first and take the fill with them,
+// along with the earliest opening fills that cover them, so each trade is
+// priced from its own fills and the ledger debits the buyback of exactly
+// what was closed. The close price is the average of the closing fills.
+func (s *TradeService) closeFill(ctx context.Context, tx *sql.Tx, t models.Trade, fill models.Execution) (*ExecutionResult, error) {
+    closeID := t.TradeID
+    var remaining *int
+    if fill.Contracts < t.Contracts {
+        part, err := s.splitTrade(ctx, tx, t, fill.Contracts)
+        if err != nil {
+            return nil, err
+        }
+        if err := s.moveFills(ctx, tx, t, part, fill); err != nil {
+            return nil, err
+        }
+        closeID, remaining = part.TradeID, &t.TradeID
+    }
+
+    fills, err := s.executions.WithTx(tx).List(ctx, closeID)
+    if err != nil {
+        return nil, err
+    }
+    totals := sumFills(fills)
+    method := "BTC"
+    if t.Side == "BTO" {
+        method = "STC"
+    }
+    closed, err := s.closeTrade(ctx, tx, closeID, CloseTradeRequest{
+        CloseDate:   dateOnly(fill.ExecutedAt),
+        CloseMethod: method,
+        ClosePrice:  totals.ClosePrice,
+        Fees:        totals.Fees - totals.OpenFees,
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    result := &ExecutionResult{Close: closed}
+    if remaining != nil {
+        // splitTrade moved contracts and fees off the trade left open
+        if err := s.postTrades(ctx, tx, *remaining); err != nil {
+            return nil, err
+        }
+        rest, err := s.trades.WithTx(tx).Get(ctx, *remaining)
+        if err != nil {
+            return nil, err
+        }
+        closed.Remaining = &rest
+    }
+    if result.Trade, err = s.trades.WithTx(tx).Get(ctx, t.TradeID); err != nil {
+        return nil, err
+    }
+    if result.Executions, err = s.executions.WithTx(tx).List(ctx, t.TradeID); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// moveFills hands part, just split off t, the closing fill and the earliest
+// opening fills of t that add up to its contracts, carving the last one in
+// two when it covers more. Both trades are then priced from their own
+// opening fills.
+func (s *TradeService) moveFills(ctx context.Context, tx *sql.Tx, t, part models.Trade, closing models.Execution) error {
+    executions := s.executions.WithTx(tx)
+    fills, err := executions.List(ctx, t.TradeID)
+    if err != nil {
+        return err
+    }
+    need := part.Contracts
+    for _, e := range fills {
+        if e.Action != "OPEN" || need == 0 {
+            continue
+        }
+        if e.Contracts > need {
+            // The fees of the fill are shared in proportion to contracts
+            carved := e
+            carved.TradeID = part.TradeID
+            carved.Contracts = need
+            carved.Fees = e.Fees * float64(need) / float64(e.Contracts)
+            if err := executions.Create(ctx, &carved); err != nil {
+                return err
+            }
+            e.Contracts -= need
+            e.Fees -= carved.Fees
+            need = 0
+        } else {
+            e.TradeID = part.TradeID
+            need -= e.Contracts
+        }
+        if err := executions.Update(ctx, e); err != nil {
+            return err
+        }
+    }
+    closing.TradeID = part.TradeID
+    if err := executions.Update(ctx, closing); err != nil {
+        return err
+    }
+
+    trades := s.trades.WithTx(tx)
+    for _, tr := range []models.Trade{t, part} {
+        if fills, err = executions.List(ctx, tr.TradeID); err != nil {
+            return err
+        }
+        totals := sumFills(fills)
+        tr.Contracts = totals.OpenContracts
+        tr.PremiumPerShare = totals.OpenPrice
+        tr.Fees = totals.OpenFees
+        if err := trades.Update(ctx, tr); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// applyFills writes what the opening fills add up to onto t, which has no
+// closing fills yet, and posts its cash again
+func (s *TradeService) applyFills(ctx context.Context, tx *sql.Tx, t models.Trade, fills []models.Execution) (*ExecutionResult, error) {
+    totals := sumFills(fills)
+    trades := s.trades.WithTx(tx)
+    var err error
+    t.Contracts = totals.OpenContracts
+    t.PremiumPerShare = totals.OpenPrice
+    t.Fees = totals.Fees
+    if err = trades.Update(ctx, t); err != nil {
+        return nil, err
+    }
+    if err := s.postTrades(ctx, tx, t.TradeID); err != nil {
+        return nil, err
+    }
+    if t.WheelID != nil {
+        if _, err := s.syncWheel(ctx, tx, *t.WheelID, false); err != nil {
+            return nil, err
+        }
+    }
+
+    result := &ExecutionResult{}
+    if result.Trade, err = trades.Get(ctx, t.TradeID); err != nil {
+        return nil, err
+    }
+    if result.Executions, err = s.executions.WithTx(tx).List(ctx, t.TradeID); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+    "context"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestPartialClosingFill(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    trade := openTestTrade(t, s, models.Trade{
+        Symbol:          "ABC",
+        TradeType:       "CSP",
+        Contracts:       3,
+        StrikePrice:     30,
+        PremiumPerShare: 2,
+        Fees:            3,
+        OpenDate:        "2026-01-05",
+        ExpirationDate:  "2026-02-20",
+    })
+    // 10000 + 3 × 200 premium - 3 fees
+    assertBalanced(t, s, 10597)
+
+    result, err := s.AddExecution(ctx, trade.TradeID, ExecutionRequest{
+        Action:     "CLOSE",
+        Contracts:  1,
+        Price:      0.5,
+        Fees:       1,
+        ExecutedAt: "2026-01-10",
+    }, false)
+    if err != nil {
+        t.Fatalf("add closing fill: %v", err)
+    }
+
+    if result.Trade.Status != "OPEN" || result.Trade.Contracts != 2 {
+        t.Errorf("trade left open = %s with %d contracts, want OPEN with 2", result.Trade.Status, result.Trade.Contracts)
+    }
+    if result.Close == nil || result.Close.Trade.Status != "CLOSED" || result.Close.Trade.Contracts != 1 {
+        t.Fatalf("closing fill did not close 1 contract: %+v", result.Close)
+    }
+    if result.Close.Remaining == nil || result.Close.Remaining.TradeID != trade.TradeID {
+        t.Errorf("remaining = %+v, want trade %d", result.Close.Remaining, trade.TradeID)
+    }
+    // The buyback of one contract and the fill's fees leave the account
+    assertBalanced(t, s, 10597-50-1)
+
+    bp, err := s.GetBuyingPower(ctx, 1)
+    if err != nil {
+        t.Fatalf("buying power: %v", err)
+    }
+    if bp.CSPCollateral != 6000 {
+        t.Errorf("collateral = %.2f, want 6000 for the 2 contracts still open", bp.CSPCollateral)
+    }
+
+    // The last fill closes the rest of the trade itself
+    result, err = s.AddExecution(ctx, trade.TradeID, ExecutionRequest{
+        Action:     "CLOSE",
+        Contracts:  2,
+        Price:      0.25,
+        ExecutedAt: "2026-01-12",
+    }, false)
+    if err != nil {
+        t.Fatalf("add last closing fill: %v", err)
+    }
+    if result.Trade.Status != "CLOSED" || result.Trade.Contracts != 2 {
+        t.Errorf("trade = %s with %d contracts, want CLOSED with 2", result.Trade.Status, result.Trade.Contracts)
+    }
+    assertBalanced(t, s, 10597-50-1-50)
+}
+
+// A closing fill takes the earliest opening fills with it, so the part it
+// closes and the part left open are each priced from their own fills
+func TestClosingFillTakesItsFills(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    trade := openTestTrade(t, s, models.Trade{
+        Symbol:          "ABC",
+        TradeType:       "CSP",
+        Contracts:       1,
+        StrikePrice:     20,
+        PremiumPerShare: 2,
+        Fees:            1,
+        OpenDate:        "2026-01-05",
+        ExpirationDate:  "2026-02-20",
+    })
+    _, err := s.AddExecution(ctx, trade.TradeID, ExecutionRequest{
+        Action:     "OPEN",
+        Contracts:  2,
+        Price:      3,
+        Fees:       2,
+        ExecutedAt: "2026-01-06",
+    }, false)
+    if err != nil {
+        t.Fatalf("add opening fill: %v", err)
+    }
+
+    result, err := s.AddExecution(ctx, trade.TradeID, ExecutionRequest{
+        Action:     "CLOSE",
+        Contracts:  2,
+        Price:      0.5,
+        Fees:       1,
+        ExecutedAt: "2026-01-10",
+    }, false)
+    if err != nil {
+        t.Fatalf("add closing fill: %v", err)
+    }
+
+    // The first fill and half the second went with the closed part
+    closed := result.Close.Trade
+    if closed.TradeID == trade.TradeID || closed.PremiumPerShare != 2.5 || closed.Fees != 3 {
+        t.Errorf("closed part = trade %d at %.2f with %.2f fees, want a new trade at 2.50 with 3.00",
+            closed.TradeID, closed.PremiumPerShare, closed.Fees)
+    }
+    if closed.ClosePrice == nil || *closed.ClosePrice != 0.5 {
+        t.Errorf("close price = %v, want 0.50", closed.ClosePrice)
+    }
+    fills, err := s.ListExecutions(ctx, closed.TradeID)
+    if err != nil {
+        t.Fatalf("list fills of the closed part: %v", err)
+    }
+    if totals := sumFills(fills); totals.OpenContracts != 2 || totals.CloseContracts != 2 {
+        t.Errorf("closed part fills = %+v, want 2 contracts opened and closed", totals)
+    }
+
+    if result.Trade.Contracts != 1 || result.Trade.PremiumPerShare != 3 || result.Trade.Fees != 1 {
+        t.Errorf("trade left open = %d at %.2f with %.2f fees, want 1 at 3.00 with 1.00",
+            result.Trade.Contracts, result.Trade.PremiumPerShare, result.Trade.Fees)
+    }
+    if len(result.Executions) != 1 || result.Executions[0].Contracts != 1 {
+        t.Errorf("fills left open = %+v, want 1 contract", result.Executions)
+    }
+    // 200 + 600 premium - 3 fees, then 100 bought back with 1 fee
+    assertBalanced(t, s, 10000+800-3-100-1)
+}
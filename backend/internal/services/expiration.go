@@ -0,0 +1,175 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// ExpirationRun summarises one pass of the expiration job
+type ExpirationRun struct {
+    AsOf    string                      `json:"as_of"`
+    Expired []models.ExpirationLogEntry `json:"expired"`
+    Flagged []models.ExpirationLogEntry `json:"flagged"`
+    Errors  []string                    `json:"errors"`
+}
+
+// ProcessExpirations handles every OPEN trade that expired before asOf
+// (YYYY-MM-DD, empty means today). Out-of-the-money trades are closed as
+// EXPIRATION at 0; in-the-money ones, and any whose underlying price at
+// expiry is unknown, stay OPEN and are flagged once for assignment review.
+// Flagged trades are checked again on every run, so one whose price is later
+// entered by hand expires then. The stored underlying_price wins over a
+// fetched quote, and a quote is only used when it is from the expiration
+// date: a trade that expired days ago is never judged by today's price.
+func (s *TradeService) ProcessExpirations(ctx context.Context, asOf string) (*ExpirationRun, error) {
+    if asOf == "" {
+        asOf = today()
+    }
+    expired, err := s.trades.ListExpired(ctx, asOf)
+    if err != nil {
+        return nil, err
+    }
+
+    run := &ExpirationRun{
+        AsOf:    asOf,
+        Expired: make([]models.ExpirationLogEntry, 0),
+        Flagged: make([]models.ExpirationLogEntry, 0),
+        Errors:  make([]string, 0),
+    }
+    for _, t := range expired {
+        entry, err := s.processExpiration(ctx, t)
+        if err != nil {
+            run.Errors = append(run.Errors, fmt.Sprintf("Trade %d (%s): %v", t.TradeID, t.Symbol, err))
+            continue
+        }
+        if entry == nil {
+            continue
+        }
+        if entry.Action == "EXPIRED" {
+            run.Expired = append(run.Expired, *entry)
+        } else {
+            run.Flagged = append(run.Flagged, *entry)
+        }
+    }
+    return run, nil
+}
+
+// processExpiration closes or flags a single expired trade. It returns nil
+// when the trade stays flagged by an earlier run.
+func (s *TradeService) processExpiration(ctx context.Context, t models.Trade) (*models.ExpirationLogEntry, error) {
+    price := t.UnderlyingPrice
+    if price == nil {
+        quote, at, err := s.quotes.Quote(ctx, t.Symbol)
+        if err != nil {
+            msg := fmt.Sprintf("Underlying price unavailable (%v); review manually", err)
+            return s.flagExpiration(ctx, t, nil, msg)
+        }
+        // Quotes are stamped with the time of the last trade; a close at
+        // 16:00 New York falls on the same UTC date
+        if at.UTC().Format(dateLayout) != dateOnly(t.ExpirationDate) {
+            msg := fmt.Sprintf("No price of %s from the expiration date, last quote %.2f on %s; set underlying_price to resolve",
+                t.Symbol, quote, at.UTC().Format(dateLayout))
+            return s.flagExpiration(ctx, t, nil, msg)
+        }
+        if err := s.trades.SetUnderlyingPrice(ctx, t.TradeID, quote); err != nil {
+            return nil, err
+        }
+        price = &quote
+    }
+
+    if inTheMoney(t, *price) {
+        msg := fmt.Sprintf("%s %.2f is in the money with %s at %.2f; review for assignment", t.TradeType, t.StrikePrice, t.Symbol, *price)
+        return s.flagExpiration(ctx, t, price, msg)
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    notes := ""
+    if t.Notes != nil {
+        notes = *t.Notes
+    }
+    _, err = s.closeTrade(ctx, tx, t.TradeID, CloseTradeRequest{
+        CloseMethod: "EXPIRATION",
+        ClosePrice:  0,
+        Notes:       notes,
+    })
+    if err != nil {
+        return nil, err
+    }
+    msg := fmt.Sprintf("Expired worthless with %s at %.2f", t.Symbol, *price)
+    entry := &models.ExpirationLogEntry{TradeID: t.TradeID, Action: "EXPIRED", UnderlyingPrice: price, Message: &msg}
+    if err := s.expirations.WithTx(tx).Create(ctx, entry); err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return entry, nil
+}
+
+// flagExpiration logs t for assignment review unless an earlier run already did
+func (s *TradeService) flagExpiration(ctx context.Context, t models.Trade, price *float64, msg string) (*models.ExpirationLogEntry, error) {
+    flagged, err := s.expirations.Flagged(ctx, t.TradeID, "ASSIGNMENT_REVIEW")
+    if err != nil || flagged {
+        return nil, err
+    }
+    entry := &models.ExpirationLogEntry{TradeID: t.TradeID, Action: "ASSIGNMENT_REVIEW", UnderlyingPrice: price, Message: &msg}
+    if err := s.expirations.Create(ctx, entry); err != nil {
+        return nil, err
+    }
+    return entry, nil
+}
+
+// inTheMoney reports whether the option would be exercised with the
+// underlying at price; a trade exactly at the strike expires. The strike is
+// compared per deliverable share, which differs from it after a split.
+func inTheMoney(t models.Trade, price float64) bool {
+    strike := pnl.Capital(t) / float64(pnl.Shares(t))
+    switch t.TradeType {
+    case "CSP", "PUT":
+        return price < strike
+    case "CC", "CALL":
+        return price > strike
+    }
+    return false
+}
+
+// ListExpirationLog returns the audit trail of the expiration job
+func (s *TradeService) ListExpirationLog(ctx context.Context, f repository.ExpirationLogFilter) ([]models.ExpirationLogEntry, error) {
+    return s.expirations.List(ctx, f)
+}
+
+// RunExpirationScheduler processes expirations now and then every day just
+// after midnight until ctx is cancelled
+func (s *TradeService) RunExpirationScheduler(ctx context.Context) {
+    for {
+        run, err := s.ProcessExpirations(ctx, "")
+        if err != nil {
+            log.Printf("Expiration job failed: %v", err)
+        } else {
+            log.Printf("Expiration job %s: %d expired, %d flagged for review, %d errors",
+                run.AsOf, len(run.Expired), len(run.Flagged), len(run.Errors))
+            for _, msg := range run.Errors {
+                log.Printf("Expiration job error: %s", msg)
+            }
+        }
+
+        now := time.Now()
+        next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 5, 0, 0, now.Location())
+        select {
+        case <-ctx.Done():
+            return
+        case <-time.After(next.Sub(now)):
+        }
+    }
+}
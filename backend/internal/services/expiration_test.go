@@ -0,0 +1,90 @@
+package services
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+// fixedQuote answers every symbol with the same price traded at the same time
+type fixedQuote struct {
+    price float64
+    at    time.Time
+}
+
+func (q fixedQuote) Quote(ctx context.Context, symbol string) (float64, time.Time, error) {
+    return q.price, q.at, nil
+}
+
+func TestExpirationNeedsPriceFromExpiry(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    trade := openTestTrade(t, s, models.Trade{
+        Symbol:          "ABC",
+        TradeType:       "CSP",
+        Contracts:       1,
+        StrikePrice:     30,
+        PremiumPerShare: 1,
+        OpenDate:        "2026-01-05",
+        ExpirationDate:  "2026-01-16",
+    })
+
+    // Out of the money today, but nothing says where it closed at expiry
+    s.quotes = fixedQuote{price: 40, at: time.Date(2026, 1, 21, 21, 0, 0, 0, time.UTC)}
+    run, err := s.ProcessExpirations(ctx, "2026-01-22")
+    if err != nil {
+        t.Fatalf("process expirations: %v", err)
+    }
+    if len(run.Expired) != 0 || len(run.Flagged) != 1 {
+        t.Fatalf("run = %+v, want the trade flagged and not expired", run)
+    }
+
+    // Later runs keep it flagged without logging it again
+    run, err = s.ProcessExpirations(ctx, "2026-01-23")
+    if err != nil {
+        t.Fatalf("process expirations: %v", err)
+    }
+    if len(run.Expired) != 0 || len(run.Flagged) != 0 {
+        t.Fatalf("second run = %+v, want nothing new", run)
+    }
+
+    // Once the price at expiry is known the next run expires it
+    if err := s.trades.SetUnderlyingPrice(ctx, trade.TradeID, 31); err != nil {
+        t.Fatalf("set underlying price: %v", err)
+    }
+    run, err = s.ProcessExpirations(ctx, "2026-01-24")
+    if err != nil {
+        t.Fatalf("process expirations: %v", err)
+    }
+    if len(run.Expired) != 1 || run.Expired[0].TradeID != trade.TradeID {
+        t.Fatalf("third run = %+v, want trade %d expired", run, trade.TradeID)
+    }
+}
+
+func TestExpirationUsesQuoteFromExpiry(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    openTestTrade(t, s, models.Trade{
+        Symbol:          "ABC",
+        TradeType:       "CSP",
+        Contracts:       1,
+        StrikePrice:     30,
+        PremiumPerShare: 1,
+        OpenDate:        "2026-01-05",
+        ExpirationDate:  "2026-01-16",
+    })
+
+    // The Friday close, fetched just after midnight
+    s.quotes = fixedQuote{price: 32, at: time.Date(2026, 1, 16, 21, 0, 0, 0, time.UTC)}
+    run, err := s.ProcessExpirations(ctx, "2026-01-17")
+    if err != nil {
+        t.Fatalf("process expirations: %v", err)
+    }
+    if len(run.Expired) != 1 || len(run.Flagged) != 0 {
+        t.Fatalf("run = %+v, want the trade expired", run)
+    }
+}
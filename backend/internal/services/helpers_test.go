@@ -0,0 +1,55 @@
+package services
+
+import (
+    "context"
+    "math"
+    "path/filepath"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/database"
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+// newTestService opens a migrated and seeded database in a temporary
+// directory; account 1 starts with 10000 in cash
+func newTestService(t *testing.T) *TradeService {
+    t.Helper()
+    db, err := database.NewDB(filepath.Join(t.TempDir(), "trades.db"))
+    if err != nil {
+        t.Fatalf("open database: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+    return NewTradeService(db)
+}
+
+// openTestTrade opens t on account 1 and fails the test if it cannot
+func openTestTrade(t *testing.T, s *TradeService, trade models.Trade) models.Trade {
+    t.Helper()
+    trade.AccountID = 1
+    trade.Status = "OPEN"
+    if err := s.OpenTrade(context.Background(), &trade, false); err != nil {
+        t.Fatalf("open trade: %v", err)
+    }
+    return trade
+}
+
+// assertBalanced checks the stored balance of account 1 against want and
+// that its ledger reconciles
+func assertBalanced(t *testing.T, s *TradeService, want float64) {
+    t.Helper()
+    ctx := context.Background()
+    acc, err := s.accounts.Get(ctx, 1)
+    if err != nil {
+        t.Fatalf("get account: %v", err)
+    }
+    if math.Abs(acc.CurrentBalance-want) > ledgerTolerance {
+        t.Errorf("balance = %.2f, want %.2f", acc.CurrentBalance, want)
+    }
+    rec, err := s.Reconcile(ctx, 1)
+    if err != nil {
+        t.Fatalf("reconcile: %v", err)
+    }
+    if !rec.Balanced {
+        t.Errorf("ledger does not reconcile: %+v", rec)
+    }
+}
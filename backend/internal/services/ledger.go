@@ -0,0 +1,404 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "math"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// ledgerTolerance absorbs float noise when comparing posted and expected cash
+const ledgerTolerance = 0.005
+
+// Reconciliation compares an account's stored balance and ledger with the
+// trades and income they should reflect. BalanceDrift is the stored balance
+// less the ledger balance; Entries lists every source whose postings differ
+// from what it implies.
+type Reconciliation struct {
+    AccountID      int           `json:"account_id"`
+    InitialBalance float64       `json:"initial_balance"`
+    LedgerTotal    float64       `json:"ledger_total"`
+    LedgerBalance  float64       `json:"ledger_balance"`
+    StoredBalance  float64       `json:"stored_balance"`
+    BalanceDrift   float64       `json:"balance_drift"`
+    Entries        []LedgerDrift `json:"entries"`
+    Drift          float64       `json:"drift"`
+    Balanced       bool          `json:"balanced"`
+}
+
+// LedgerDrift is one trade, income or position row whose postings of a type
+// do not add up to what the row implies
+type LedgerDrift struct {
+    Source          string  `json:"source"` // trade, income o position
+    SourceID        int     `json:"source_id"`
+    TransactionType string  `json:"transaction_type"`
+    Expected        float64 `json:"expected"`
+    Posted          float64 `json:"posted"`
+    Difference      float64 `json:"difference"`
+}
+
+// tradeEntries is the cash t moves: the premium at open, the price paid or
+// received to close it and its fees. Shares changing hands on assignment are
+// posted by the assignment itself.
+func tradeEntries(t models.Trade) []models.AccountTransaction {
+    tradeID := t.TradeID
+    entries := []models.AccountTransaction{{
+        AccountID:       t.AccountID,
+        TransactionType: "PREMIUM",
+        Amount:          pnl.Premium(t),
+        TransactionDate: dateOnly(t.OpenDate),
+        Notes:           fmt.Sprintf("Premium of trade #%d", t.TradeID),
+        TradeID:         &tradeID,
+    }}
+    feeDate := dateOnly(t.OpenDate)
+    if t.Status == "CLOSED" && t.CloseDate != nil {
+        feeDate = dateOnly(*t.CloseDate)
+        if price := pnl.ClosePrice(t); price != 0 {
+            entries = append(entries, models.AccountTransaction{
+                AccountID:       t.AccountID,
+                TransactionType: "OPTION_CLOSE",
+                Amount:          -pnl.Sign(t) * price * pnl.Multiplier(t),
+                TransactionDate: feeDate,
+                Notes:           fmt.Sprintf("Close of trade #%d", t.TradeID),
+                TradeID:         &tradeID,
+            })
+        }
+    }
+    if t.Fees != 0 {
+        entries = append(entries, models.AccountTransaction{
+            AccountID:       t.AccountID,
+            TransactionType: "FEE",
+            Amount:          -t.Fees,
+            TransactionDate: feeDate,
+            Notes:           fmt.Sprintf("Fees of trade #%d", t.TradeID),
+            TradeID:         &tradeID,
+        })
+    }
+    return entries
+}
+
+// purchaseEntry is the cash spent on the lot rooted at lot: the strike of the
+// put whose assignment bought it, or else the cost of its shares, counting
+// those since sold off onto rows of their own. rows is the lot and those
+// rows; put is nil unless the lot came from an assignment.
+func purchaseEntry(lot models.Position, rows []models.Position, put *models.Trade) models.AccountTransaction {
+    positionID := lot.PositionID
+    if put != nil {
+        return models.AccountTransaction{
+            AccountID:       lot.AccountID,
+            TransactionType: "ASSIGNMENT",
+            Amount:          -pnl.Capital(*put),
+            TransactionDate: dateOnly(lot.AcquiredDate),
+            Notes:           fmt.Sprintf("Assignment of trade #%d into position #%d", put.TradeID, lot.PositionID),
+            PositionID:      &positionID,
+        }
+    }
+    var cost float64
+    for _, r := range rows {
+        cost += r.CostBasisPerShare * float64(r.Shares)
+    }
+    return models.AccountTransaction{
+        AccountID:       lot.AccountID,
+        TransactionType: "STOCK_BUY",
+        Amount:          -cost,
+        TransactionDate: dateOnly(lot.AcquiredDate),
+        Notes:           fmt.Sprintf("Purchase of position #%d", lot.PositionID),
+        PositionID:      &positionID,
+    }
+}
+
+// saleEntry is the cash a lot sale brought in net of its fees. Shares an
+// assigned call took are posted as a call away at the strike.
+func saleEntry(l models.LotSale) models.AccountTransaction {
+    positionID := l.PositionID
+    e := models.AccountTransaction{
+        AccountID:       l.AccountID,
+        TransactionType: "STOCK_SELL",
+        Amount:          l.SalePricePerShare*float64(l.Shares) - l.Fees,
+        TransactionDate: dateOnly(l.SoldDate),
+        Notes:           fmt.Sprintf("Sale of position #%d", l.PositionID),
+        PositionID:      &positionID,
+    }
+    if l.TradeID != nil {
+        e.TransactionType = "CALL_AWAY"
+        e.Notes = fmt.Sprintf("Call away of position #%d by trade #%d", l.PositionID, *l.TradeID)
+    }
+    return e
+}
+
+// incomeEntry is the cash inc brought in; a split moves no cash
+func incomeEntry(inc models.Income) (models.AccountTransaction, bool) {
+    incomeID := inc.IncomeID
+    return models.AccountTransaction{
+        AccountID:       inc.AccountID,
+        TransactionType: "INCOME",
+        Amount:          inc.Amount,
+        TransactionDate: dateOnly(inc.PaymentDate),
+        Notes:           fmt.Sprintf("%s #%d", inc.IncomeType, inc.IncomeID),
+        IncomeID:        &incomeID,
+    }, inc.IncomeType != "SPLIT"
+}
+
+// postTrades replaces what was posted for each trade with what it implies
+// now. Every service that opens, changes or closes a trade calls it in the
+// same transaction, so the ledger never lags behind the trades.
+func (s *TradeService) postTrades(ctx context.Context, tx *sql.Tx, ids ...int) error {
+    accounts := s.accounts.WithTx(tx)
+    for _, id := range ids {
+        t, err := s.trades.WithTx(tx).Get(ctx, id)
+        if err != nil {
+            return err
+        }
+        if err := accounts.DeleteTradeEntries(ctx, id); err != nil {
+            return err
+        }
+        for _, e := range tradeEntries(t) {
+            if err := accounts.Post(ctx, &e); err != nil {
+                return err
+            }
+        }
+        if err := accounts.RefreshBalance(ctx, t.AccountID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// postPositions replaces what was posted for each position with what it
+// implies now: the purchase when it is a lot of its own and the sale that
+// closed it, if any. Every service that buys, sells, edits or removes shares
+// calls it in the same transaction, as postTrades does for options.
+func (s *TradeService) postPositions(ctx context.Context, tx *sql.Tx, ids ...int) error {
+    accounts := s.accounts.WithTx(tx)
+    positions := s.positions.WithTx(tx)
+    posted := make(map[int]bool, len(ids))
+    for _, id := range ids {
+        if posted[id] {
+            continue
+        }
+        posted[id] = true
+
+        p, err := positions.Get(ctx, id)
+        if err != nil {
+            return err
+        }
+        if err := accounts.DeletePositionEntries(ctx, id); err != nil {
+            return err
+        }
+        var entries []models.AccountTransaction
+        if p.SplitFromPositionID == nil {
+            rows, err := positions.ListLot(ctx, id)
+            if err != nil {
+                return err
+            }
+            var put *models.Trade
+            if p.SourceTradeID != nil {
+                t, err := s.trades.WithTx(tx).Get(ctx, *p.SourceTradeID)
+                if err != nil {
+                    return err
+                }
+                put = &t
+            }
+            entries = append(entries, purchaseEntry(p, rows, put))
+        }
+        sales, err := s.lotSales.WithTx(tx).List(ctx, repository.LotSaleFilter{PositionID: id})
+        if err != nil {
+            return err
+        }
+        for _, l := range sales {
+            entries = append(entries, saleEntry(l))
+        }
+        for _, e := range entries {
+            if err := accounts.Post(ctx, &e); err != nil {
+                return err
+            }
+        }
+        if err := accounts.RefreshBalance(ctx, p.AccountID); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// CreateIncome records a dividend or other income and posts its cash
+func (s *TradeService) CreateIncome(ctx context.Context, inc *models.Income) error {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if err := s.income.WithTx(tx).Create(ctx, inc); err != nil {
+        return err
+    }
+    if e, ok := incomeEntry(*inc); ok {
+        if err := s.accounts.WithTx(tx).Post(ctx, &e); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// DeleteIncome removes an income row together with its ledger entry
+func (s *TradeService) DeleteIncome(ctx context.Context, id int) error {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    income := s.income.WithTx(tx)
+    inc, err := income.Get(ctx, id)
+    if err != nil {
+        return err
+    }
+    if err := income.Delete(ctx, id); err != nil {
+        return err
+    }
+    if err := s.accounts.WithTx(tx).RefreshBalance(ctx, inc.AccountID); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// Reconcile rebuilds what the ledger of an account should hold from its
+// trades, income and positions and reports where it, or the stored balance,
+// drifted
+func (s *TradeService) Reconcile(ctx context.Context, accountID int) (*Reconciliation, error) {
+    acc, err := s.accounts.Get(ctx, accountID)
+    if err != nil {
+        return nil, err
+    }
+    ledger, err := s.accounts.ListTransactions(ctx, accountID)
+    if err != nil {
+        return nil, err
+    }
+    trades, err := s.trades.List(ctx, repository.TradeFilter{AccountID: accountID})
+    if err != nil {
+        return nil, err
+    }
+    incomes, err := s.income.List(ctx, repository.IncomeFilter{AccountID: accountID})
+    if err != nil {
+        return nil, err
+    }
+    positions, err := s.positions.List(ctx, repository.PositionFilter{AccountID: accountID})
+    if err != nil {
+        return nil, err
+    }
+    sales, err := s.lotSales.List(ctx, repository.LotSaleFilter{AccountID: accountID})
+    if err != nil {
+        return nil, err
+    }
+
+    type key struct {
+        source string
+        id     int
+        kind   string
+    }
+    posted := make(map[key]float64)
+    expected := make(map[key]float64)
+    var order []key
+    expect := func(k key, amount float64) {
+        if _, seen := expected[k]; !seen {
+            order = append(order, k)
+        }
+        expected[k] += amount
+    }
+
+    r := &Reconciliation{
+        AccountID:      accountID,
+        InitialBalance: acc.InitialBalance,
+        StoredBalance:  acc.CurrentBalance,
+        Entries:        make([]LedgerDrift, 0),
+    }
+    // Deposits, withdrawals and adjustments have no source to check
+    source := func(e models.AccountTransaction) (key, bool) {
+        switch {
+        case e.TradeID != nil:
+            return key{"trade", *e.TradeID, e.TransactionType}, true
+        case e.IncomeID != nil:
+            return key{"income", *e.IncomeID, e.TransactionType}, true
+        case e.PositionID != nil:
+            return key{"position", *e.PositionID, e.TransactionType}, true
+        }
+        return key{}, false
+    }
+    for _, e := range ledger {
+        r.LedgerTotal += e.Amount
+        if k, ok := source(e); ok {
+            posted[k] += e.Amount
+        }
+    }
+    for _, t := range trades {
+        for _, e := range tradeEntries(t) {
+            expect(key{"trade", t.TradeID, e.TransactionType}, e.Amount)
+        }
+    }
+    for _, inc := range incomes {
+        e, ok := incomeEntry(inc)
+        if !ok {
+            continue
+        }
+        expect(key{"income", inc.IncomeID, e.TransactionType}, e.Amount)
+    }
+    byID := make(map[int]models.Trade, len(trades))
+    for _, t := range trades {
+        byID[t.TradeID] = t
+    }
+    lots := make(map[int][]models.Position)
+    for _, p := range positions {
+        lot := p.PositionID
+        if p.SplitFromPositionID != nil {
+            lot = *p.SplitFromPositionID
+        }
+        lots[lot] = append(lots[lot], p)
+    }
+    for _, p := range positions {
+        if p.SplitFromPositionID != nil {
+            continue
+        }
+        var put *models.Trade
+        if p.SourceTradeID != nil {
+            if t, ok := byID[*p.SourceTradeID]; ok {
+                put = &t
+            }
+        }
+        e := purchaseEntry(p, lots[p.PositionID], put)
+        expect(key{"position", p.PositionID, e.TransactionType}, e.Amount)
+    }
+    for _, l := range sales {
+        e := saleEntry(l)
+        expect(key{"position", l.PositionID, e.TransactionType}, e.Amount)
+    }
+    // Postings of a type their source no longer implies
+    for _, e := range ledger {
+        if k, ok := source(e); ok {
+            expect(k, 0)
+        }
+    }
+
+    for _, k := range order {
+        diff := posted[k] - expected[k]
+        if math.Abs(diff) < ledgerTolerance {
+            continue
+        }
+        r.Entries = append(r.Entries, LedgerDrift{
+            Source:          k.source,
+            SourceID:        k.id,
+            TransactionType: k.kind,
+            Expected:        expected[k],
+            Posted:          posted[k],
+            Difference:      diff,
+        })
+        r.Drift += diff
+    }
+
+    r.LedgerBalance = acc.InitialBalance + r.LedgerTotal
+    r.BalanceDrift = acc.CurrentBalance - r.LedgerBalance
+    r.Balanced = len(r.Entries) == 0 && math.Abs(r.BalanceDrift) < ledgerTolerance
+    return r, nil
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// lotMethods are the ways an account can choose the lots a sale consumes
+var lotMethods = map[string]bool{"FIFO": true, "LIFO": true, "HIFO": true, "SPECIFIC": true}
+
+// ValidLotMethod reports whether method is a lot method accounts accept
+func ValidLotMethod(method string) bool {
+    return lotMethods[method]
+}
+
+// lotOrder is a sale of shares out of the open lots matching Filter. Fees
+// are shared between the lots sold by shares. LotIDs sells those lots, in
+// that order, instead of following the account's method. TradeID is the
+// assigned call that forced the sale.
+type lotOrder struct {
+    Filter  repository.PositionFilter
+    Shares  int
+    Price   float64
+    Fees    float64
+    Date    string
+    Note    string
+    LotIDs  []int
+    TradeID *int
+}
+
+// lotSale is what sellLots closed
+type lotSale struct {
+    Positions   []models.Position
+    Sales       []models.LotSale
+    RealizedPnL float64 // net of the order's fees
+    WheelID     *int    // wheel of the first lot sold
+}
+
+// LotSaleReport is the realized gains of a set of lot sales split by holding term
+type LotSaleReport struct {
+    Sales        []models.LotSale `json:"sales"`
+    Proceeds     float64          `json:"proceeds"`
+    CostBasis    float64          `json:"cost_basis"`
+    Fees         float64          `json:"fees"`
+    ShortTermPnL float64          `json:"short_term_pnl"`
+    LongTermPnL  float64          `json:"long_term_pnl"`
+    RealizedPnL  float64          `json:"realized_pnl"`
+}
+
+// ClosePositionRequest is the payload of POST /positions/:id/close. Shares
+// sells only part of the lot; 0 sells all of it.
+type ClosePositionRequest struct {
+    SoldDate          string  `json:"sold_date" binding:"required"`
+    SoldPricePerShare float64 `json:"sold_price_per_share" binding:"required"`
+    Shares            int     `json:"shares"`
+}
+
+// ClosePosition sells shares of one lot as a specific-lot sale, credits the
+// proceeds and refreshes its wheel
+func (s *TradeService) ClosePosition(ctx context.Context, id int, req ClosePositionRequest) ([]models.LotSale, error) {
+    var sales []models.LotSale
+    err := s.inWheelTx(ctx, false, func(tx *sql.Tx) (*int, error) {
+        p, err := s.positions.WithTx(tx).Get(ctx, id)
+        if err != nil {
+            return nil, err
+        }
+        if p.Status != "OPEN" {
+            return nil, invalidf("position %d is already closed", id)
+        }
+        shares := req.Shares
+        if shares == 0 {
+            shares = p.Shares
+        }
+        if shares < 0 || shares > p.Shares {
+            return nil, invalidf("shares must be between 1 and the %d held in position %d", p.Shares, id)
+        }
+
+        sale, err := s.sellLots(ctx, tx, lotOrder{
+            Filter: repository.PositionFilter{Status: "OPEN", AccountID: p.AccountID, Symbol: p.Symbol},
+            Shares: shares,
+            Price:  req.SoldPricePerShare,
+            Date:   req.SoldDate,
+            Note:   "Sold",
+            LotIDs: []int{id},
+        })
+        if err != nil {
+            return nil, err
+        }
+        sales = sale.Sales
+        return p.WheelID, nil
+    })
+    return sales, err
+}
+
+// ListLotSales reports the realized gains of the lot sales matching f
+func (s *TradeService) ListLotSales(ctx context.Context, f repository.LotSaleFilter) (*LotSaleReport, error) {
+    sales, err := s.lotSales.List(ctx, f)
+    if err != nil {
+        return nil, err
+    }
+    report := &LotSaleReport{Sales: sales}
+    for _, l := range sales {
+        report.Proceeds += l.SalePricePerShare * float64(l.Shares)
+        report.CostBasis += l.CostBasisPerShare * float64(l.Shares)
+        report.Fees += l.Fees
+        if l.Term == "LONG" {
+            report.LongTermPnL += l.RealizedPnL
+        } else {
+            report.ShortTermPnL += l.RealizedPnL
+        }
+    }
+    report.RealizedPnL = report.ShortTermPnL + report.LongTermPnL
+    return report, nil
+}
+
+// sellLots closes shares at price from the open lots matching the order, in
+// the order of the account's lot method, and records and posts the gain of
+// each lot.
+// A lot larger than needed is split so the sold part can be closed on its own
+// row; the note prefixes the notes of that new row. An account that sells by
+// specific lot falls back to FIFO when no lots are named, as a broker does
+// for assignments.
+func (s *TradeService) sellLots(ctx context.Context, tx *sql.Tx, o lotOrder) (*lotSale, error) {
+    positions := s.positions.WithTx(tx)
+
+    acc, err := s.accounts.WithTx(tx).Get(ctx, o.Filter.AccountID)
+    if err != nil {
+        return nil, err
+    }
+    method := acc.LotMethod
+    if len(o.LotIDs) > 0 {
+        method = "SPECIFIC"
+    } else if method == "SPECIFIC" {
+        method = "FIFO"
+    }
+
+    open, err := positions.List(ctx, o.Filter)
+    if err != nil {
+        return nil, err
+    }
+    lots, err := orderLots(open, method, o.LotIDs)
+    if err != nil {
+        return nil, err
+    }
+
+    held := 0
+    for _, lot := range lots {
+        held += lot.Shares
+    }
+    if held < o.Shares {
+        return nil, invalidf("not enough %s shares: %d held, %d needed", o.Filter.Symbol, held, o.Shares)
+    }
+
+    result := &lotSale{}
+    remaining := o.Shares
+    for _, lot := range lots {
+        if remaining == 0 {
+            break
+        }
+        if result.WheelID == nil {
+            result.WheelID = lot.WheelID
+        }
+
+        sold := lot
+        sold.Status = "CLOSED"
+        sold.SoldDate = &o.Date
+        sold.SoldPricePerShare = &o.Price
+        if lot.Shares > remaining {
+            // Keep the rest of the lot open and close the sold shares on a new row
+            if err := positions.SetShares(ctx, lot.PositionID, lot.Shares-remaining); err != nil {
+                return nil, err
+            }
+            notes := fmt.Sprintf("%s (split from position #%d)", o.Note, lot.PositionID)
+            lotID := lot.PositionID
+            sold.Shares = remaining
+            sold.Notes = &notes
+            sold.SplitFromPositionID = &lotID
+            if err := positions.Create(ctx, &sold); err != nil {
+                return nil, err
+            }
+        } else if err := positions.Close(ctx, lot.PositionID, o.Date, o.Price); err != nil {
+            return nil, err
+        }
+        remaining -= sold.Shares
+
+        fees := o.Fees * float64(sold.Shares) / float64(o.Shares)
+        sale := models.LotSale{
+            AccountID:         lot.AccountID,
+            Symbol:            lot.Symbol,
+            LotID:             lot.PositionID,
+            PositionID:        sold.PositionID,
+            Shares:            sold.Shares,
+            AcquiredDate:      dateOnly(lot.AcquiredDate),
+            SoldDate:          dateOnly(o.Date),
+            CostBasisPerShare: lot.CostBasisPerShare,
+            SalePricePerShare: o.Price,
+            Fees:              fees,
+            RealizedPnL:       pnl.Stock(sold.Shares, lot.CostBasisPerShare, o.Price) - fees,
+            Term:              holdingTerm(lot.AcquiredDate, o.Date),
+            LotMethod:         method,
+            TradeID:           o.TradeID,
+        }
+        if err := s.lotSales.WithTx(tx).Create(ctx, &sale); err != nil {
+            return nil, err
+        }
+        if err := s.postPositions(ctx, tx, sold.PositionID); err != nil {
+            return nil, err
+        }
+
+        result.RealizedPnL += sale.RealizedPnL
+        result.Positions = append(result.Positions, sold)
+        result.Sales = append(result.Sales, sale)
+    }
+    return result, nil
+}
+
+// orderLots puts open lots in the order a sale consumes them. SPECIFIC keeps
+// only the lots in ids, in that order.
+func orderLots(lots []models.Position, method string, ids []int) ([]models.Position, error) {
+    if method == "SPECIFIC" {
+        byID := make(map[int]models.Position, len(lots))
+        for _, lot := range lots {
+            byID[lot.PositionID] = lot
+        }
+        picked := make([]models.Position, 0, len(ids))
+        for _, id := range ids {
+            lot, ok := byID[id]
+            if !ok {
+                return nil, invalidf("position %d is not an open lot of this sale", id)
+            }
+            picked = append(picked, lot)
+            delete(byID, id)
+        }
+        return picked, nil
+    }
+
+    fifo := func(a, b models.Position) bool {
+        if a.AcquiredDate != b.AcquiredDate {
+            return a.AcquiredDate < b.AcquiredDate
+        }
+        return a.PositionID < b.PositionID
+    }
+    sort.Slice(lots, func(i, j int) bool {
+        switch method {
+        case "LIFO":
+            return fifo(lots[j], lots[i])
+        case "HIFO":
+            if lots[i].CostBasisPerShare != lots[j].CostBasisPerShare {
+                return lots[i].CostBasisPerShare > lots[j].CostBasisPerShare
+            }
+        }
+        return fifo(lots[i], lots[j])
+    })
+    return lots, nil
+}
+
+// holdingTerm is LONG for shares sold more than a year after they were acquired
+func holdingTerm(acquired, sold string) string {
+    from, err1 := time.Parse(dateLayout, dateOnly(acquired))
+    to, err2 := time.Parse(dateLayout, dateOnly(sold))
+    if err1 == nil && err2 == nil && to.After(from.AddDate(1, 0, 0)) {
+        return "LONG"
+    }
+    return "SHORT"
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+    "context"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestLotPostings(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    lot := models.Position{
+        AccountID:         1,
+        Symbol:            "ABC",
+        Shares:            100,
+        CostBasisPerShare: 20,
+        AcquiredDate:      "2026-01-05",
+        Status:            "OPEN",
+    }
+    if err := s.CreatePosition(ctx, &lot); err != nil {
+        t.Fatalf("create position: %v", err)
+    }
+    assertBalanced(t, s, 10000-2000)
+
+    sales, err := s.ClosePosition(ctx, lot.PositionID, ClosePositionRequest{
+        SoldDate:          "2026-02-10",
+        SoldPricePerShare: 25,
+        Shares:            40,
+    })
+    if err != nil {
+        t.Fatalf("close position: %v", err)
+    }
+    if len(sales) != 1 || sales[0].Shares != 40 {
+        t.Fatalf("sales = %+v, want one sale of 40 shares", sales)
+    }
+    // The lot keeps its purchase; the 40 shares sold bring in 1000
+    assertBalanced(t, s, 10000-2000+1000)
+
+    if err := s.DeletePosition(ctx, lot.PositionID); err == nil {
+        t.Error("deleting a lot with shares sold on another row succeeded")
+    }
+
+    // Removing the sale takes its proceeds and the cost of its shares back
+    if err := s.DeletePosition(ctx, sales[0].PositionID); err != nil {
+        t.Fatalf("delete sold row: %v", err)
+    }
+    assertBalanced(t, s, 10000-1200)
+
+    if err := s.DeletePosition(ctx, lot.PositionID); err != nil {
+        t.Fatalf("delete lot: %v", err)
+    }
+    assertBalanced(t, s, 10000)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "time"
+)
+
+// QuoteSource returns the latest price of an underlying and when it traded
+type QuoteSource interface {
+    Quote(ctx context.Context, symbol string) (float64, time.Time, error)
+}
+
+// finnhubQuotes fetches prices from Finnhub with the key stored in api_configs
+type finnhubQuotes struct {
+    db     *sql.DB
+    client *http.Client
+}
+
+func newFinnhubQuotes(db *sql.DB) *finnhubQuotes {
+    return &finnhubQuotes{db: db, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (q *finnhubQuotes) Quote(ctx context.Context, symbol string) (float64, time.Time, error) {
+    var apiKey string
+    err := q.db.QueryRowContext(ctx, "SELECT api_key FROM api_configs WHERE provider = 'FINNHUB' AND is_active = 1").Scan(&apiKey)
+    if err != nil {
+        return 0, time.Time{}, fmt.Errorf("FINNHUB API key not configured: %w", err)
+    }
+
+    endpoint := fmt.Sprintf("https://finnhub.io/api/v1/quote?symbol=%s&token=%s", url.QueryEscape(symbol), url.QueryEscape(apiKey))
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+    if err != nil {
+        return 0, time.Time{}, err
+    }
+    resp, err := q.client.Do(req)
+    if err != nil {
+        // The request URL carries the API key; keep it out of logs
+        var urlErr *url.Error
+        if errors.As(err, &urlErr) {
+            err = urlErr.Err
+        }
+        return 0, time.Time{}, fmt.Errorf("finnhub quote for %s: %w", symbol, err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return 0, time.Time{}, fmt.Errorf("finnhub quote for %s: %s", symbol, resp.Status)
+    }
+
+    var quote struct {
+        Current float64 `json:"c"`
+        Time    int64   `json:"t"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+        return 0, time.Time{}, err
+    }
+    // Finnhub answers unknown symbols with an all-zero quote
+    if quote.Current <= 0 {
+        return 0, time.Time{}, fmt.Errorf("no quote for %s", symbol)
+    }
+    return quote.Current, time.Unix(quote.Time, 0), nil
+}
@@ -1,20 +1,46 @@
 package services
 
 import (
+"context"
 "fmt"
 "log"
 
 "github.com/wheel-tracker/backend/internal/database"
 "github.com/wheel-tracker/backend/internal/models"
+"github.com/wheel-tracker/backend/internal/repository"
 )
 
 type TradeService struct {
-db *database.DB
+db          *database.DB
+trades      *repository.TradeRepo
+accounts    *repository.AccountRepo
+positions   *repository.PositionRepo
+wheels      *repository.WheelRepo
+summaries   *repository.WheelSummaryRepo
+strategies  *repository.StrategyGroupRepo
+executions  *repository.ExecutionRepo
+lotSales    *repository.LotSaleRepo
+income      *repository.IncomeRepo
+expirations *repository.ExpirationLogRepo
+actions     *repository.CorporateActionRepo
+quotes      QuoteSource
 }
 
 func NewTradeService(db *database.DB) *TradeService {
 return &TradeService{
-db: db,
+db:          db,
+trades:      repository.NewTradeRepo(db.DB),
+accounts:    repository.NewAccountRepo(db.DB),
+positions:   repository.NewPositionRepo(db.DB),
+wheels:      repository.NewWheelRepo(db.DB),
+summaries:   repository.NewWheelSummaryRepo(db.DB),
+strategies:  repository.NewStrategyGroupRepo(db.DB),
+executions:  repository.NewExecutionRepo(db.DB),
+lotSales:    repository.NewLotSaleRepo(db.DB),
+income:      repository.NewIncomeRepo(db.DB),
+expirations: repository.NewExpirationLogRepo(db.DB),
+actions:     repository.NewCorporateActionRepo(db.DB),
+quotes:      newFinnhubQuotes(db.DB),
 }
 }
 
@@ -32,75 +58,81 @@ return nil
 // ============== NUEVOS MÉTODOS PARA IMPORTACIÓN ==============
 
 // SaveTrade guarda un trade individual en la BD
-func (s *TradeService) SaveTrade(trade models.Trade) error {
-result, err := s.db.Exec(`
-INSERT INTO trades (
-account_id, symbol, trade_type, contracts, strike_price,
-premium_per_share, open_date, expiration_date, close_date,
-close_method, close_price, fees, status, tags, notes, wheel_id
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-`,
-trade.AccountID, trade.Symbol, trade.TradeType, trade.Contracts,
-trade.StrikePrice, trade.PremiumPerShare, trade.OpenDate,
-trade.ExpirationDate, trade.CloseDate, trade.CloseMethod,
-trade.ClosePrice, trade.Fees, trade.Status, trade.Tags,
-trade.Notes, trade.WheelID,
-)
-
-if err != nil {
+func (s *TradeService) SaveTrade(ctx context.Context, trade *models.Trade) error {
+if err := s.trades.Create(ctx, trade); err != nil {
 log.Printf("Error saving trade: %v", err)
 return err
 }
-
-id, _ := result.LastInsertId()
-trade.TradeID = int(id)
 return nil
 }
 
 // SaveTradesTransaction guarda múltiples trades en una transacción
-// Si falla uno, revierte todos
-func (s *TradeService) SaveTradesTransaction(trades []models.Trade) (int, []string, error) {
+// Si falla uno, revierte todos; un trade abierto que supera el buying power
+// falla salvo con allowShortfall
+func (s *TradeService) SaveTradesTransaction(ctx context.Context, trades []models.Trade, allowShortfall bool) (int, []string, error) {
 // Iniciar transacción
-tx, err := s.db.Begin()
+tx, err := s.db.BeginTx(ctx, nil)
 if err != nil {
 return 0, nil, fmt.Errorf("failed to begin transaction: %v", err)
 }
+tradeRepo := s.trades.WithTx(tx)
+accountRepo := s.accounts.WithTx(tx)
 
 var importedCount int
 var errors []string
 
-for i, trade := range trades {
+for i := range trades {
+trade := &trades[i]
+
 // Validar que account_id existe
-var accountExists bool
-err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM accounts WHERE account_id = ? AND is_active = 1)", trade.AccountID).Scan(&accountExists)
+accountExists, err := accountRepo.IsActive(ctx, trade.AccountID)
 if err != nil || !accountExists {
 errors = append(errors, fmt.Sprintf("Trade %d: Account ID %d not found or inactive", i+1, trade.AccountID))
 continue
 }
 
+// Comprobar el buying power con lo ya importado antes que él
+shortfall, err := s.checkBuyingPower(ctx, tx, *trade)
+if err != nil {
+errors = append(errors, fmt.Sprintf("Trade %d (%s): %v", i+1, trade.Symbol, err))
+continue
+}
+if shortfall != "" {
+if !allowShortfall {
+errors = append(errors, fmt.Sprintf("Trade %d (%s): %s", i+1, trade.Symbol, shortfall))
+continue
+}
+log.Printf("Trade %d (%s) imported with a warning: %s", i+1, trade.Symbol, shortfall)
+}
+
+// Enlazar con la rueda abierta del símbolo o empezar una nueva
+if err := s.linkWheel(ctx, tx, trade); err != nil {
+errors = append(errors, fmt.Sprintf("Trade %d (%s): %v", i+1, trade.Symbol, err))
+continue
+}
+
 // Insertar trade
-result, err := tx.Exec(`
-INSERT INTO trades (
-account_id, symbol, trade_type, contracts, strike_price,
-premium_per_share, open_date, expiration_date, close_date,
-close_method, close_price, fees, status, tags, notes, wheel_id
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-`,
-trade.AccountID, trade.Symbol, trade.TradeType, trade.Contracts,
-trade.StrikePrice, trade.PremiumPerShare, trade.OpenDate,
-trade.ExpirationDate, trade.CloseDate, trade.CloseMethod,
-trade.ClosePrice, trade.Fees, trade.Status, trade.Tags,
-trade.Notes, trade.WheelID,
-)
+if err := tradeRepo.Create(ctx, trade); err != nil {
+errors = append(errors, fmt.Sprintf("Trade %d (%s): %v", i+1, trade.Symbol, err))
+continue
+}
 
-if err != nil {
+// Registrar su caja en el libro de la cuenta
+if err := s.postTrades(ctx, tx, trade.TradeID); err != nil {
 errors = append(errors, fmt.Sprintf("Trade %d (%s): %v", i+1, trade.Symbol, err))
 continue
 }
 
+// El histórico importado manda: recalcular la rueda tras cada trade para que
+// una rueda completada no reciba el siguiente put
+if trade.WheelID != nil {
+if _, err := s.syncWheel(ctx, tx, *trade.WheelID, true); err != nil {
+errors = append(errors, fmt.Sprintf("Trade %d (%s): %v", i+1, trade.Symbol, err))
+continue
+}
+}
+
 importedCount++
-id, _ := result.LastInsertId()
-trades[i].TradeID = int(id)
 }
 
 // Si hubo errores, revertir transacción
@@ -147,25 +179,32 @@ return nil
 }
 
 // GetTradesSummary obtiene resumen de trades por cuenta
-func (s *TradeService) GetTradesSummary(accountID int) (map[string]interface{}, error) {
+func (s *TradeService) GetTradesSummary(ctx context.Context, accountID int) (map[string]interface{}, error) {
 summary := make(map[string]interface{})
 
 // Total trades
 var totalTrades int
-s.db.QueryRow("SELECT COUNT(*) FROM trades WHERE account_id = ?", accountID).Scan(&totalTrades)
+if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM trades WHERE account_id = ?", accountID).Scan(&totalTrades); err != nil {
+return nil, err
+}
 summary["total_trades"] = totalTrades
 
 // Open trades
 var openTrades int
-s.db.QueryRow("SELECT COUNT(*) FROM trades WHERE account_id = ? AND status = 'OPEN'", accountID).Scan(&openTrades)
+if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM trades WHERE account_id = ? AND status = 'OPEN'", accountID).Scan(&openTrades); err != nil {
+return nil, err
+}
 summary["open_trades"] = openTrades
 
 // Total premium
 var totalPremium float64
-s.db.QueryRow(`
+err := s.db.QueryRowContext(ctx, `
 SELECT COALESCE(SUM((premium_per_share * contracts * 100) - fees), 0)
 FROM trades WHERE account_id = ? AND status = 'CLOSED'
 `, accountID).Scan(&totalPremium)
+if err != nil {
+return nil, err
+}
 summary["total_premium"] = totalPremium
 
 return summary, nil
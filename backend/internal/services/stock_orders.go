@@ -0,0 +1,243 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// StockOrderRequest is the payload of POST /trades/buy and /trades/sell
+type StockOrderRequest struct {
+    AccountID int     `json:"account_id"` // 0 means the active account
+    Symbol    string  `json:"symbol"`
+    Shares    int     `json:"shares"`
+    Price     float64 `json:"price"`
+    Fees      float64 `json:"fees"`
+    Date      string  `json:"date"`
+    WheelID   *int    `json:"wheel_id"`
+    Notes     string  `json:"notes"`
+    // LotIDs sells these positions, in this order, instead of following
+    // the account's lot method; required when that method is SPECIFIC
+    LotIDs []int `json:"lot_ids"`
+}
+
+// StockOrderResult describes the lots an order opened or closed and the cash it moved
+type StockOrderResult struct {
+    Positions   []models.Position `json:"positions"`
+    CashDelta   float64           `json:"cash_delta"`
+    RealizedPnL *float64          `json:"realized_pnl,omitempty"`
+    LotSales    []models.LotSale  `json:"lot_sales,omitempty"`
+    Balance     float64           `json:"balance"`
+}
+
+// BuyStocks opens a new lot with the fees folded into its cost basis and
+// debits the account. The cost has to fit in the free buying power, after
+// what open puts and strategies reserve.
+func (s *TradeService) BuyStocks(ctx context.Context, req StockOrderRequest) (*StockOrderResult, error) {
+    if err := validateStockOrder(req); err != nil {
+        return nil, err
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    acc, err := s.orderAccount(ctx, tx, req.AccountID)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkOrderWheel(ctx, tx, req, acc.AccountID); err != nil {
+        return nil, err
+    }
+
+    cost := req.Price*float64(req.Shares) + req.Fees
+    bp, err := s.buyingPowerOf(ctx, tx, acc)
+    if err != nil {
+        return nil, err
+    }
+    if cost > bp.FreeBuyingPower {
+        return nil, invalidf("Insufficient buying power. Available: %.2f, Required: %.2f", bp.FreeBuyingPower, cost)
+    }
+
+    date := req.Date
+    if date == "" {
+        date = today()
+    }
+    p := models.Position{
+        AccountID:         acc.AccountID,
+        Symbol:            req.Symbol,
+        Shares:            req.Shares,
+        CostBasisPerShare: cost / float64(req.Shares),
+        AcquiredDate:      date,
+        WheelID:           req.WheelID,
+    }
+    if req.Notes != "" {
+        p.Notes = &req.Notes
+    }
+    if err := s.positions.WithTx(tx).Create(ctx, &p); err != nil {
+        return nil, err
+    }
+
+    if err := s.postPositions(ctx, tx, p.PositionID); err != nil {
+        return nil, err
+    }
+
+    if req.WheelID != nil {
+        if _, err := s.syncWheel(ctx, tx, *req.WheelID, false); err != nil {
+            return nil, err
+        }
+    }
+
+    return s.finishStockOrder(ctx, tx, acc.AccountID, &StockOrderResult{
+        Positions: []models.Position{p},
+        CashDelta: -cost,
+    })
+}
+
+// SellStocks closes shares from the open lots of the symbol, chosen by the
+// account's lot method or by lot_ids, restricted to the wheel when one is
+// given, and credits the proceeds net of fees. Fees are charged against the
+// realized P&L of the lots sold.
+func (s *TradeService) SellStocks(ctx context.Context, req StockOrderRequest) (*StockOrderResult, error) {
+    if err := validateStockOrder(req); err != nil {
+        return nil, err
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    acc, err := s.orderAccount(ctx, tx, req.AccountID)
+    if err != nil {
+        return nil, err
+    }
+    if err := s.checkOrderWheel(ctx, tx, req, acc.AccountID); err != nil {
+        return nil, err
+    }
+
+    date := req.Date
+    if date == "" {
+        date = today()
+    }
+    filter := repository.PositionFilter{Status: "OPEN", AccountID: acc.AccountID, Symbol: req.Symbol}
+    if req.WheelID != nil {
+        filter.WheelID = *req.WheelID
+    }
+    note := "Sold"
+    if req.Notes != "" {
+        note = req.Notes
+    }
+    if acc.LotMethod == "SPECIFIC" && len(req.LotIDs) == 0 {
+        return nil, invalidf("account %d sells by specific lot; name the lots in lot_ids", acc.AccountID)
+    }
+    sale, err := s.sellLots(ctx, tx, lotOrder{
+        Filter: filter,
+        Shares: req.Shares,
+        Price:  req.Price,
+        Fees:   req.Fees,
+        Date:   date,
+        Note:   note,
+        LotIDs: req.LotIDs,
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    // sellLots credited what each lot brought in
+    proceeds := req.Price*float64(req.Shares) - req.Fees
+
+    wheelID := req.WheelID
+    if wheelID == nil {
+        wheelID = sale.WheelID
+    }
+    if wheelID != nil {
+        if _, err := s.syncWheel(ctx, tx, *wheelID, false); err != nil {
+            return nil, err
+        }
+    }
+
+    return s.finishStockOrder(ctx, tx, acc.AccountID, &StockOrderResult{
+        Positions:   sale.Positions,
+        CashDelta:   proceeds,
+        RealizedPnL: &sale.RealizedPnL,
+        LotSales:    sale.Sales,
+    })
+}
+
+func validateStockOrder(req StockOrderRequest) error {
+    if req.Symbol == "" {
+        return invalidf("symbol is required")
+    }
+    if req.Shares <= 0 {
+        return invalidf("shares must be a positive integer")
+    }
+    if req.Price <= 0 {
+        return invalidf("price must be positive")
+    }
+    if req.Fees < 0 {
+        return invalidf("fees must be zero or positive")
+    }
+    return nil
+}
+
+// orderAccount resolves the account of an order, defaulting to the active one
+func (s *TradeService) orderAccount(ctx context.Context, tx *sql.Tx, id int) (models.Account, error) {
+    accounts := s.accounts.WithTx(tx)
+    if id == 0 {
+        activeID, err := accounts.GetActiveID(ctx)
+        if err != nil {
+            return models.Account{}, invalidf("No active account found")
+        }
+        id = activeID
+    }
+    acc, err := accounts.Get(ctx, id)
+    if err != nil {
+        return acc, err
+    }
+    if !acc.IsActive {
+        return acc, invalidf("Associated account does not exist or is not active")
+    }
+    return acc, nil
+}
+
+// checkOrderWheel makes sure the wheel of an order is an active wheel on the
+// same account and symbol
+func (s *TradeService) checkOrderWheel(ctx context.Context, tx *sql.Tx, req StockOrderRequest, accountID int) error {
+    if req.WheelID == nil {
+        return nil
+    }
+    w, err := s.wheels.WithTx(tx).Get(ctx, *req.WheelID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return invalidf("wheel %d not found", *req.WheelID)
+    }
+    if err != nil {
+        return err
+    }
+    if w.AccountID != accountID || w.Symbol != req.Symbol {
+        return invalidf("wheel %d belongs to %s on account %d", w.WheelID, w.Symbol, w.AccountID)
+    }
+    if w.Status != "ACTIVE" {
+        return invalidf("wheel %d is %s", w.WheelID, w.Status)
+    }
+    return nil
+}
+
+// finishStockOrder fills in the new balance and commits the order
+func (s *TradeService) finishStockOrder(ctx context.Context, tx *sql.Tx, accountID int, result *StockOrderResult) (*StockOrderResult, error) {
+    acc, err := s.accounts.WithTx(tx).Get(ctx, accountID)
+    if err != nil {
+        return nil, err
+    }
+    result.Balance = acc.CurrentBalance
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "sort"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// StrategyLeg is one option of a StrategyRequest
+type StrategyLeg struct {
+    Side            string   `json:"side"`
+    TradeType       string   `json:"trade_type"`
+    StrikePrice     float64  `json:"strike_price"`
+    PremiumPerShare float64  `json:"premium_per_share"`
+    Contracts       int      `json:"contracts"`
+    Delta           *float64 `json:"delta"`
+    Fees            float64  `json:"fees"`
+}
+
+// StrategyRequest is the payload of POST /strategies. All legs share the
+// expiration date and contract count; a COLLAR names the position it covers.
+type StrategyRequest struct {
+    AccountID      int           `json:"account_id"`
+    Symbol         string        `json:"symbol"`
+    Strategy       string        `json:"strategy"`
+    OpenDate       string        `json:"open_date"`
+    ExpirationDate string        `json:"expiration_date"`
+    PositionID     *int          `json:"position_id"`
+    Notes          *string       `json:"notes"`
+    Legs           []StrategyLeg `json:"legs"`
+}
+
+// Strategy is a strategy group with its legs and payoff. RealizedPnL is set
+// once every leg is closed.
+type Strategy struct {
+    models.StrategyGroup
+    Legs        []models.Trade   `json:"legs"`
+    Risk        pnl.StrategyRisk `json:"risk"`
+    RealizedPnL *float64         `json:"realized_pnl,omitempty"`
+    // Warnings lists the shortfall a strategy opened with allow_shortfall has
+    Warnings []string `json:"warnings,omitempty"`
+}
+
+// CloseStrategyRequest closes every open leg of a group with one order.
+// LegPrices holds the price per share of each leg by trade_id and has to
+// price every open leg. Fees are the fees of the closing order, split evenly
+// across the legs it closes.
+type CloseStrategyRequest struct {
+    CloseDate string          `json:"close_date"`
+    LegPrices map[int]float64 `json:"leg_prices"`
+    Fees      float64         `json:"fees"`
+    Notes     string          `json:"notes"`
+}
+
+// OpenStrategy validates the legs against the named strategy and stores the
+// group and its legs in one transaction. Strategy legs never join a wheel.
+// The strategy has to fit in the free buying power with what it reserves
+// once open; a shortfall is rejected unless allowShortfall is set, as in
+// OpenTrade.
+func (s *TradeService) OpenStrategy(ctx context.Context, req StrategyRequest, allowShortfall bool) (*Strategy, error) {
+    if req.AccountID <= 0 {
+        return nil, invalidf("account_id is required")
+    }
+    if req.Symbol == "" {
+        return nil, invalidf("symbol is required")
+    }
+    if req.ExpirationDate == "" {
+        return nil, invalidf("expiration_date is required")
+    }
+    if req.OpenDate == "" {
+        req.OpenDate = today()
+    }
+    if req.ExpirationDate < req.OpenDate {
+        return nil, invalidf("expiration_date must be equal or after open_date")
+    }
+    if err := validateStrategyLegs(req.Strategy, req.Legs); err != nil {
+        return nil, err
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    if req.Strategy == "COLLAR" {
+        if err := s.checkCollarPosition(ctx, tx, req); err != nil {
+            return nil, err
+        }
+    } else {
+        req.PositionID = nil
+    }
+    // Measured before the legs post their premium and join the reserve
+    free, err := s.freeBuyingPower(ctx, tx, req.AccountID)
+    if err != nil {
+        return nil, err
+    }
+
+    group := models.StrategyGroup{
+        AccountID:  req.AccountID,
+        Symbol:     req.Symbol,
+        Strategy:   req.Strategy,
+        PositionID: req.PositionID,
+        OpenDate:   req.OpenDate,
+        Notes:      req.Notes,
+    }
+    if err := s.strategies.WithTx(tx).Create(ctx, &group); err != nil {
+        return nil, err
+    }
+    trades := s.trades.WithTx(tx)
+    for _, leg := range req.Legs {
+        t := models.Trade{
+            AccountID:       req.AccountID,
+            Symbol:          req.Symbol,
+            TradeType:       leg.TradeType,
+            Contracts:       leg.Contracts,
+            StrikePrice:     leg.StrikePrice,
+            PremiumPerShare: leg.PremiumPerShare,
+            Delta:           leg.Delta,
+            OpenDate:        req.OpenDate,
+            ExpirationDate:  req.ExpirationDate,
+            Fees:            leg.Fees,
+            Side:            leg.Side,
+            StrategyGroupID: &group.GroupID,
+        }
+        if err := trades.Create(ctx, &t); err != nil {
+            return nil, err
+        }
+        if err := s.postTrades(ctx, tx, t.TradeID); err != nil {
+            return nil, err
+        }
+    }
+
+    strategy, err := s.loadStrategy(ctx, tx, group.GroupID)
+    if err != nil {
+        return nil, err
+    }
+    if short := shortfall(free, strategyRequirement(strategy)); short != "" {
+        if !allowShortfall {
+            return nil, invalidf("%s", short)
+        }
+        strategy.Warnings = []string{short}
+    }
+    return strategy, tx.Commit()
+}
+
+// validateStrategyLegs checks that legs have the shape of strategy
+func validateStrategyLegs(strategy string, legs []StrategyLeg) error {
+    want := map[string]int{"VERTICAL": 2, "STRANGLE": 2, "IRON_CONDOR": 4, "COLLAR": 2}
+    n, ok := want[strategy]
+    if !ok {
+        return invalidf("strategy must be VERTICAL, STRANGLE, IRON_CONDOR or COLLAR")
+    }
+    if len(legs) != n {
+        return invalidf("a %s has %d legs, got %d", strategy, n, len(legs))
+    }
+
+    var puts, calls []StrategyLeg
+    for i, leg := range legs {
+        if leg.Side != "BTO" && leg.Side != "STO" {
+            return invalidf("leg %d: side must be BTO or STO", i+1)
+        }
+        if leg.TradeType != "PUT" && leg.TradeType != "CALL" {
+            return invalidf("leg %d: trade_type must be PUT or CALL", i+1)
+        }
+        if leg.StrikePrice <= 0 {
+            return invalidf("leg %d: strike_price must be positive", i+1)
+        }
+        if leg.PremiumPerShare < 0 {
+            return invalidf("leg %d: premium_per_share must be zero or positive", i+1)
+        }
+        if leg.Contracts <= 0 || leg.Contracts != legs[0].Contracts {
+            return invalidf("leg %d: every leg needs the same positive number of contracts", i+1)
+        }
+        if leg.TradeType == "PUT" {
+            puts = append(puts, leg)
+        } else {
+            calls = append(calls, leg)
+        }
+    }
+    byStrike := func(l []StrategyLeg) {
+        sort.Slice(l, func(i, j int) bool { return l[i].StrikePrice < l[j].StrikePrice })
+    }
+    byStrike(puts)
+    byStrike(calls)
+
+    switch strategy {
+    case "VERTICAL":
+        pair := puts
+        if len(calls) == 2 {
+            pair = calls
+        }
+        if len(pair) != 2 || pair[0].Side == pair[1].Side || pair[0].StrikePrice == pair[1].StrikePrice {
+            return invalidf("a VERTICAL buys one and sells one option of the same type at different strikes")
+        }
+    case "STRANGLE":
+        if len(puts) != 1 || len(calls) != 1 || puts[0].Side != calls[0].Side {
+            return invalidf("a STRANGLE buys or sells one put and one call together")
+        }
+        if puts[0].StrikePrice > calls[0].StrikePrice {
+            return invalidf("the put strike of a STRANGLE cannot be above the call strike")
+        }
+    case "IRON_CONDOR":
+        if len(puts) != 2 || len(calls) != 2 ||
+            puts[0].Side != "BTO" || puts[1].Side != "STO" || calls[0].Side != "STO" || calls[1].Side != "BTO" ||
+            puts[0].StrikePrice == puts[1].StrikePrice || calls[0].StrikePrice == calls[1].StrikePrice {
+            return invalidf("an IRON_CONDOR sells a put and a call and buys a put below and a call above them")
+        }
+        if puts[1].StrikePrice > calls[0].StrikePrice {
+            return invalidf("the short put of an IRON_CONDOR cannot be above its short call")
+        }
+    case "COLLAR":
+        if len(puts) != 1 || len(calls) != 1 || puts[0].Side != "BTO" || calls[0].Side != "STO" {
+            return invalidf("a COLLAR buys a put and sells a call against shares held")
+        }
+        if puts[0].StrikePrice >= calls[0].StrikePrice {
+            return invalidf("the put strike of a COLLAR must be below the call strike")
+        }
+    }
+    return nil
+}
+
+// checkCollarPosition makes sure the collar covers an open lot of the same
+// account and symbol large enough for its contracts
+func (s *TradeService) checkCollarPosition(ctx context.Context, tx *sql.Tx, req StrategyRequest) error {
+    if req.PositionID == nil {
+        return invalidf("a COLLAR needs the position_id of the shares it covers")
+    }
+    p, err := s.positions.WithTx(tx).Get(ctx, *req.PositionID)
+    if err != nil {
+        return err
+    }
+    if p.Status != "OPEN" || p.AccountID != req.AccountID || p.Symbol != req.Symbol {
+        return invalidf("position %d is not an open %s lot of account %d", p.PositionID, req.Symbol, req.AccountID)
+    }
+    if needed := req.Legs[0].Contracts * pnl.SharesPerContract; p.Shares < needed {
+        return invalidf("position %d holds %d shares; the collar covers %d", p.PositionID, p.Shares, needed)
+    }
+    return nil
+}
+
+// GetStrategy returns a strategy group with its legs and payoff
+func (s *TradeService) GetStrategy(ctx context.Context, id int) (*Strategy, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+    return s.loadStrategy(ctx, tx, id)
+}
+
+// ListStrategies returns the strategy groups matching f with their legs and payoff
+func (s *TradeService) ListStrategies(ctx context.Context, f repository.StrategyGroupFilter) ([]Strategy, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    groups, err := s.strategies.WithTx(tx).List(ctx, f)
+    if err != nil {
+        return nil, err
+    }
+    result := make([]Strategy, 0, len(groups))
+    for _, g := range groups {
+        strategy, err := s.loadStrategy(ctx, tx, g.GroupID)
+        if err != nil {
+            return nil, err
+        }
+        result = append(result, *strategy)
+    }
+    return result, nil
+}
+
+// loadStrategy reads a group and its legs and works out the payoff
+func (s *TradeService) loadStrategy(ctx context.Context, tx *sql.Tx, id int) (*Strategy, error) {
+    group, err := s.strategies.WithTx(tx).Get(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    legs, err := s.trades.WithTx(tx).List(ctx, repository.TradeFilter{StrategyGroupID: id})
+    if err != nil {
+        return nil, err
+    }
+
+    shares, basis := 0, 0.0
+    if group.PositionID != nil && len(legs) > 0 {
+        p, err := s.positions.WithTx(tx).Get(ctx, *group.PositionID)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            return nil, err
+        }
+        if err == nil {
+            shares, basis = pnl.Shares(legs[0]), p.CostBasisPerShare
+        }
+    }
+
+    strategy := &Strategy{StrategyGroup: group, Legs: legs, Risk: pnl.Strategy(legs, shares, basis)}
+    if group.Status == "CLOSED" {
+        var realized float64
+        for _, t := range legs {
+            realized += pnl.Realized(t)
+        }
+        strategy.RealizedPnL = &realized
+    }
+    return strategy, nil
+}
+
+// CloseStrategy closes every open leg of a group with one closing order
+func (s *TradeService) CloseStrategy(ctx context.Context, id int, req CloseStrategyRequest) (*Strategy, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    strategy, err := s.closeStrategy(ctx, tx, id, req)
+    if err != nil {
+        return nil, err
+    }
+    return strategy, tx.Commit()
+}
+
+// closeStrategy does the work of CloseStrategy inside the caller's
+// transaction. Legs are recorded with close_method BTC, which for a bought
+// leg means it was sold to close.
+func (s *TradeService) closeStrategy(ctx context.Context, tx *sql.Tx, id int, req CloseStrategyRequest) (*Strategy, error) {
+    group, err := s.strategies.WithTx(tx).Get(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if group.Status != "OPEN" {
+        return nil, repository.ErrNotFound
+    }
+    trades := s.trades.WithTx(tx)
+    legs, err := trades.List(ctx, repository.TradeFilter{StrategyGroupID: id, Status: "OPEN"})
+    if err != nil {
+        return nil, err
+    }
+    for tradeID, price := range req.LegPrices {
+        if price < 0 {
+            return nil, invalidf("leg_prices: price of trade %d must be zero or positive", tradeID)
+        }
+    }
+    // A leg left out would close at 0 and book a made-up gain or loss
+    for _, t := range legs {
+        if _, ok := req.LegPrices[t.TradeID]; !ok {
+            return nil, invalidf("strategy %d closes as a whole; leg_prices must price trade %d", id, t.TradeID)
+        }
+    }
+
+    closeDate := req.CloseDate
+    if closeDate == "" {
+        closeDate = today()
+    }
+    for _, t := range legs {
+        notes := req.Notes
+        if notes == "" && t.Notes != nil {
+            notes = *t.Notes
+        }
+        err := trades.Close(ctx, t.TradeID, repository.TradeClose{
+            CloseDate:   closeDate,
+            CloseMethod: "BTC",
+            ClosePrice:  req.LegPrices[t.TradeID],
+            Fees:        t.Fees + req.Fees/float64(len(legs)),
+            Notes:       notes,
+        })
+        if err != nil {
+            return nil, err
+        }
+        if err := s.postTrades(ctx, tx, t.TradeID); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := s.settleStrategy(ctx, tx, id); err != nil {
+        return nil, err
+    }
+    return s.loadStrategy(ctx, tx, id)
+}
+
+// settleStrategy closes the group once none of its legs is open, dated by
+// the last leg to close
+func (s *TradeService) settleStrategy(ctx context.Context, tx *sql.Tx, id int) error {
+    legs, err := s.trades.WithTx(tx).List(ctx, repository.TradeFilter{StrategyGroupID: id})
+    if err != nil {
+        return err
+    }
+    lastClose := ""
+    for _, t := range legs {
+        if t.Status == "OPEN" {
+            return nil
+        }
+        if t.CloseDate != nil && dateOnly(*t.CloseDate) > lastClose {
+            lastClose = dateOnly(*t.CloseDate)
+        }
+    }
+    if lastClose == "" {
+        lastClose = today()
+    }
+    return s.strategies.WithTx(tx).Close(ctx, id, lastClose)
+}
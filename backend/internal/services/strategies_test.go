@@ -0,0 +1,63 @@
+package services
+
+import (
+    "context"
+    "errors"
+    "testing"
+)
+
+func TestCloseStrategyLegNeedsEveryPrice(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    strategy, err := s.OpenStrategy(ctx, StrategyRequest{
+        AccountID:      1,
+        Symbol:         "ABC",
+        Strategy:       "VERTICAL",
+        OpenDate:       "2026-01-05",
+        ExpirationDate: "2026-02-20",
+        Legs: []StrategyLeg{
+            {Side: "STO", TradeType: "PUT", StrikePrice: 30, PremiumPerShare: 2, Contracts: 1},
+            {Side: "BTO", TradeType: "PUT", StrikePrice: 25, PremiumPerShare: 0.5, Contracts: 1},
+        },
+    }, false)
+    if err != nil {
+        t.Fatalf("open strategy: %v", err)
+    }
+    short, long := strategy.Legs[0], strategy.Legs[1]
+    if short.Side != "STO" {
+        short, long = long, short
+    }
+    // 200 credit - 50 debit
+    assertBalanced(t, s, 10150)
+
+    _, err = s.CloseTrade(ctx, short.TradeID, CloseTradeRequest{
+        CloseMethod: "BTC",
+        ClosePrice:  1,
+        LegPrices:   map[int]float64{short.TradeID: 1},
+    })
+    var invalid *ValidationError
+    if !errors.As(err, &invalid) {
+        t.Fatalf("closing a leg without the price of trade %d: err = %v, want a validation error", long.TradeID, err)
+    }
+
+    // POST /strategies/:id/close holds every leg to the same rule
+    _, err = s.CloseStrategy(ctx, strategy.GroupID, CloseStrategyRequest{
+        LegPrices: map[int]float64{short.TradeID: 1},
+    })
+    if !errors.As(err, &invalid) {
+        t.Fatalf("closing the strategy without the price of trade %d: err = %v, want a validation error", long.TradeID, err)
+    }
+
+    result, err := s.CloseTrade(ctx, short.TradeID, CloseTradeRequest{
+        CloseMethod: "BTC",
+        LegPrices:   map[int]float64{short.TradeID: 1, long.TradeID: 0.25},
+    })
+    if err != nil {
+        t.Fatalf("close leg: %v", err)
+    }
+    if result.Strategy == nil || result.Strategy.Status != "CLOSED" {
+        t.Fatalf("strategy = %+v, want it closed", result.Strategy)
+    }
+    assertBalanced(t, s, 10150-100+25)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+)
+
+// TaxEvent is one realized disposal, shaped like a row of Form 8949. Fees
+// are added to the cost basis of an option and taken off the proceeds of a
+// stock sale. Code is W for a wash sale, whose disallowed loss is added back
+// to Gain. Notes explain how an assigned option or an earlier wash sale
+// changed the basis or the proceeds.
+type TaxEvent struct {
+    Source       string  `json:"source"` // trade o lot_sale
+    SourceID     int     `json:"source_id"`
+    AccountID    int     `json:"account_id"`
+    Symbol       string  `json:"symbol"`
+    Description  string  `json:"description"`
+    AcquiredDate string  `json:"acquired_date"`
+    DisposedDate string  `json:"disposed_date"`
+    Proceeds     float64 `json:"proceeds"`
+    CostBasis    float64 `json:"cost_basis"`
+    Gain         float64 `json:"gain"`
+    Term         string  `json:"term"`
+    Code         string  `json:"code,omitempty"`
+    // WashSaleDisallowed is the part of a loss deferred to its replacement
+    WashSaleDisallowed float64 `json:"wash_sale_disallowed,omitempty"`
+    Notes              string  `json:"notes,omitempty"`
+}
+
+// TaxTotals adds up the events of one holding term
+type TaxTotals struct {
+    Events             int     `json:"events"`
+    Proceeds           float64 `json:"proceeds"`
+    CostBasis          float64 `json:"cost_basis"`
+    WashSaleDisallowed float64 `json:"wash_sale_disallowed"`
+    Gain               float64 `json:"gain"`
+}
+
+// TaxReport is every gain realized in a calendar year, short-term events
+// first as Part I of Form 8949 lists them
+type TaxReport struct {
+    Year      int        `json:"year"`
+    AccountID int        `json:"account_id,omitempty"`
+    Events    []TaxEvent `json:"events"`
+    ShortTerm TaxTotals  `json:"short_term"`
+    LongTerm  TaxTotals  `json:"long_term"`
+    TotalGain float64    `json:"total_gain"`
+}
+
+// TaxReport lists the gains realized in year, in one account or all of them
+// when accountID is 0. Options closed or expired are events of their own. An
+// assigned option is not: a put's premium lowers the basis of the shares it
+// bought and a call's premium adds to the proceeds of the shares it sold, so
+// both surface when those shares are sold. Losses the wash sale rule
+// disallows carry code W and are added back to the gain; see replayWashSales.
+func (s *TradeService) TaxReport(ctx context.Context, year, accountID int) (*TaxReport, error) {
+    book, err := s.washBook(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    report := &TaxReport{Year: year, AccountID: accountID, Events: make([]TaxEvent, 0)}
+    for _, e := range book.events {
+        if accountID != 0 && e.AccountID != accountID {
+            continue
+        }
+        if strings.HasPrefix(e.DisposedDate, strconv.Itoa(year)+"-") {
+            report.Events = append(report.Events, e)
+        }
+    }
+
+    sort.SliceStable(report.Events, func(i, j int) bool {
+        a, b := report.Events[i], report.Events[j]
+        if a.Term != b.Term {
+            return a.Term == "SHORT"
+        }
+        if a.DisposedDate != b.DisposedDate {
+            return a.DisposedDate < b.DisposedDate
+        }
+        return a.AcquiredDate < b.AcquiredDate
+    })
+    for _, e := range report.Events {
+        totals := &report.ShortTerm
+        if e.Term == "LONG" {
+            totals = &report.LongTerm
+        }
+        totals.Events++
+        totals.Proceeds += e.Proceeds
+        totals.CostBasis += e.CostBasis
+        totals.WashSaleDisallowed += e.WashSaleDisallowed
+        totals.Gain += e.Gain
+        report.TotalGain += e.Gain
+    }
+    return report, nil
+}
+
+// optionTaxEvent is the gain of an option closed or left to expire. A written
+// option's gain is short-term however long it was open; a bought one follows
+// its holding period.
+func optionTaxEvent(t models.Trade) TaxEvent {
+    right := "PUT"
+    if isCallType(t.TradeType) {
+        right = "CALL"
+    }
+    e := TaxEvent{
+        Source:    "trade",
+        SourceID:  t.TradeID,
+        AccountID: t.AccountID,
+        Symbol:    t.Symbol,
+        Description: fmt.Sprintf("%d %s %s %.2f %s", t.Contracts, t.Symbol,
+            dateOnly(t.ExpirationDate), t.StrikePrice, right),
+        AcquiredDate: dateOnly(t.OpenDate),
+        DisposedDate: dateOnly(*t.CloseDate),
+        Term:         "SHORT",
+    }
+    opened := t.PremiumPerShare * pnl.Multiplier(t)
+    closed := pnl.ClosePrice(t) * pnl.Multiplier(t)
+    if t.Side == "BTO" {
+        e.Proceeds = closed
+        e.CostBasis = opened + t.Fees
+        e.Term = holdingTerm(e.AcquiredDate, e.DisposedDate)
+    } else {
+        e.Proceeds = opened
+        e.CostBasis = closed + t.Fees
+    }
+    e.Gain = e.Proceeds - e.CostBasis
+    return e
+}
+
+// stockTaxEvent is the gain of the shares a sale took from one lot. put is
+// the assigned put that bought the lot and call the assigned call that sold
+// it; either may be nil. Their premium net of fees is shared by shares, the
+// put's over the lot's split ratio since it was assigned.
+func stockTaxEvent(l models.LotSale, put, call *models.Trade, splitRatio float64) TaxEvent {
+    e := TaxEvent{
+        Source:       "lot_sale",
+        SourceID:     l.SaleID,
+        AccountID:    l.AccountID,
+        Symbol:       l.Symbol,
+        Description:  fmt.Sprintf("%d sh %s", l.Shares, l.Symbol),
+        AcquiredDate: dateOnly(l.AcquiredDate),
+        DisposedDate: dateOnly(l.SoldDate),
+        Proceeds:     l.SalePricePerShare*float64(l.Shares) - l.Fees,
+        CostBasis:    l.CostBasisPerShare * float64(l.Shares),
+        Term:         l.Term,
+    }
+    if put != nil {
+        shares := float64(pnl.Shares(*put))
+        perShare := (pnl.Capital(*put) - pnl.Premium(*put) + put.Fees) / shares
+        if splitRatio > 0 {
+            perShare /= splitRatio
+        }
+        e.CostBasis = perShare * float64(l.Shares)
+        e.Notes = fmt.Sprintf("Basis reduced by the premium of put #%d", put.TradeID)
+    }
+    if call != nil {
+        perShare := (pnl.Premium(*call) - call.Fees) / float64(pnl.Shares(*call))
+        e.Proceeds += perShare * float64(l.Shares)
+        e.Notes = joinNotes(e.Notes, fmt.Sprintf("Proceeds include the premium of call #%d", call.TradeID))
+    }
+    e.Gain = e.Proceeds - e.CostBasis
+    return e
+}
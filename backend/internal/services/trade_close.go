@@ -0,0 +1,318 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "fmt"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// CloseTradeRequest is the payload of POST /trades/:id/close. Fees are the
+// commissions of the closing order and are added to those paid at open.
+type CloseTradeRequest struct {
+    CloseDate   string  `json:"close_date"`
+    CloseMethod string  `json:"close_method"`
+    ClosePrice  float64 `json:"close_price"`
+    Fees        float64 `json:"fees"`
+    Notes       string  `json:"notes"`
+    // Contracts closes only part of the trade; 0 closes all of it
+    Contracts int `json:"contracts"`
+    // ReduceBasisByPremium lowers the cost basis of assigned shares by the
+    // net premium the put collected
+    ReduceBasisByPremium bool `json:"reduce_basis_by_premium"`
+    // LegPrices prices every open leg, this one included, when BTC or STC
+    // closes a strategy leg, which closes the whole group; see
+    // CloseStrategyRequest
+    LegPrices map[int]float64 `json:"leg_prices"`
+}
+
+// CloseTradeResult describes everything a close touched
+type CloseTradeResult struct {
+    Trade      models.Trade     `json:"trade"`
+    Position   *models.Position `json:"position,omitempty"`
+    CalledAway *CallAway        `json:"called_away,omitempty"`
+    Strategy   *Strategy        `json:"strategy,omitempty"`
+    // Remaining is the still open part of a partially closed trade
+    Remaining *models.Trade `json:"remaining,omitempty"`
+}
+
+// CallAway summarises the shares delivered when a covered call is assigned
+type CallAway struct {
+    Shares      int               `json:"shares"`
+    Proceeds    float64           `json:"proceeds"`
+    RealizedPnL float64           `json:"realized_pnl"`
+    Positions   []models.Position `json:"positions"`
+    WheelID     *int              `json:"wheel_id,omitempty"`
+}
+
+// CloseTrade closes an OPEN trade and, for assignments, books the resulting
+// stock movement in the same transaction. repository.ErrNotFound means the
+// trade does not exist or is already closed.
+func (s *TradeService) CloseTrade(ctx context.Context, id int, req CloseTradeRequest) (*CloseTradeResult, error) {
+    switch req.CloseMethod {
+    case "BTC", "STC", "EXPIRATION", "ASSIGNMENT":
+    default:
+        return nil, invalidf("close_method must be BTC, STC, EXPIRATION or ASSIGNMENT")
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    result, err := s.closeTrade(ctx, tx, id, req)
+    if err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// closeTrade does the work of CloseTrade inside the caller's transaction
+func (s *TradeService) closeTrade(ctx context.Context, tx *sql.Tx, id int, req CloseTradeRequest) (*CloseTradeResult, error) {
+    trades := s.trades.WithTx(tx)
+
+    t, err := trades.Get(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if t.Status != "OPEN" {
+        return nil, repository.ErrNotFound
+    }
+    if req.Contracts < 0 || req.Contracts > t.Contracts {
+        return nil, invalidf("contracts must be between 1 and the %d open on trade %d", t.Contracts, id)
+    }
+    partial := req.Contracts > 0 && req.Contracts < t.Contracts
+    switch {
+    case partial && t.StrategyGroupID != nil:
+        return nil, invalidf("trade %d is a leg of strategy %d; strategy legs close in full", id, *t.StrategyGroupID)
+    case req.CloseMethod == "STC" && t.Side != "BTO":
+        return nil, invalidf("STC closes a bought leg; trade %d was sold to open, use BTC", id)
+    case req.CloseMethod == "ASSIGNMENT" && t.Side == "BTO":
+        return nil, invalidf("exercising a bought leg is not supported; close trade %d with STC", id)
+    case t.StrategyGroupID != nil && (req.CloseMethod == "BTC" || req.CloseMethod == "STC"):
+        return s.closeStrategyFromLeg(ctx, tx, t, req)
+    case req.CloseMethod == "STC":
+        // close_method records a closing trade as BTC whichever side it was
+        req.CloseMethod = "BTC"
+    }
+    // Link before closing so an assignment books its shares on the wheel
+    if err := s.linkStoredTrade(ctx, tx, &t); err != nil {
+        return nil, err
+    }
+
+    var remaining *models.Trade
+    if partial {
+        fills, err := s.executions.WithTx(tx).List(ctx, id)
+        if err != nil {
+            return nil, err
+        }
+        if len(fills) > 0 {
+            return nil, invalidf("trade %d is tracked by fills; record CLOSE executions instead", id)
+        }
+        rest := t
+        if t, err = s.splitTrade(ctx, tx, t, req.Contracts); err != nil {
+            return nil, err
+        }
+        id = t.TradeID
+        remaining = &rest
+    }
+
+    closeDate := req.CloseDate
+    if closeDate == "" {
+        // Assignment and expiration happen at expiry unless told otherwise
+        closeDate = dateOnly(t.ExpirationDate)
+        if req.CloseMethod == "BTC" {
+            closeDate = today()
+        }
+    }
+
+    err = trades.Close(ctx, id, repository.TradeClose{
+        CloseDate:   closeDate,
+        CloseMethod: req.CloseMethod,
+        ClosePrice:  req.ClosePrice,
+        Fees:        t.Fees + req.Fees,
+        Notes:       req.Notes,
+    })
+    if err != nil {
+        return nil, err
+    }
+    posted := []int{id}
+    if remaining != nil {
+        posted = append(posted, remaining.TradeID)
+    }
+    if err := s.postTrades(ctx, tx, posted...); err != nil {
+        return nil, err
+    }
+    if t, err = trades.Get(ctx, id); err != nil {
+        return nil, err
+    }
+
+    result := &CloseTradeResult{Trade: t}
+    if remaining != nil {
+        rest, err := trades.Get(ctx, remaining.TradeID)
+        if err != nil {
+            return nil, err
+        }
+        result.Remaining = &rest
+    }
+    if req.CloseMethod == "ASSIGNMENT" {
+        switch t.TradeType {
+        case "CSP", "PUT":
+            if result.Position, err = s.assignPut(ctx, tx, t, closeDate, req.ReduceBasisByPremium); err != nil {
+                return nil, err
+            }
+        case "CC", "CALL":
+            if result.CalledAway, err = s.callAway(ctx, tx, t, closeDate); err != nil {
+                return nil, err
+            }
+        }
+    }
+
+    if t.StrategyGroupID != nil {
+        if err := s.settleStrategy(ctx, tx, *t.StrategyGroupID); err != nil {
+            return nil, err
+        }
+    }
+
+    wheelID := t.WheelID
+    if result.CalledAway != nil && wheelID == nil {
+        wheelID = result.CalledAway.WheelID
+    }
+    if wheelID != nil {
+        if _, err := s.syncWheel(ctx, tx, *wheelID, false); err != nil {
+            return nil, err
+        }
+    }
+    return result, nil
+}
+
+// splitTrade carves contracts off an open trade into a new OPEN trade that
+// points back through split_from_trade_id, and returns it. Opening fees are
+// shared in proportion to contracts; the original keeps the rest.
+func (s *TradeService) splitTrade(ctx context.Context, tx *sql.Tx, t models.Trade, contracts int) (models.Trade, error) {
+    trades := s.trades.WithTx(tx)
+    fees := t.Fees * float64(contracts) / float64(t.Contracts)
+
+    originID := t.TradeID
+    part := t
+    part.TradeID = 0
+    part.Contracts = contracts
+    part.Fees = fees
+    part.Status = "OPEN"
+    // Only the original answers for the roll that opened it, so the chain
+    // is not counted twice; the part stays in the roll group
+    part.ParentTradeID = nil
+    part.SplitFromTradeID = &originID
+    if err := trades.Create(ctx, &part); err != nil {
+        return part, err
+    }
+
+    t.Contracts -= contracts
+    t.Fees -= fees
+    if err := trades.Update(ctx, t); err != nil {
+        return part, err
+    }
+    return trades.Get(ctx, part.TradeID)
+}
+
+// closeStrategyFromLeg closes the whole group of a strategy leg closed by
+// BTC or STC at the leg_prices given for each of its open legs
+func (s *TradeService) closeStrategyFromLeg(ctx context.Context, tx *sql.Tx, t models.Trade, req CloseTradeRequest) (*CloseTradeResult, error) {
+    strategy, err := s.closeStrategy(ctx, tx, *t.StrategyGroupID, CloseStrategyRequest{
+        CloseDate: req.CloseDate,
+        LegPrices: req.LegPrices,
+        Fees:      req.Fees,
+        Notes:     req.Notes,
+    })
+    if err != nil {
+        return nil, err
+    }
+    result := &CloseTradeResult{Trade: t, Strategy: strategy}
+    for _, leg := range strategy.Legs {
+        if leg.TradeID == t.TradeID {
+            result.Trade = leg
+        }
+    }
+    return result, nil
+}
+
+// assignPut buys the shares put to us at the strike, booked as a new position
+// on the trade's wheel with the purchase debited from the account. After a
+// split changed the deliverable the strike notional buys a different number
+// of shares, so the price per share is the notional over those shares.
+func (s *TradeService) assignPut(ctx context.Context, tx *sql.Tx, t models.Trade, date string, reduceBasis bool) (*models.Position, error) {
+    shares := pnl.Shares(t)
+    cost := pnl.Capital(t)
+    price := cost / float64(shares)
+    basis := price
+    if reduceBasis {
+        netPremium := pnl.Premium(t) - t.Fees
+        basis -= netPremium / float64(shares)
+    }
+
+    notes := fmt.Sprintf("Assigned from trade #%d", t.TradeID)
+    tradeID := t.TradeID
+    p := &models.Position{
+        AccountID:         t.AccountID,
+        Symbol:            t.Symbol,
+        Shares:            shares,
+        CostBasisPerShare: basis,
+        AcquiredDate:      date,
+        WheelID:           t.WheelID,
+        SourceTradeID:     &tradeID,
+        Notes:             &notes,
+    }
+    if err := s.positions.WithTx(tx).Create(ctx, p); err != nil {
+        return nil, err
+    }
+    if err := s.postPositions(ctx, tx, p.PositionID); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+// callAway delivers the shares behind an assigned call at the strike. Shares
+// come from the trade's wheel, or from any open lot of the same symbol in the
+// account, in the order of the account's lot method.
+func (s *TradeService) callAway(ctx context.Context, tx *sql.Tx, t models.Trade, date string) (*CallAway, error) {
+    filter := repository.PositionFilter{Status: "OPEN", AccountID: t.AccountID, Symbol: t.Symbol}
+    if t.WheelID != nil {
+        filter.WheelID = *t.WheelID
+    }
+    needed := pnl.Shares(t)
+    price := pnl.Capital(t) / float64(needed)
+    note := fmt.Sprintf("Called away by trade #%d", t.TradeID)
+    tradeID := t.TradeID
+    sale, err := s.sellLots(ctx, tx, lotOrder{
+        Filter:  filter,
+        Shares:  needed,
+        Price:   price,
+        Date:    date,
+        Note:    note,
+        TradeID: &tradeID,
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    result := &CallAway{
+        Shares:      needed,
+        RealizedPnL: sale.RealizedPnL,
+        Positions:   sale.Positions,
+        WheelID:     t.WheelID,
+    }
+    if result.WheelID == nil {
+        result.WheelID = sale.WheelID
+    }
+
+    // sellLots credited the strike received for each lot
+    result.Proceeds = pnl.Capital(t)
+    return result, nil
+}
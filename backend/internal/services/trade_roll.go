@@ -0,0 +1,202 @@
+package services
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// RollTradeRequest is the payload of POST /trades/:id/roll. The current trade
+// is bought to close at ClosePrice and a replacement is sold at the new
+// strike, expiration and premium.
+type RollTradeRequest struct {
+    CloseDate  string  `json:"close_date"`
+    ClosePrice float64 `json:"close_price"`
+    CloseFees  float64 `json:"close_fees"`
+
+    StrikePrice     float64  `json:"strike_price"`
+    ExpirationDate  string   `json:"expiration_date"`
+    PremiumPerShare float64  `json:"premium_per_share"`
+    Contracts       int      `json:"contracts"` // 0 keeps the contracts of the rolled trade
+    Delta           *float64 `json:"delta"`
+    OpenFees        float64  `json:"open_fees"`
+    Notes           string   `json:"notes"`
+}
+
+// RollTradeResult holds both legs of a roll
+type RollTradeResult struct {
+    Closed             models.Trade `json:"closed"`
+    Opened             models.Trade `json:"opened"`
+    RollChainNetCredit float64      `json:"roll_chain_net_credit"`
+}
+
+// RollTrade closes an OPEN trade by BTC and opens its replacement in one
+// transaction. The new trade points back through parent_trade_id and shares
+// the roll_group of the chain, which is the trade_id of its first trade.
+// The replacement is checked against the buying power left once the old
+// trade is bought back, so only the net new collateral has to be free; a
+// shortfall is rejected unless allowShortfall is set, as in OpenTrade.
+// repository.ErrNotFound means the trade does not exist or is already closed.
+func (s *TradeService) RollTrade(ctx context.Context, id int, req RollTradeRequest, allowShortfall bool) (*RollTradeResult, error) {
+    if req.StrikePrice <= 0 {
+        return nil, invalidf("strike_price must be positive")
+    }
+    if req.PremiumPerShare < 0 {
+        return nil, invalidf("premium_per_share must be zero or positive")
+    }
+    if req.ClosePrice < 0 {
+        return nil, invalidf("close_price must be zero or positive")
+    }
+    if req.Contracts < 0 {
+        return nil, invalidf("contracts must be positive")
+    }
+    if req.ExpirationDate == "" {
+        return nil, invalidf("expiration_date is required")
+    }
+
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+    trades := s.trades.WithTx(tx)
+
+    old, err := trades.Get(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if old.Status != "OPEN" {
+        return nil, repository.ErrNotFound
+    }
+    if old.StrategyGroupID != nil {
+        return nil, invalidf("trade %d is a leg of strategy %d; close the strategy instead of rolling one leg", id, *old.StrategyGroupID)
+    }
+
+    closeDate := req.CloseDate
+    if closeDate == "" {
+        closeDate = today()
+    }
+    if req.ExpirationDate < closeDate {
+        return nil, invalidf("expiration_date must be equal or after close_date")
+    }
+
+    closeNotes := fmt.Sprintf("Rolled to %s %.2f", req.ExpirationDate, req.StrikePrice)
+    if old.Notes != nil && *old.Notes != "" {
+        closeNotes = *old.Notes + "\n" + closeNotes
+    }
+    err = trades.Close(ctx, id, repository.TradeClose{
+        CloseDate:   closeDate,
+        CloseMethod: "BTC",
+        ClosePrice:  req.ClosePrice,
+        Fees:        old.Fees + req.CloseFees,
+        Notes:       closeNotes,
+    })
+    if err != nil {
+        return nil, err
+    }
+    if err := s.postTrades(ctx, tx, old.TradeID); err != nil {
+        return nil, err
+    }
+
+    group := old.TradeID
+    if old.RollGroup != nil {
+        group = *old.RollGroup
+    } else if err := trades.SetRollGroup(ctx, old.TradeID, group); err != nil {
+        return nil, err
+    }
+
+    contracts := req.Contracts
+    if contracts == 0 {
+        contracts = old.Contracts
+    }
+    parentID := old.TradeID
+    next := models.Trade{
+        AccountID:       old.AccountID,
+        Symbol:          old.Symbol,
+        TradeType:       old.TradeType,
+        Contracts:       contracts,
+        StrikePrice:     req.StrikePrice,
+        PremiumPerShare: req.PremiumPerShare,
+        Delta:           req.Delta,
+        OpenDate:        closeDate,
+        ExpirationDate:  req.ExpirationDate,
+        Fees:            req.OpenFees,
+        Status:          "OPEN",
+        Tags:            old.Tags,
+        WheelID:         old.WheelID,
+        ParentTradeID:   &parentID,
+        RollGroup:       &group,
+    }
+    if req.Notes != "" {
+        next.Notes = &req.Notes
+    }
+    short, err := s.checkBuyingPower(ctx, tx, next)
+    if err != nil {
+        return nil, err
+    }
+    if short != "" && !allowShortfall {
+        return nil, invalidf("%s", short)
+    }
+    if err := trades.Create(ctx, &next); err != nil {
+        return nil, err
+    }
+    if err := s.postTrades(ctx, tx, next.TradeID); err != nil {
+        return nil, err
+    }
+
+    if old.WheelID != nil {
+        if _, err := s.syncWheel(ctx, tx, *old.WheelID, false); err != nil {
+            return nil, err
+        }
+    }
+
+    result := &RollTradeResult{}
+    if result.Closed, err = trades.Get(ctx, old.TradeID); err != nil {
+        return nil, err
+    }
+    if result.Opened, err = trades.Get(ctx, next.TradeID); err != nil {
+        return nil, err
+    }
+    chain, err := trades.ListRollGroup(ctx, group)
+    if err != nil {
+        return nil, err
+    }
+    result.RollChainNetCredit = chainNetCredit(chain)
+    result.Opened.RollChainNetCredit = &result.RollChainNetCredit
+    if short != "" {
+        result.Opened.Warnings = []string{short}
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
+
+// GetTrade loads a trade and, when it belongs to a roll chain, the net credit
+// collected across the whole chain
+func (s *TradeService) GetTrade(ctx context.Context, id int) (models.Trade, error) {
+    t, err := s.trades.Get(ctx, id)
+    if err != nil || t.RollGroup == nil {
+        return t, err
+    }
+    chain, err := s.trades.ListRollGroup(ctx, *t.RollGroup)
+    if err != nil {
+        return t, err
+    }
+    credit := chainNetCredit(chain)
+    t.RollChainNetCredit = &credit
+    return t, nil
+}
+
+// chainNetCredit sums the net credit of every leg of a roll chain
+func chainNetCredit(chain []models.Trade) float64 {
+    var credit float64
+    for _, t := range chain {
+        credit += pnl.NetCredit(t)
+    }
+    return credit
+}
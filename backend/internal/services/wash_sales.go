@@ -0,0 +1,398 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// washWindowDays is how far either side of a loss a purchase replaces what was sold
+const washWindowDays = 30
+
+// washBook is every realized event of every account replayed in date order
+// with the wash sale rule applied. A loss's disallowed part is added to the
+// basis of its replacement before the replacement is itself sold, so losses
+// chain through later lots. The holding period of a replacement is not
+// extended by that of the shares it replaced.
+type washBook struct {
+    events  []TaxEvent
+    washes  []models.WashSale
+    lots    map[int][]lotAdjustment // by lot, the position the shares were bought as
+    charged map[int]float64         // by position sold, the deferred loss in its basis
+}
+
+// lotAdjustment is a disallowed loss spread over the replacement shares of a
+// lot; shares counts those not yet sold
+type lotAdjustment struct {
+    date     string
+    perShare float64
+    shares   int
+}
+
+// washCandidate is a purchase that can replace what a loss sold. A lot bought
+// through an assigned put counts as the put, from the day it was sold.
+type washCandidate struct {
+    source    string // position o trade
+    id        int
+    symbol    string
+    accountID int
+    shares    bool   // a lot of shares
+    right     string // PUT or CALL of the option it is or came from
+    sold      bool   // that option was sold to open
+    dates     []string
+    until     string // when a trade was closed, "" while open
+    acquired  string // for lots, to tell shares bought with the loss apart
+    basis     float64
+    washed    int
+}
+
+// adjustment is the deferred loss carried by shares sold from lot on date.
+// The replacement shares are taken to go first, each adjustment in the order
+// it was made, and what they carried is used up with them.
+func (b *washBook) adjustment(lot, shares int, date string) float64 {
+    var total float64
+    adjustments := b.lots[lot]
+    for i := range adjustments {
+        a := &adjustments[i]
+        if shares == 0 {
+            break
+        }
+        if a.date > date || a.shares == 0 {
+            continue
+        }
+        n := min(shares, a.shares)
+        total += a.perShare * float64(n)
+        a.shares -= n
+        shares -= n
+    }
+    return total
+}
+
+// WashSales lists the losses disallowed by the wash sale rule, optionally
+// narrowed to a symbol and the year of the loss
+func (s *TradeService) WashSales(ctx context.Context, symbol string, year int) ([]models.WashSale, error) {
+    book, err := s.washBook(ctx)
+    if err != nil {
+        return nil, err
+    }
+    washes := make([]models.WashSale, 0)
+    for _, w := range book.washes {
+        if symbol != "" && w.Symbol != symbol {
+            continue
+        }
+        if year != 0 && !strings.HasPrefix(w.LossDate, fmt.Sprintf("%d-", year)) {
+            continue
+        }
+        washes = append(washes, w)
+    }
+    return washes, nil
+}
+
+// AnnotateWashSales sets the deferred losses added to the basis of p and the
+// wash sales it took part in, as the lot sold or as the replacement
+func (s *TradeService) AnnotateWashSales(ctx context.Context, p *models.Position) error {
+    book, err := s.washBook(ctx)
+    if err != nil {
+        return err
+    }
+    lot := p.PositionID
+    if p.SplitFromPositionID != nil {
+        lot = *p.SplitFromPositionID
+    }
+
+    // A sold row took its share when the book replayed its sale; an open one
+    // carries what the sales left
+    adj := book.charged[p.PositionID]
+    if p.SoldDate == nil {
+        adj = book.adjustment(lot, p.Shares, "9999-12-31")
+    }
+    if adj != 0 {
+        p.WashSaleAdjustment = &adj
+    }
+    for _, w := range book.washes {
+        replaced := w.ReplacementSource == "position" && w.ReplacementID == lot
+        lost := w.LossLotID != nil && *w.LossLotID == lot
+        if replaced || lost {
+            p.WashSales = append(p.WashSales, w)
+        }
+    }
+    return nil
+}
+
+// washBook loads every trade, position and lot sale and replays them
+func (s *TradeService) washBook(ctx context.Context) (*washBook, error) {
+    trades, err := s.trades.List(ctx, repository.TradeFilter{})
+    if err != nil {
+        return nil, err
+    }
+    positions, err := s.positions.List(ctx, repository.PositionFilter{})
+    if err != nil {
+        return nil, err
+    }
+    sales, err := s.lotSales.List(ctx, repository.LotSaleFilter{})
+    if err != nil {
+        return nil, err
+    }
+    return replayWashSales(trades, positions, sales), nil
+}
+
+// replayWashSales builds the tax events of every closed option and lot sale
+// in the order they happened and matches each loss to the purchases of the
+// same symbol, in any account, within washWindowDays of it. A stock loss is
+// replaced by shares, sold puts and bought calls; an option loss by another
+// option of the same kind and side, which flags rolls made at a loss.
+// Replacements are used in the order they were bought and each share only
+// once.
+func replayWashSales(trades []models.Trade, positions []models.Position, sales []models.LotSale) *washBook {
+    book := &washBook{lots: make(map[int][]lotAdjustment), charged: make(map[int]float64)}
+
+    assigned := make(map[int]models.Trade)
+    for _, t := range trades {
+        if t.CloseMethod != nil && *t.CloseMethod == "ASSIGNMENT" {
+            assigned[t.TradeID] = t
+        }
+    }
+
+    lotShares := make(map[int]int)
+    sourceTrade := make(map[int]*int, len(positions))
+    splitRatio := make(map[int]float64, len(positions))
+    for _, p := range positions {
+        sourceTrade[p.PositionID] = p.SourceTradeID
+        splitRatio[p.PositionID] = p.SplitRatio
+        lot := p.PositionID
+        if p.SplitFromPositionID != nil {
+            lot = *p.SplitFromPositionID
+        }
+        lotShares[lot] += p.Shares
+    }
+
+    var candidates []*washCandidate
+    for _, p := range positions {
+        if p.SplitFromPositionID != nil {
+            continue
+        }
+        c := &washCandidate{
+            source:    "position",
+            id:        p.PositionID,
+            symbol:    p.Symbol,
+            accountID: p.AccountID,
+            shares:    true,
+            dates:     []string{dateOnly(p.AcquiredDate)},
+            acquired:  dateOnly(p.AcquiredDate),
+            basis:     p.CostBasisPerShare,
+        }
+        if p.SourceTradeID != nil {
+            if put, ok := assigned[*p.SourceTradeID]; ok && !isCallType(put.TradeType) {
+                c.right, c.sold = "PUT", true
+                c.dates = append([]string{dateOnly(put.OpenDate)}, c.dates...)
+            }
+        }
+        candidates = append(candidates, c)
+    }
+    for _, t := range trades {
+        if _, ok := assigned[t.TradeID]; ok {
+            continue
+        }
+        c := &washCandidate{
+            source:    "trade",
+            id:        t.TradeID,
+            symbol:    t.Symbol,
+            accountID: t.AccountID,
+            right:     optionRight(t),
+            sold:      t.Side != "BTO",
+            dates:     []string{dateOnly(t.OpenDate)},
+        }
+        if t.Status == "CLOSED" && t.CloseDate != nil {
+            c.until = dateOnly(*t.CloseDate)
+        }
+        candidates = append(candidates, c)
+    }
+    sort.SliceStable(candidates, func(i, j int) bool {
+        return candidates[i].dates[0] < candidates[j].dates[0]
+    })
+
+    // Realized events in the order they happened
+    type realized struct {
+        date  string
+        id    int
+        sale  *models.LotSale
+        trade *models.Trade
+    }
+    var queue []realized
+    for i := range sales {
+        queue = append(queue, realized{date: dateOnly(sales[i].SoldDate), id: sales[i].SaleID, sale: &sales[i]})
+    }
+    byID := make(map[int]models.Trade, len(trades))
+    for i, t := range trades {
+        byID[t.TradeID] = t
+        if t.Status != "CLOSED" || t.CloseDate == nil {
+            continue
+        }
+        if _, ok := assigned[t.TradeID]; !ok {
+            queue = append(queue, realized{date: dateOnly(*t.CloseDate), id: t.TradeID, trade: &trades[i]})
+        }
+    }
+    sort.Slice(queue, func(i, j int) bool {
+        a, b := queue[i], queue[j]
+        if a.date != b.date {
+            return a.date < b.date
+        }
+        if (a.sale == nil) != (b.sale == nil) {
+            return a.sale != nil
+        }
+        return a.id < b.id
+    })
+
+    soldShares := make(map[int]int)
+    tradeAdjustments := make(map[int]float64)
+    for _, r := range queue {
+        var e TaxEvent
+        var lossShares, lossLot int
+        var eligible func(c *washCandidate) bool
+        if l := r.sale; l != nil {
+            var put, call *models.Trade
+            if id := sourceTrade[l.PositionID]; id != nil {
+                if t, ok := assigned[*id]; ok && !isCallType(t.TradeType) {
+                    put = &t
+                }
+            }
+            if l.TradeID != nil {
+                if t, ok := assigned[*l.TradeID]; ok {
+                    call = &t
+                }
+            }
+            e = stockTaxEvent(*l, put, call, splitRatio[l.PositionID])
+            if adj := book.adjustment(l.LotID, l.Shares, r.date); adj != 0 {
+                book.charged[l.PositionID] += adj
+                e.CostBasis += adj
+                e.Notes = joinNotes(e.Notes, "Basis includes a loss deferred by a wash sale")
+            }
+            soldShares[l.LotID] += l.Shares
+            lossShares, lossLot = l.Shares, l.LotID
+            acquired, basis := dateOnly(l.AcquiredDate), l.CostBasisPerShare
+            eligible = func(c *washCandidate) bool {
+                if c.source == "position" && (c.id == l.LotID ||
+                    c.accountID == l.AccountID && c.acquired == acquired && c.basis == basis) {
+                    return false // the shares sold, or bought with them
+                }
+                return c.shares || c.right == "PUT" && c.sold || c.right == "CALL" && !c.sold
+            }
+        } else {
+            t := *r.trade
+            e = optionTaxEvent(t)
+            if adj := tradeAdjustments[t.TradeID]; adj != 0 {
+                e.CostBasis += adj
+                e.Notes = joinNotes(e.Notes, "Basis includes a loss deferred by a wash sale")
+            }
+            lossShares = pnl.Shares(t)
+            right, sold := optionRight(t), t.Side != "BTO"
+            eligible = func(c *washCandidate) bool {
+                if c.source == "trade" && c.id == t.TradeID {
+                    return false
+                }
+                return c.right == right && c.sold == sold
+            }
+        }
+        e.Gain = e.Proceeds - e.CostBasis
+
+        if e.Gain < 0 {
+            remaining := lossShares
+            for _, c := range candidates {
+                if remaining == 0 {
+                    break
+                }
+                if c.symbol != e.Symbol || !eligible(c) {
+                    continue
+                }
+                bought, ok := inWashWindow(c.dates, r.date)
+                if !ok {
+                    continue
+                }
+
+                capacity := -c.washed
+                if c.source == "position" {
+                    capacity += lotShares[c.id] - soldShares[c.id]
+                } else {
+                    if c.until != "" && c.until < r.date {
+                        continue
+                    }
+                    capacity += pnl.Shares(byID[c.id])
+                }
+                take := min(remaining, capacity)
+                if take <= 0 {
+                    continue
+                }
+
+                disallowed := -e.Gain * float64(take) / float64(lossShares)
+                if c.source == "position" {
+                    book.lots[c.id] = append(book.lots[c.id], lotAdjustment{date: r.date, perShare: disallowed / float64(take), shares: take})
+                } else {
+                    tradeAdjustments[c.id] += disallowed
+                }
+                c.washed += take
+                remaining -= take
+
+                w := models.WashSale{
+                    Symbol:            e.Symbol,
+                    LossSource:        e.Source,
+                    LossSourceID:      e.SourceID,
+                    LossDate:          r.date,
+                    Loss:              e.Gain,
+                    Shares:            take,
+                    Disallowed:        disallowed,
+                    ReplacementSource: c.source,
+                    ReplacementID:     c.id,
+                    ReplacementDate:   bought,
+                }
+                if lossLot != 0 {
+                    lot := lossLot
+                    w.LossLotID = &lot
+                }
+                book.washes = append(book.washes, w)
+                e.WashSaleDisallowed += disallowed
+            }
+            if e.WashSaleDisallowed != 0 {
+                e.Code = "W"
+                e.Gain += e.WashSaleDisallowed
+            }
+        }
+        book.events = append(book.events, e)
+    }
+    return book
+}
+
+// inWashWindow returns the first of dates within washWindowDays of date
+func inWashWindow(dates []string, date string) (string, bool) {
+    at, err := time.Parse(dateLayout, date)
+    if err != nil {
+        return "", false
+    }
+    from := at.AddDate(0, 0, -washWindowDays).Format(dateLayout)
+    to := at.AddDate(0, 0, washWindowDays).Format(dateLayout)
+    for _, d := range dates {
+        if d >= from && d <= to {
+            return d, true
+        }
+    }
+    return "", false
+}
+
+func optionRight(t models.Trade) string {
+    if isCallType(t.TradeType) {
+        return "CALL"
+    }
+    return "PUT"
+}
+
+func joinNotes(notes, note string) string {
+    if notes == "" {
+        return note
+    }
+    return notes + "; " + note
+}
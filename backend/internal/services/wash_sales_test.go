@@ -0,0 +1,46 @@
+package services
+
+import (
+    "math"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+// A loss replaced by part of a larger lot defers onto the replacement shares
+// alone, and selling those shares realizes all of it
+func TestWashSaleAdjustsReplacementShares(t *testing.T) {
+    lot := 2
+    positions := []models.Position{
+        {PositionID: 1, AccountID: 1, Symbol: "XYZ", Shares: 100, CostBasisPerShare: 50, AcquiredDate: "2026-01-02", Status: "CLOSED"},
+        {PositionID: 2, AccountID: 1, Symbol: "XYZ", Shares: 150, CostBasisPerShare: 40, AcquiredDate: "2026-03-10", Status: "OPEN"},
+        {PositionID: 3, AccountID: 1, Symbol: "XYZ", Shares: 150, CostBasisPerShare: 40, AcquiredDate: "2026-03-10", Status: "CLOSED", SplitFromPositionID: &lot},
+    }
+    sales := []models.LotSale{
+        {SaleID: 1, AccountID: 1, Symbol: "XYZ", LotID: 1, PositionID: 1, Shares: 100, AcquiredDate: "2026-01-02",
+            SoldDate: "2026-03-02", CostBasisPerShare: 50, SalePricePerShare: 40},
+        {SaleID: 2, AccountID: 1, Symbol: "XYZ", LotID: 2, PositionID: 3, Shares: 150, AcquiredDate: "2026-03-10",
+            SoldDate: "2026-06-01", CostBasisPerShare: 40, SalePricePerShare: 45},
+    }
+    book := replayWashSales(nil, positions, sales)
+
+    if len(book.washes) != 1 || book.washes[0].Shares != 100 || book.washes[0].Disallowed != 1000 {
+        t.Fatalf("washes = %+v, want 1000 disallowed on 100 shares", book.washes)
+    }
+    // 150 × 45 against 150 × 40 plus the 1000 deferred onto 100 of them
+    var gain float64
+    for _, e := range book.events {
+        if e.Source == "lot_sale" && e.SourceID == 2 {
+            gain = e.Gain
+        }
+    }
+    if math.Abs(gain-(-250)) > ledgerTolerance {
+        t.Errorf("gain on the replacement = %.2f, want -250.00", gain)
+    }
+    if charged := book.charged[3]; charged != 1000 {
+        t.Errorf("deferred loss in the sold shares = %.2f, want 1000", charged)
+    }
+    if left := book.adjustment(2, 150, "9999-12-31"); left != 0 {
+        t.Errorf("deferred loss left on the lot = %.2f, want 0", left)
+    }
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+    "context"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// wheelLedger loads every trade and position of a wheel
+func (s *TradeService) wheelLedger(ctx context.Context, wheelID int) ([]models.Trade, []models.Position, error) {
+    trades, err := s.trades.List(ctx, repository.TradeFilter{WheelID: wheelID})
+    if err != nil {
+        return nil, nil, err
+    }
+    positions, err := s.positions.List(ctx, repository.PositionFilter{WheelID: wheelID})
+    return trades, positions, err
+}
+
+// WheelBasis returns the raw, premium-adjusted and breakeven basis of the
+// shares the wheel holds now
+func (s *TradeService) WheelBasis(ctx context.Context, wheelID int) (pnl.Basis, error) {
+    if _, err := s.wheels.Get(ctx, wheelID); err != nil {
+        return pnl.Basis{}, err
+    }
+    trades, positions, err := s.wheelLedger(ctx, wheelID)
+    if err != nil {
+        return pnl.Basis{}, err
+    }
+    return pnl.WheelBasis(trades, positions), nil
+}
+
+// BreakevenHistory returns how the wheel's breakeven moved with each trade and lot
+func (s *TradeService) BreakevenHistory(ctx context.Context, wheelID int) ([]pnl.BreakevenPoint, error) {
+    if _, err := s.wheels.Get(ctx, wheelID); err != nil {
+        return nil, err
+    }
+    trades, positions, err := s.wheelLedger(ctx, wheelID)
+    if err != nil {
+        return nil, err
+    }
+    return pnl.BreakevenHistory(trades, positions), nil
+}
+
+// AnnotatePositions fills the computed basis and coverage fields of open
+// positions. A lot on a wheel shares the wheel's option credit and realized
+// stock P&L per share; a lot outside any wheel has nothing to net against.
+func (s *TradeService) AnnotatePositions(ctx context.Context, positions []models.Position) error {
+    type wheelData struct {
+        strikes map[int]float64
+        basis   pnl.Basis
+    }
+    wheels := make(map[int]*wheelData)
+
+    for i := range positions {
+        p := &positions[i]
+        if p.Status != "OPEN" {
+            continue
+        }
+        raw := p.CostBasisPerShare
+        adjusted, breakeven := raw, raw
+        if p.WheelID != nil {
+            data, ok := wheels[*p.WheelID]
+            if !ok {
+                trades, lots, err := s.wheelLedger(ctx, *p.WheelID)
+                if err != nil {
+                    return err
+                }
+                data = &wheelData{strikes: pnl.Strikes(trades), basis: pnl.WheelBasis(trades, lots)}
+                wheels[*p.WheelID] = data
+            }
+            raw = pnl.RawBasis(*p, data.strikes)
+            adjusted, breakeven = raw, raw
+            if data.basis.CreditPerShare != nil {
+                adjusted = raw - *data.basis.CreditPerShare
+                breakeven = adjusted - *data.basis.RealizedPerShare
+            }
+        }
+        p.RawBasisPerShare, p.AdjustedBasisPerShare, p.BreakevenPerShare = &raw, &adjusted, &breakeven
+    }
+    return s.AnnotateCoverage(ctx, positions)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+    "context"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// WheelCloseRequest is the optional payload of POST /wheels/:id/close
+type WheelCloseRequest struct {
+    EndDate string `json:"end_date"`
+}
+
+// CloseWheel archives a wheel whose trades and positions are all closed. The
+// end date defaults to the wheel's last trade close or share sale. The final
+// performance is stored as a snapshot; closing the wheel again after it was
+// reopened replaces it.
+func (s *TradeService) CloseWheel(ctx context.Context, wheelID int, req WheelCloseRequest) (models.WheelSummary, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return models.WheelSummary{}, err
+    }
+    defer tx.Rollback()
+
+    w, err := s.wheels.WithTx(tx).Get(ctx, wheelID)
+    if err != nil {
+        return models.WheelSummary{}, err
+    }
+    if w.Status == "CLOSED" {
+        return models.WheelSummary{}, invalidf("wheel %d is already closed", wheelID)
+    }
+    trades, err := s.trades.WithTx(tx).List(ctx, repository.TradeFilter{WheelID: wheelID})
+    if err != nil {
+        return models.WheelSummary{}, err
+    }
+    positions, err := s.positions.WithTx(tx).List(ctx, repository.PositionFilter{WheelID: wheelID})
+    if err != nil {
+        return models.WheelSummary{}, err
+    }
+
+    start := dateOnly(w.StartDate)
+    lastActivity := start
+    openTrades, openPositions := 0, 0
+    for _, t := range trades {
+        if t.Status == "OPEN" {
+            openTrades++
+        } else if t.CloseDate != nil && dateOnly(*t.CloseDate) > lastActivity {
+            lastActivity = dateOnly(*t.CloseDate)
+        }
+    }
+    for _, p := range positions {
+        if p.Status == "OPEN" {
+            openPositions++
+        } else if p.SoldDate != nil && dateOnly(*p.SoldDate) > lastActivity {
+            lastActivity = dateOnly(*p.SoldDate)
+        }
+    }
+    if openTrades > 0 || openPositions > 0 {
+        return models.WheelSummary{}, invalidf("wheel %d still has %d open trade(s) and %d open position(s)", wheelID, openTrades, openPositions)
+    }
+
+    endDate := lastActivity
+    if req.EndDate != "" {
+        if _, err := time.Parse(dateLayout, req.EndDate); err != nil {
+            return models.WheelSummary{}, invalidf("end_date must be YYYY-MM-DD")
+        }
+        if req.EndDate < lastActivity {
+            return models.WheelSummary{}, invalidf("end_date cannot be before the wheel's last activity on %s", lastActivity)
+        }
+        endDate = req.EndDate
+    }
+
+    if err := s.rollUpWheel(ctx, tx, wheelID); err != nil {
+        return models.WheelSummary{}, err
+    }
+    if err := s.wheels.WithTx(tx).Close(ctx, wheelID, endDate); err != nil {
+        return models.WheelSummary{}, err
+    }
+
+    perf := pnl.WheelPerformance(trades, positions, start, endDate)
+    summary := models.WheelSummary{
+        WheelID:          wheelID,
+        AccountID:        w.AccountID,
+        Symbol:           w.Symbol,
+        StartDate:        start,
+        EndDate:          endDate,
+        DaysHeld:         perf.DaysHeld,
+        TradeCount:       len(trades),
+        TotalPremium:     perf.TotalPremium,
+        RealizedPnL:      perf.RealizedPnL,
+        CapitalAtRisk:    perf.CapitalAtRisk,
+        ReturnOnCapital:  perf.ReturnOnCapital,
+        AnnualizedReturn: perf.AnnualizedReturn,
+    }
+    summaries := s.summaries.WithTx(tx)
+    if err := summaries.Save(ctx, summary); err != nil {
+        return models.WheelSummary{}, err
+    }
+    if summary, err = summaries.Get(ctx, wheelID); err != nil {
+        return summary, err
+    }
+    return summary, tx.Commit()
+}
+
+// WheelSummary returns the snapshot stored when the wheel was closed
+func (s *TradeService) WheelSummary(ctx context.Context, wheelID int) (models.WheelSummary, error) {
+    return s.summaries.Get(ctx, wheelID)
+}
+
+// ListWheelSummaries returns the snapshots of closed wheels for comparison
+func (s *TradeService) ListWheelSummaries(ctx context.Context, f repository.WheelSummaryFilter) ([]models.WheelSummary, error) {
+    return s.summaries.List(ctx, f)
+}
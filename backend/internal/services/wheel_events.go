@@ -0,0 +1,244 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// inWheelTx runs fn in a transaction and then syncs the wheel it touched, so
+// a ledger edit that would push the wheel into an illegal phase is undone.
+// Deletes pass force: removing a mistaken row may legitimately take the
+// wheel back to an earlier phase.
+func (s *TradeService) inWheelTx(ctx context.Context, force bool, fn func(tx *sql.Tx) (*int, error)) error {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    wheelID, err := fn(tx)
+    if err != nil {
+        return err
+    }
+    if wheelID != nil {
+        if _, err := s.syncWheel(ctx, tx, *wheelID, force); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// OpenTrade inserts a new OPEN trade, linking it to a wheel when it names
+// none, and advances that wheel. A trade the account cannot afford is
+// rejected unless allowShortfall is set, in which case it is opened and the
+// shortfall added to its warnings. A call that leaves the account short more
+// calls than it holds shares is opened with a warning too.
+func (s *TradeService) OpenTrade(ctx context.Context, t *models.Trade, allowShortfall bool) error {
+    return s.inWheelTx(ctx, false, func(tx *sql.Tx) (*int, error) {
+        if err := s.checkTradeRefs(ctx, tx, *t); err != nil {
+            return nil, err
+        }
+        shortfall, err := s.checkBuyingPower(ctx, tx, *t)
+        if err != nil {
+            return nil, err
+        }
+        if shortfall != "" && !allowShortfall {
+            return nil, invalidf("%s", shortfall)
+        }
+        naked, err := s.checkCoverage(ctx, tx, *t)
+        if err != nil {
+            return nil, err
+        }
+        t.Warnings = nil
+        for _, w := range []string{shortfall, naked} {
+            if w != "" {
+                t.Warnings = append(t.Warnings, w)
+            }
+        }
+        if err := s.linkWheel(ctx, tx, t); err != nil {
+            return nil, err
+        }
+        if err := s.trades.WithTx(tx).Create(ctx, t); err != nil {
+            return nil, err
+        }
+        return t.WheelID, s.postTrades(ctx, tx, t.TradeID)
+    })
+}
+
+// checkTradeRefs makes sure the account, wheel and roll parent a new trade
+// names exist, so each missing one is reported as what it is
+func (s *TradeService) checkTradeRefs(ctx context.Context, tx *sql.Tx, t models.Trade) error {
+    _, err := s.accounts.WithTx(tx).Get(ctx, t.AccountID)
+    if errors.Is(err, repository.ErrNotFound) {
+        return invalidf("account %d not found", t.AccountID)
+    }
+    if err != nil {
+        return err
+    }
+    if t.WheelID != nil {
+        _, err := s.wheels.WithTx(tx).Get(ctx, *t.WheelID)
+        if errors.Is(err, repository.ErrNotFound) {
+            return invalidf("wheel %d not found", *t.WheelID)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    if t.ParentTradeID != nil {
+        _, err := s.trades.WithTx(tx).Get(ctx, *t.ParentTradeID)
+        if errors.Is(err, repository.ErrNotFound) {
+            return invalidf("parent trade %d not found", *t.ParentTradeID)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// UpdateTrade rewrites the editable fields of a trade, posts its cash again
+// and refreshes its wheel. An edit that commits more than the account has
+// free is rejected unless allowShortfall is set, as in OpenTrade.
+func (s *TradeService) UpdateTrade(ctx context.Context, t *models.Trade, allowShortfall bool) error {
+    return s.inWheelTx(ctx, false, func(tx *sql.Tx) (*int, error) {
+        trades := s.trades.WithTx(tx)
+        current, err := trades.Get(ctx, t.TradeID)
+        if err != nil {
+            return nil, err
+        }
+        edited := current
+        edited.TradeType, edited.Contracts, edited.StrikePrice = t.TradeType, t.Contracts, t.StrikePrice
+        edited.PremiumPerShare, edited.Fees = t.PremiumPerShare, t.Fees
+        if t.Deliverable != 0 {
+            edited.Deliverable = t.Deliverable
+        }
+        shortfall, err := s.checkCommitment(ctx, tx, current.AccountID, commitment(edited)-commitment(current))
+        if err != nil {
+            return nil, err
+        }
+        if shortfall != "" && !allowShortfall {
+            return nil, invalidf("%s", shortfall)
+        }
+        t.Warnings = nil
+        if shortfall != "" {
+            t.Warnings = []string{shortfall}
+        }
+        if err := trades.Update(ctx, *t); err != nil {
+            return nil, err
+        }
+        return current.WheelID, s.postTrades(ctx, tx, t.TradeID)
+    })
+}
+
+// DeleteTrade removes a trade and re-derives its wheel. Shares its
+// assignment bought or called away are posted again as plain purchases and
+// sales, since no strike is left to pay or receive.
+func (s *TradeService) DeleteTrade(ctx context.Context, id int) error {
+    return s.inWheelTx(ctx, true, func(tx *sql.Tx) (*int, error) {
+        trades := s.trades.WithTx(tx)
+        t, err := trades.Get(ctx, id)
+        if err != nil {
+            return nil, err
+        }
+        assigned, err := s.assignedPositions(ctx, tx, t)
+        if err != nil {
+            return nil, err
+        }
+        if err := trades.Delete(ctx, id); err != nil {
+            return nil, err
+        }
+        // Its ledger entries went with it
+        if err := s.accounts.WithTx(tx).RefreshBalance(ctx, t.AccountID); err != nil {
+            return nil, err
+        }
+        return t.WheelID, s.postPositions(ctx, tx, assigned...)
+    })
+}
+
+// assignedPositions returns the positions whose cash was posted through the
+// assignment of t: the lot its put bought and the rows its call sold
+func (s *TradeService) assignedPositions(ctx context.Context, tx *sql.Tx, t models.Trade) ([]int, error) {
+    var ids []int
+    lots, err := s.positions.WithTx(tx).List(ctx, repository.PositionFilter{AccountID: t.AccountID, Symbol: t.Symbol})
+    if err != nil {
+        return nil, err
+    }
+    for _, p := range lots {
+        if p.SourceTradeID != nil && *p.SourceTradeID == t.TradeID {
+            ids = append(ids, p.PositionID)
+        }
+    }
+    sales, err := s.lotSales.WithTx(tx).List(ctx, repository.LotSaleFilter{TradeID: t.TradeID})
+    if err != nil {
+        return nil, err
+    }
+    for _, l := range sales {
+        ids = append(ids, l.PositionID)
+    }
+    return ids, nil
+}
+
+// CreatePosition inserts an OPEN position, debits its cost and advances its wheel
+func (s *TradeService) CreatePosition(ctx context.Context, p *models.Position) error {
+    return s.inWheelTx(ctx, false, func(tx *sql.Tx) (*int, error) {
+        if err := s.positions.WithTx(tx).Create(ctx, p); err != nil {
+            return nil, err
+        }
+        return p.WheelID, s.postPositions(ctx, tx, p.PositionID)
+    })
+}
+
+// UpdatePosition rewrites the editable fields of a position, posts the cost
+// of its lot again and refreshes its wheel
+func (s *TradeService) UpdatePosition(ctx context.Context, p models.Position) error {
+    return s.inWheelTx(ctx, false, func(tx *sql.Tx) (*int, error) {
+        positions := s.positions.WithTx(tx)
+        current, err := positions.Get(ctx, p.PositionID)
+        if err != nil {
+            return nil, err
+        }
+        if err := positions.Update(ctx, p); err != nil {
+            return nil, err
+        }
+        return current.WheelID, s.postPositions(ctx, tx, lotOf(current), p.PositionID)
+    })
+}
+
+// DeletePosition removes a position with its postings and re-derives its
+// wheel. Removing sold shares takes their cost off their lot's purchase; a
+// lot whose shares were partly sold goes only after those rows.
+func (s *TradeService) DeletePosition(ctx context.Context, id int) error {
+    return s.inWheelTx(ctx, true, func(tx *sql.Tx) (*int, error) {
+        positions := s.positions.WithTx(tx)
+        p, err := positions.Get(ctx, id)
+        if err != nil {
+            return nil, err
+        }
+        rows, err := positions.ListLot(ctx, id)
+        if err != nil {
+            return nil, err
+        }
+        if p.SplitFromPositionID == nil && len(rows) > 1 {
+            return nil, invalidf("position %d has shares sold on other positions; delete those first", id)
+        }
+        if err := positions.Delete(ctx, id); err != nil {
+            return nil, err
+        }
+        if p.SplitFromPositionID != nil {
+            return p.WheelID, s.postPositions(ctx, tx, *p.SplitFromPositionID)
+        }
+        return p.WheelID, s.accounts.WithTx(tx).RefreshBalance(ctx, p.AccountID)
+    })
+}
+
+// lotOf is the lot p belongs to: itself, or the lot its shares were sold off
+func lotOf(p models.Position) int {
+    if p.SplitFromPositionID != nil {
+        return *p.SplitFromPositionID
+    }
+    return p.PositionID
+}
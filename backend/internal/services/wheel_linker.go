@@ -0,0 +1,121 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// linkWheel sets t.WheelID when the trade has none: it joins the open wheel of
+// the same account and symbol, and a put with no wheel to join starts a new
+// one. Calls without a wheel stay unlinked since the shares they cover were
+// not bought through a wheel, and so do bought legs and the legs of a
+// strategy group. It does not write the trade itself.
+func (s *TradeService) linkWheel(ctx context.Context, tx *sql.Tx, t *models.Trade) error {
+    if t.WheelID != nil || t.StrategyGroupID != nil || t.Side == "BTO" {
+        return nil
+    }
+    wheels := s.wheels.WithTx(tx)
+
+    w, err := wheels.FindOpen(ctx, t.AccountID, t.Symbol)
+    if err == nil {
+        t.WheelID = &w.WheelID
+        return nil
+    }
+    if !errors.Is(err, repository.ErrNotFound) {
+        return err
+    }
+    if t.TradeType != "CSP" && t.TradeType != "PUT" {
+        return nil
+    }
+
+    w = models.Wheel{AccountID: t.AccountID, Symbol: t.Symbol, StartDate: dateOnly(t.OpenDate)}
+    if err := wheels.Create(ctx, &w); err != nil {
+        return err
+    }
+    t.WheelID = &w.WheelID
+    return nil
+}
+
+// linkStoredTrade links a trade that is already saved and persists the link
+func (s *TradeService) linkStoredTrade(ctx context.Context, tx *sql.Tx, t *models.Trade) error {
+    if t.WheelID != nil {
+        return nil
+    }
+    if err := s.linkWheel(ctx, tx, t); err != nil || t.WheelID == nil {
+        return err
+    }
+    return s.trades.WithTx(tx).SetWheel(ctx, t.TradeID, *t.WheelID)
+}
+
+// WheelBackfill reports what BackfillWheels changed
+type WheelBackfill struct {
+    TradesLinked        int   `json:"trades_linked"`
+    WheelsCreated       []int `json:"wheels_created"`
+    WheelsUpdated       []int `json:"wheels_updated"`
+    WheelsWithPositions []int `json:"wheels_with_positions"`
+}
+
+// BackfillWheels links every trade without a wheel, oldest first, exactly as
+// if each had just been entered: each wheel is re-derived after every trade so
+// a called-away wheel is completed before the next put starts a new one.
+func (s *TradeService) BackfillWheels(ctx context.Context) (*WheelBackfill, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    orphans, err := s.trades.WithTx(tx).ListUnlinked(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    result := &WheelBackfill{WheelsCreated: make([]int, 0), WheelsUpdated: make([]int, 0)}
+    touched := make(map[int]bool)
+    for i := range orphans {
+        t := &orphans[i]
+        before, err := s.wheels.WithTx(tx).FindOpen(ctx, t.AccountID, t.Symbol)
+        if err != nil && !errors.Is(err, repository.ErrNotFound) {
+            return nil, err
+        }
+        if err := s.linkStoredTrade(ctx, tx, t); err != nil {
+            return nil, err
+        }
+        if t.WheelID == nil {
+            continue
+        }
+
+        result.TradesLinked++
+        if *t.WheelID != before.WheelID && !touched[*t.WheelID] {
+            result.WheelsCreated = append(result.WheelsCreated, *t.WheelID)
+        } else if !touched[*t.WheelID] {
+            result.WheelsUpdated = append(result.WheelsUpdated, *t.WheelID)
+        }
+        touched[*t.WheelID] = true
+
+        if _, err := s.syncWheel(ctx, tx, *t.WheelID, true); err != nil {
+            return nil, err
+        }
+    }
+
+    // Positions created by assignment follow the wheel of their trade
+    wheelIDs, err := s.positions.WithTx(tx).LinkToTradeWheels(ctx)
+    if err != nil {
+        return nil, err
+    }
+    result.WheelsWithPositions = wheelIDs
+    for _, wheelID := range wheelIDs {
+        if _, err := s.syncWheel(ctx, tx, wheelID, true); err != nil {
+            return nil, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return result, nil
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+    "context"
+    "sort"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+)
+
+// wheelSortKeys maps the sort values ListWheels accepts to the metric they order by
+var wheelSortKeys = map[string]func(w models.Wheel) float64{
+    "capital_at_risk":   func(w models.Wheel) float64 { return derefFloat(w.CapitalAtRisk) },
+    "return_on_capital": func(w models.Wheel) float64 { return derefFloat(w.ReturnOnCapital) },
+    "annualized_return": func(w models.Wheel) float64 { return derefFloat(w.AnnualizedReturn) },
+    "premium_per_day":   func(w models.Wheel) float64 { return derefFloat(w.PremiumPerDay) },
+    "total_pnl":         func(w models.Wheel) float64 { return w.TotalPnL },
+    "total_premium":     func(w models.Wheel) float64 { return w.TotalPremium },
+}
+
+// AnnotateWheels fills the computed return fields of each wheel from its
+// trades and positions. A running wheel is measured up to today.
+func (s *TradeService) AnnotateWheels(ctx context.Context, wheels []models.Wheel) error {
+    for i := range wheels {
+        w := &wheels[i]
+        trades, positions, err := s.wheelLedger(ctx, w.WheelID)
+        if err != nil {
+            return err
+        }
+        asOf := today()
+        if w.EndDate != nil {
+            asOf = dateOnly(*w.EndDate)
+        }
+        m := pnl.WheelMetrics(trades, positions, dateOnly(w.StartDate), asOf)
+        w.DaysActive = &m.DaysActive
+        w.CapitalAtRisk = &m.CapitalAtRisk
+        w.ReturnOnCapital = &m.ReturnOnCapital
+        w.AnnualizedReturn = &m.AnnualizedReturn
+        w.PremiumPerDay = &m.PremiumPerDay
+    }
+    return nil
+}
+
+// SortWheels orders annotated wheels by one of the wheelSortKeys, highest
+// first unless ascending. An empty key keeps the repository order.
+func SortWheels(wheels []models.Wheel, key string, ascending bool) error {
+    if key == "" {
+        return nil
+    }
+    metric, ok := wheelSortKeys[key]
+    if !ok {
+        return invalidf("cannot sort wheels by %q", key)
+    }
+    sort.SliceStable(wheels, func(i, j int) bool {
+        if ascending {
+            return metric(wheels[i]) < metric(wheels[j])
+        }
+        return metric(wheels[i]) > metric(wheels[j])
+    })
+    return nil
+}
+
+func derefFloat(p *float64) float64 {
+    if p == nil {
+        return 0
+    }
+    return *p
+}
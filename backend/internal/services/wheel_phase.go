@@ -0,0 +1,229 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+    "math"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// wheelTransitions lists the phases a wheel may move to from each phase.
+// A covered call that expires takes the wheel back to HOLDING; selling the
+// shares outright completes it without a call.
+var wheelTransitions = map[string][]string{
+    "CSP":       {"HOLDING"},
+    "HOLDING":   {"CC", "COMPLETED"},
+    "CC":        {"HOLDING", "COMPLETED"},
+    "COMPLETED": {},
+}
+
+// canReach reports whether phase to follows from phase from through zero or
+// more legal transitions; one ledger event may cover several steps, e.g. a
+// put assigned and a call sold on the same shares
+func canReach(from, to string) bool {
+    seen := map[string]bool{from: true}
+    queue := []string{from}
+    for len(queue) > 0 {
+        phase := queue[0]
+        queue = queue[1:]
+        if phase == to {
+            return true
+        }
+        for _, next := range wheelTransitions[phase] {
+            if !seen[next] {
+                seen[next] = true
+                queue = append(queue, next)
+            }
+        }
+    }
+    return false
+}
+
+// derivePhase reads the wheel's phase off its ledger: no shares yet is CSP,
+// shares with an open call is CC, shares without one is HOLDING, and once
+// every share is gone with nothing left open the wheel is COMPLETED. The
+// returned date is the last sale, used as the end date of a completed wheel.
+func derivePhase(trades []models.Trade, positions []models.Position) (string, string) {
+    if len(positions) == 0 {
+        return phaseFromAssignments(trades)
+    }
+
+    openShares := false
+    lastSale := ""
+    for _, p := range positions {
+        if p.Status == "OPEN" {
+            openShares = true
+        } else if p.SoldDate != nil && dateOnly(*p.SoldDate) > lastSale {
+            lastSale = dateOnly(*p.SoldDate)
+        }
+    }
+
+    openCall, openTrade := false, false
+    for _, t := range trades {
+        if t.Status != "OPEN" {
+            continue
+        }
+        openTrade = true
+        if t.TradeType == "CC" || t.TradeType == "CALL" {
+            openCall = true
+        }
+    }
+
+    switch {
+    case openShares && openCall:
+        return "CC", ""
+    case openShares:
+        return "HOLDING", ""
+    case openTrade:
+        // The shares are gone but an option on the wheel is still open
+        return "HOLDING", ""
+    }
+    return "COMPLETED", lastSale
+}
+
+// phaseFromAssignments derives the phase of a wheel that has no position rows,
+// as happens with imported history: an assigned put means shares are held and
+// an assigned call means they were delivered
+func phaseFromAssignments(trades []models.Trade) (string, string) {
+    assignedPut, calledAway, openCall, openTrade := false, false, false, false
+    endDate := ""
+    for _, t := range trades {
+        isCall := t.TradeType == "CC" || t.TradeType == "CALL"
+        if t.Status == "OPEN" {
+            openTrade = true
+            openCall = openCall || isCall
+            continue
+        }
+        if t.CloseMethod == nil || *t.CloseMethod != "ASSIGNMENT" {
+            continue
+        }
+        if !isCall {
+            assignedPut = true
+            continue
+        }
+        calledAway = true
+        if t.CloseDate != nil && dateOnly(*t.CloseDate) > endDate {
+            endDate = dateOnly(*t.CloseDate)
+        }
+    }
+
+    switch {
+    case calledAway && !openTrade:
+        return "COMPLETED", endDate
+    case openCall:
+        return "CC", ""
+    case assignedPut || calledAway:
+        return "HOLDING", ""
+    }
+    return "CSP", ""
+}
+
+// syncWheel moves the wheel to the phase its ledger implies and refreshes its
+// totals. Unless force is set a move the state machine does not allow is
+// rejected, which rolls back the event that caused it.
+func (s *TradeService) syncWheel(ctx context.Context, tx *sql.Tx, wheelID int, force bool) (models.Wheel, error) {
+    wheels := s.wheels.WithTx(tx)
+    w, err := wheels.Get(ctx, wheelID)
+    if err != nil {
+        return w, err
+    }
+    trades, err := s.trades.WithTx(tx).List(ctx, repository.TradeFilter{WheelID: wheelID})
+    if err != nil {
+        return w, err
+    }
+    positions, err := s.positions.WithTx(tx).List(ctx, repository.PositionFilter{WheelID: wheelID})
+    if err != nil {
+        return w, err
+    }
+
+    current := "CSP"
+    if w.CurrentPhase != nil {
+        current = *w.CurrentPhase
+    }
+    phase, endDate := derivePhase(trades, positions)
+    if phase != current {
+        if !force && !canReach(current, phase) {
+            return w, invalidf("wheel %d cannot move from %s to %s", wheelID, current, phase)
+        }
+        if phase == "COMPLETED" {
+            if endDate == "" {
+                endDate = today()
+            }
+            err = wheels.Complete(ctx, wheelID, endDate)
+        } else {
+            err = wheels.SetPhase(ctx, wheelID, phase)
+        }
+        if err != nil {
+            return w, err
+        }
+    }
+
+    if err := s.rollUpWheel(ctx, tx, wheelID); err != nil {
+        return w, err
+    }
+    return wheels.Get(ctx, wheelID)
+}
+
+// RecomputeWheel rebuilds phase and totals of a wheel from its trades and
+// positions, accepting whatever phase the ledger implies
+func (s *TradeService) RecomputeWheel(ctx context.Context, wheelID int) (models.Wheel, error) {
+    tx, err := s.db.BeginTx(ctx, nil)
+    if err != nil {
+        return models.Wheel{}, err
+    }
+    defer tx.Rollback()
+
+    w, err := s.syncWheel(ctx, tx, wheelID, true)
+    if err != nil {
+        return w, err
+    }
+    return w, tx.Commit()
+}
+
+// WheelUpdate is the payload of PUT /wheels/:id. Phase and totals are derived
+// from the ledger; they are accepted only when they match the stored values
+// so clients that echo the whole wheel back keep working.
+type WheelUpdate struct {
+    Status       *string  `json:"status"`
+    CurrentPhase *string  `json:"current_phase"`
+    TotalPremium *float64 `json:"total_premium"`
+    TotalPnL     *float64 `json:"total_pnl"`
+}
+
+// UpdateWheel applies the editable part of a wheel update, rejecting manual
+// changes to derived fields
+func (s *TradeService) UpdateWheel(ctx context.Context, wheelID int, u WheelUpdate) (models.Wheel, error) {
+    w, err := s.wheels.Get(ctx, wheelID)
+    if err != nil {
+        return w, err
+    }
+
+    if u.CurrentPhase != nil && (w.CurrentPhase == nil || *u.CurrentPhase != *w.CurrentPhase) {
+        return w, invalidf("current_phase is derived from the wheel's trades and positions; use POST /wheels/%d/recompute", wheelID)
+    }
+    if (u.TotalPremium != nil && !sameAmount(*u.TotalPremium, w.TotalPremium)) ||
+        (u.TotalPnL != nil && !sameAmount(*u.TotalPnL, w.TotalPnL)) {
+        return w, invalidf("total_premium and total_pnl are derived from the wheel's trades and positions; use POST /wheels/%d/recompute", wheelID)
+    }
+
+    if u.Status != nil && *u.Status != w.Status {
+        switch *u.Status {
+        case "ACTIVE":
+        case "CLOSED":
+            return w, invalidf("closing a wheel sets its end date and final summary; use POST /wheels/%d/close", wheelID)
+        default:
+            return w, invalidf("status must be ACTIVE or CLOSED")
+        }
+        if err := s.wheels.SetStatus(ctx, wheelID, *u.Status); err != nil {
+            return w, err
+        }
+    }
+    return s.wheels.Get(ctx, wheelID)
+}
+
+// sameAmount compares money values stored as REAL to the cent
+func sameAmount(a, b float64) bool {
+    return math.Abs(a-b) < 0.005
+}
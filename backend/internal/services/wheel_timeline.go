@@ -0,0 +1,194 @@
+package services
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/wheel-tracker/backend/internal/models"
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// timelineStep is a WheelEvent before the running figures are known
+type timelineStep struct {
+    event        models.WheelEvent
+    order        int // breaks ties on the same date: opens, closes, lots, income
+    premiumDelta float64
+    pnlDelta     float64
+}
+
+// WheelTimeline returns the wheel's trades, lots and dividends as one
+// chronological list. Running premium is the net option credit so far and
+// running P&L adds realized option, stock and dividend results. A roll is a
+// single ROLLED step, and the shares that change hands on assignment are
+// folded into the ASSIGNED or CALLED_AWAY step.
+func (s *TradeService) WheelTimeline(ctx context.Context, wheelID int) ([]models.WheelEvent, error) {
+    w, err := s.wheels.Get(ctx, wheelID)
+    if err != nil {
+        return nil, err
+    }
+    trades, positions, err := s.wheelLedger(ctx, wheelID)
+    if err != nil {
+        return nil, err
+    }
+    dividends, err := s.income.List(ctx, repository.IncomeFilter{AccountID: w.AccountID, IncomeType: "DIVIDEND", Symbol: w.Symbol})
+    if err != nil {
+        return nil, err
+    }
+
+    byID := make(map[int]models.Trade, len(trades))
+    rolledParents := make(map[int]bool)
+    for _, t := range trades {
+        byID[t.TradeID] = t
+    }
+    for _, t := range trades {
+        if t.ParentTradeID != nil {
+            if _, ok := byID[*t.ParentTradeID]; ok {
+                rolledParents[*t.ParentTradeID] = true
+            }
+        }
+    }
+
+    var steps []*timelineStep
+    calledAway := make(map[string]*timelineStep)
+    for _, t := range trades {
+        t := t
+        tradeID := t.TradeID
+        open := &timelineStep{order: 0, premiumDelta: pnl.Unrealized(t, 0)}
+        open.event = models.WheelEvent{Date: dateOnly(t.OpenDate), TradeID: &tradeID}
+        switch {
+        case t.ParentTradeID != nil && rolledParents[*t.ParentTradeID]:
+            parent := byID[*t.ParentTradeID]
+            open.event.Type = "ROLLED"
+            open.event.Description = fmt.Sprintf("Rolled %s %.2f to %.2f exp %s", parent.TradeType, parent.StrikePrice, t.StrikePrice, dateOnly(t.ExpirationDate))
+            open.premiumDelta -= pnl.ClosePrice(parent) * pnl.Multiplier(parent)
+            open.pnlDelta = pnl.Realized(parent)
+        case isCallType(t.TradeType):
+            open.event.Type = "CC_SOLD"
+            open.event.Description = fmt.Sprintf("Sold %d %s %.2f call exp %s @ %.2f", t.Contracts, t.Symbol, t.StrikePrice, dateOnly(t.ExpirationDate), t.PremiumPerShare)
+        default:
+            open.event.Type = "CSP_OPENED"
+            open.event.Description = fmt.Sprintf("Sold %d %s %.2f put exp %s @ %.2f", t.Contracts, t.Symbol, t.StrikePrice, dateOnly(t.ExpirationDate), t.PremiumPerShare)
+        }
+        open.event.Amount = open.premiumDelta
+        steps = append(steps, open)
+
+        if t.Status != "CLOSED" || t.CloseDate == nil || rolledParents[t.TradeID] {
+            continue
+        }
+        method := ""
+        if t.CloseMethod != nil {
+            method = *t.CloseMethod
+        }
+        closed := &timelineStep{order: 1, pnlDelta: pnl.Realized(t)}
+        closed.event = models.WheelEvent{Date: dateOnly(*t.CloseDate), TradeID: &tradeID}
+        shares := pnl.Shares(t)
+        switch {
+        case method == "EXPIRATION":
+            closed.event.Type = "EXPIRED"
+            closed.event.Description = fmt.Sprintf("%s %.2f expired worthless", t.TradeType, t.StrikePrice)
+        case method == "ASSIGNMENT" && isCallType(t.TradeType):
+            closed.event.Type = "CALLED_AWAY"
+            closed.event.Description = fmt.Sprintf("%d shares called away @ %.2f", shares, t.StrikePrice)
+            closed.event.Amount = pnl.Capital(t)
+            calledAway[closed.event.Date] = closed
+        case method == "ASSIGNMENT":
+            closed.event.Type = "ASSIGNED"
+            closed.event.Description = fmt.Sprintf("Assigned %d shares @ %.2f", shares, t.StrikePrice)
+            closed.event.Amount = -pnl.Capital(t)
+        default:
+            cost := pnl.ClosePrice(t) * pnl.Multiplier(t)
+            closed.event.Type = "BOUGHT_BACK"
+            closed.event.Description = fmt.Sprintf("Bought back %s %.2f @ %.2f", t.TradeType, t.StrikePrice, pnl.ClosePrice(t))
+            closed.event.Amount = -cost
+            closed.premiumDelta = -cost
+        }
+        steps = append(steps, closed)
+    }
+
+    strikes := pnl.Strikes(trades)
+    for _, p := range positions {
+        p := p
+        positionID := p.PositionID
+        raw := pnl.RawBasis(p, strikes)
+        if _, assigned := byID[derefInt(p.SourceTradeID)]; !assigned {
+            steps = append(steps, &timelineStep{order: 2, event: models.WheelEvent{
+                Date:        dateOnly(p.AcquiredDate),
+                Type:        "SHARES_BOUGHT",
+                Description: fmt.Sprintf("Bought %d shares @ %.2f", p.Shares, p.CostBasisPerShare),
+                PositionID:  &positionID,
+                Amount:      -p.CostBasisPerShare * float64(p.Shares),
+            }})
+        }
+        if p.Status != "CLOSED" || p.SoldDate == nil || p.SoldPricePerShare == nil {
+            continue
+        }
+        stock := pnl.Stock(p.Shares, raw, *p.SoldPricePerShare)
+        if step, ok := calledAway[dateOnly(*p.SoldDate)]; ok {
+            step.pnlDelta += stock
+            continue
+        }
+        steps = append(steps, &timelineStep{order: 3, pnlDelta: stock, event: models.WheelEvent{
+            Date:        dateOnly(*p.SoldDate),
+            Type:        "SHARES_SOLD",
+            Description: fmt.Sprintf("Sold %d shares @ %.2f", p.Shares, *p.SoldPricePerShare),
+            PositionID:  &positionID,
+            Amount:      *p.SoldPricePerShare * float64(p.Shares),
+        }})
+    }
+
+    start := dateOnly(w.StartDate)
+    end := ""
+    if w.EndDate != nil {
+        end = dateOnly(*w.EndDate)
+    }
+    for _, d := range dividends {
+        d := d
+        date := dateOnly(d.PaymentDate)
+        if date < start || (end != "" && date > end) {
+            continue
+        }
+        steps = append(steps, &timelineStep{order: 4, pnlDelta: d.Amount, event: models.WheelEvent{
+            Date:        date,
+            Type:        "DIVIDEND",
+            Description: fmt.Sprintf("Dividend %.2f %s", d.Amount, d.Currency),
+            IncomeID:    &d.IncomeID,
+            Amount:      d.Amount,
+        }})
+    }
+
+    sort.SliceStable(steps, func(i, j int) bool {
+        if steps[i].event.Date != steps[j].event.Date {
+            return steps[i].event.Date < steps[j].event.Date
+        }
+        return steps[i].order < steps[j].order
+    })
+
+    startDate, _ := time.Parse(dateLayout, start)
+    events := make([]models.WheelEvent, 0, len(steps))
+    var premium, total float64
+    for _, step := range steps {
+        premium += step.premiumDelta
+        total += step.pnlDelta
+        step.event.RunningPremium = premium
+        step.event.RunningPnL = total
+        if date, err := time.Parse(dateLayout, step.event.Date); err == nil && !startDate.IsZero() {
+            step.event.DaysInCycle = int(date.Sub(startDate).Hours() / 24)
+        }
+        events = append(events, step.event)
+    }
+    return events, nil
+}
+
+func isCallType(tradeType string) bool {
+    return tradeType == "CC" || tradeType == "CALL"
+}
+
+func derefInt(p *int) int {
+    if p == nil {
+        return 0
+    }
+    return *p
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+    "context"
+    "database/sql"
+
+    "github.com/wheel-tracker/backend/internal/pnl"
+    "github.com/wheel-tracker/backend/internal/repository"
+)
+
+// rollUpWheel recomputes total_premium and total_pnl from the wheel's trades
+// and positions. Stock P&L of assigned shares is measured from the put strike,
+// over the lot's split ratio as pnl.WheelBasis does, so premium folded into
+// the basis is not counted twice.
+func (s *TradeService) rollUpWheel(ctx context.Context, tx *sql.Tx, wheelID int) error {
+    trades, err := s.trades.WithTx(tx).List(ctx, repository.TradeFilter{WheelID: wheelID})
+    if err != nil {
+        return err
+    }
+    positions, err := s.positions.WithTx(tx).List(ctx, repository.PositionFilter{WheelID: wheelID})
+    if err != nil {
+        return err
+    }
+
+    var totalPremium, totalPnL float64
+    for _, t := range trades {
+        totalPremium += pnl.Premium(t)
+        if t.Status == "CLOSED" {
+            totalPnL += pnl.Realized(t)
+        }
+    }
+
+    strikes := pnl.Strikes(trades)
+    for _, p := range positions {
+        if p.Status != "CLOSED" || p.SoldPricePerShare == nil {
+            continue
+        }
+        totalPnL += pnl.Stock(p.Shares, pnl.RawBasis(p, strikes), *p.SoldPricePerShare)
+    }
+
+    return s.wheels.WithTx(tx).SetTotals(ctx, wheelID, totalPremium, totalPnL)
+}
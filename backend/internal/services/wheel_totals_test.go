@@ -0,0 +1,55 @@
+package services
+
+import (
+    "context"
+    "testing"
+
+    "github.com/wheel-tracker/backend/internal/models"
+)
+
+func TestWheelTotalsAfterSplit(t *testing.T) {
+    s := newTestService(t)
+    ctx := context.Background()
+
+    put := openTestTrade(t, s, models.Trade{
+        Symbol:          "ABC",
+        TradeType:       "CSP",
+        Contracts:       1,
+        StrikePrice:     100,
+        PremiumPerShare: 2,
+        OpenDate:        "2026-01-05",
+        ExpirationDate:  "2026-01-16",
+    })
+    closed, err := s.CloseTrade(ctx, put.TradeID, CloseTradeRequest{CloseMethod: "ASSIGNMENT"})
+    if err != nil {
+        t.Fatalf("assign put: %v", err)
+    }
+    if closed.Position == nil || closed.Trade.WheelID == nil {
+        t.Fatalf("assignment booked no position on a wheel: %+v", closed)
+    }
+
+    if _, err := s.ApplyCorporateAction(ctx, CorporateActionRequest{
+        ActionType:    "SPLIT",
+        Symbol:        "ABC",
+        SplitFrom:     1,
+        SplitTo:       2,
+        EffectiveDate: "2026-02-02",
+    }); err != nil {
+        t.Fatalf("apply split: %v", err)
+    }
+    if _, err := s.ClosePosition(ctx, closed.Position.PositionID, ClosePositionRequest{
+        SoldDate:          "2026-03-02",
+        SoldPricePerShare: 55,
+    }); err != nil {
+        t.Fatalf("sell position: %v", err)
+    }
+
+    w, err := s.wheels.Get(ctx, *closed.Trade.WheelID)
+    if err != nil {
+        t.Fatalf("get wheel: %v", err)
+    }
+    // 200 premium, and 200 shares bought at 50 after the split sold at 55
+    if w.TotalPnL != 200+1000 {
+        t.Errorf("total_pnl = %.2f, want 1200", w.TotalPnL)
+    }
+}